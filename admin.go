@@ -5,6 +5,7 @@ import "github.com/gorilla/mux"
 import "fmt"
 import "net/http"
 import "strconv"
+import "strings"
 
 type AdminFormParams struct {
 	Frame FrameParams
@@ -84,6 +85,7 @@ type AdminUserParams struct {
 	User            *User
 	StatusAction    string // action that admin can take on this user, either "disable" or "enable" depending on current user status
 	VirtualMachines []*VirtualMachine
+	Quotas          []*QuotaResource
 	Token           string
 }
 
@@ -98,6 +100,7 @@ func adminUser(w http.ResponseWriter, r *http.Request, session *Session, framePa
 			params.StatusAction = "disable"
 		}
 		params.VirtualMachines = vmList(user.Id)
+		params.Quotas = userQuotaSummary(user.Id)
 		params.Token = CSRFGenerate(session)
 		RenderTemplate(w, "admin", "user", params)
 	})
@@ -146,6 +149,30 @@ func adminUserPassword(w http.ResponseWriter, r *http.Request, session *Session,
 	})
 }
 
+// Sets quota overrides from the quota_{resource} form fields; empty fields remove the override.
+func adminUserQuotas(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	adminUserProcess(w, r, session, frameParams, func(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams, user *User) {
+		quotas := make(map[string]int)
+		for _, resource := range QUOTA_RESOURCES {
+			str := strings.TrimSpace(r.PostFormValue("quota_" + resource))
+			if str == "" {
+				quotas[resource] = -1
+				continue
+			}
+			quota, err := strconv.Atoi(str)
+			if err != nil || quota < 0 {
+				RedirectMessage(w, r, fmt.Sprintf("/admin/user/%d", user.Id), L.FormattedErrorf("invalid_quota", L.T("quota_"+resource)))
+				return
+			}
+			quotas[resource] = quota
+		}
+		for resource, quota := range quotas {
+			userQuotaSet(user.Id, resource, quota)
+		}
+		RedirectMessage(w, r, fmt.Sprintf("/admin/user/%d", user.Id), L.Success("quotas_updated"))
+	})
+}
+
 func adminUserDisable(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
 	adminUserProcess(w, r, session, frameParams, func(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams, user *User) {
 		db.Exec("UPDATE users SET status = 'disabled' WHERE id = ?", user.Id)
@@ -357,16 +384,47 @@ func adminPlanUnsetMetadata(w http.ResponseWriter, r *http.Request, session *Ses
 	RedirectMessage(w, r, fmt.Sprintf("/admin/plan/%d", planId), L.Success("plan_updated"))
 }
 
+type AdminDriftParams struct {
+	Frame  FrameParams
+	Drifts []*Drift
+	Token  string
+}
+
+func adminDrift(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	params := AdminDriftParams{}
+	params.Frame = frameParams
+	params.Drifts = driftList()
+	params.Token = CSRFGenerate(session)
+	RenderTemplate(w, "admin", "drift", params)
+}
+
+func adminDriftDismiss(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	driftId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Redirect(w, r, "/admin/drift", 303)
+		return
+	}
+	driftDismiss(driftId)
+	RedirectMessage(w, r, "/admin/drift", L.Success("drift_dismissed"))
+}
+
 type AdminRegionsParams struct {
-	Frame   FrameParams
-	Regions []Region
-	Token   string
+	Frame     FrameParams
+	Regions   []Region
+	Capacity  map[string]*RegionCapacity
+	CanReload bool
+	Token     string
 }
 
 func adminRegions(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
 	params := AdminRegionsParams{}
 	params.Frame = frameParams
 	params.Regions = regionListAll()
+	params.CanReload = interfaceLoader != nil
+	params.Capacity = make(map[string]*RegionCapacity)
+	for _, region := range params.Regions {
+		params.Capacity[region.Region] = regionCapacitySummary(region.Region)
+	}
 	params.Token = CSRFGenerate(session)
 	RenderTemplate(w, "admin", "regions", params)
 }
@@ -381,6 +439,49 @@ func adminRegionDisable(w http.ResponseWriter, r *http.Request, session *Session
 	RedirectMessage(w, r, "/admin/regions", L.Success("region_disabled"))
 }
 
+func adminRegionsReload(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	result, err := ReloadInterfaces()
+	if err != nil {
+		RedirectMessage(w, r, "/admin/regions", L.FormatError(err))
+		return
+	}
+	RedirectMessage(w, r, "/admin/regions", L.Successf(
+		"interfaces_reloaded",
+		strings.Join(result.Regions, ", "),
+		strings.Join(result.PaymentMethods, ", "),
+		strings.Join(result.Removed, ", "),
+		strings.Join(result.Draining, ", "),
+	))
+}
+
+// Sets virtual machine limits from the vm_limit field for the region as a whole, and plan_{id} fields
+// for plans in the region; empty fields remove the limit.
+func adminRegionCapacity(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	region := mux.Vars(r)["region"]
+	limits := make(map[int]int)
+	fields := map[int]string{0: "vm_limit"}
+	for _, plan := range planListRegion(region) {
+		fields[plan.Id] = fmt.Sprintf("plan_%d", plan.Id)
+	}
+	for planId, field := range fields {
+		str := strings.TrimSpace(r.PostFormValue(field))
+		if str == "" {
+			limits[planId] = -1
+			continue
+		}
+		limit, err := strconv.Atoi(str)
+		if err != nil || limit < 0 {
+			RedirectMessage(w, r, "/admin/regions", L.FormattedError("invalid_capacity"))
+			return
+		}
+		limits[planId] = limit
+	}
+	for planId, limit := range limits {
+		regionCapacitySet(region, planId, limit)
+	}
+	RedirectMessage(w, r, "/admin/regions", L.Success("region_capacity_updated"))
+}
+
 type AdminImagesParams struct {
 	Frame   FrameParams
 	Images  []*Image
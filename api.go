@@ -246,6 +246,15 @@ func apiResponse(w http.ResponseWriter, code int, v interface{}) {
 	}
 }
 
+// Writes the error with a distinct status code if the virtual machine is protected, or 400 otherwise.
+func apiVMError(w http.ResponseWriter, err error) {
+	if _, ok := err.(VmProtectedError); ok {
+		http.Error(w, err.Error(), api.STATUS_VM_PROTECTED)
+	} else {
+		http.Error(w, err.Error(), 400)
+	}
+}
+
 func copyVM(src *VirtualMachine, dst *api.VirtualMachine) {
 	dst.Id = src.Id
 	dst.PlanId = src.Plan.Id
@@ -256,6 +265,8 @@ func copyVM(src *VirtualMachine, dst *api.VirtualMachine) {
 	dst.ExternalIP = src.ExternalIP
 	dst.PrivateIP = src.PrivateIP
 	dst.CreatedTime = src.CreatedTime.Unix()
+	dst.Tags = src.Tags
+	dst.Protected = src.Protected
 }
 
 func copyVMDetails(src *VmInfo, dst *api.VirtualMachineDetails) {
@@ -271,6 +282,14 @@ func copyVMDetails(src *VmInfo, dst *api.VirtualMachineDetails) {
 	dst.CanResize = src.CanResize
 	dst.CanSnapshot = src.CanSnapshot
 	dst.CanAddresses = src.CanAddresses
+	dst.CanFirewall = src.CanFirewall
+	dst.CanVolumes = src.CanVolumes
+	dst.CanConsoleLog = src.CanConsoleOutput
+	dst.CanMetrics = src.CanMetrics
+	dst.CanReservedIp = src.CanReservedIps
+	dst.CanNetworks = src.CanNetworks
+	dst.CanRescue = src.CanRescue
+	dst.CanPasswordReset = src.CanPasswordReset
 	for _, srcAction := range src.Actions {
 		dstAction := new(api.VirtualMachineAction)
 		dstAction.Action = srcAction.Action
@@ -285,10 +304,104 @@ func copyVMDetails(src *VmInfo, dst *api.VirtualMachineDetails) {
 func copyAddress(src *IpAddress, dst *api.IpAddress) {
 	dst.Ip = src.Ip
 	dst.PrivateIp = src.PrivateIp
+	dst.Family = src.Family
 	dst.CanRdns = src.CanRdns
 	dst.Hostname = src.Hostname
 }
 
+func copyFirewallRule(src *FirewallRule, dst *api.FirewallRule) {
+	dst.Id = src.Id
+	dst.Protocol = src.Protocol
+	dst.PortMin = src.PortMin
+	dst.PortMax = src.PortMax
+	dst.Cidr = src.Cidr
+}
+
+func copyVolume(src *Volume, dst *api.Volume) {
+	dst.Id = src.Id
+	dst.Region = src.Region
+	dst.Name = src.Name
+	dst.Size = src.Size
+	dst.Status = src.Status
+	dst.VmId = src.VmId
+	dst.CreatedTime = src.CreatedTime.Unix()
+}
+
+func copyReservedIp(src *ReservedIp, dst *api.ReservedIp) {
+	dst.Id = src.Id
+	dst.Region = src.Region
+	dst.Ip = src.Ip
+	dst.VmId = src.VmId
+	dst.CreatedTime = src.CreatedTime.Unix()
+}
+
+func copyNetwork(src *Network, dst *api.Network) {
+	dst.Id = src.Id
+	dst.Region = src.Region
+	dst.Name = src.Name
+	dst.Cidr = src.Cidr
+	dst.VmIds = src.VmIds
+	dst.CreatedTime = src.CreatedTime.Unix()
+}
+
+func copyJob(src *Job, dst *api.Job) {
+	dst.Id = src.Id
+	dst.Kind = src.Kind
+	dst.Status = src.Status
+	dst.Attempts = src.Attempts
+	// error details come from the backend and are only reported to administrators
+	if src.Error != "" {
+		dst.Error = L.T("job_error_hidden")
+	}
+	dst.Progress = src.Progress
+	dst.CreatedTime = src.CreatedTime.Unix()
+	dst.UpdatedTime = src.UpdatedTime.Unix()
+}
+
+func copyBackupSchedule(src *BackupSchedule, dst *api.BackupSchedule) {
+	dst.Id = src.Id
+	dst.Frequency = src.Frequency
+	dst.Hour = src.Hour
+	dst.Weekday = src.Weekday
+	dst.Retention = src.Retention
+	dst.LastTime = src.LastTime.Unix()
+}
+
+func copyPowerSchedule(src *PowerSchedule, dst *api.PowerSchedule) {
+	dst.Id = src.Id
+	dst.Action = src.Action
+	dst.Expression = src.Expression
+	dst.Timezone = src.Timezone
+	dst.LastTime = src.LastTime.Unix()
+	if next := src.NextTime(); !next.IsZero() {
+		dst.NextTime = next.Unix()
+	}
+}
+
+func copyHealthCheck(src *HealthCheck, dst *api.HealthCheck) {
+	dst.Id = src.Id
+	dst.Protocol = src.Protocol
+	dst.Port = src.Port
+	dst.Path = src.Path
+	dst.Threshold = src.Threshold
+	dst.AutoReboot = src.AutoReboot
+	dst.Status = src.Status
+	dst.Failures = src.Failures
+	dst.Message = src.Message
+	if !src.CheckedTime.IsZero() {
+		dst.CheckedTime = src.CheckedTime.Unix()
+	}
+}
+
+func copyVmTransfer(src *VmTransfer, dst *api.VmTransfer) {
+	dst.Id = src.Id
+	dst.VmId = src.VmId
+	dst.VmName = src.VmName
+	dst.FromUsername = src.FromUsername
+	dst.ToUsername = src.ToUsername
+	dst.CreatedTime = src.CreatedTime.Unix()
+}
+
 func copyImage(src *Image, dst *api.Image) {
 	dst.Id = src.Id
 	dst.Region = src.Region
@@ -323,11 +436,19 @@ func copyKey(src *SSHKey, dst *api.Key) {
 	dst.Id = src.ID
 	dst.Name = src.Name
 	dst.Key = src.Key
+	dst.Fingerprint = src.Fingerprint
 }
 
 func apiVMList(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	var vms []*VirtualMachine
+	if r.URL.Query().Get("tag") != "" {
+		vms = vmListTag(userId, r.URL.Query().Get("tag"))
+	} else {
+		vms = vmList(userId)
+	}
+
 	var response api.VMListResponse
-	for _, vm := range vmList(userId) {
+	for _, vm := range vms {
 		vmCopy := new(api.VirtualMachine)
 		copyVM(vm, vmCopy)
 		response.VirtualMachines = append(response.VirtualMachines, vmCopy)
@@ -345,7 +466,8 @@ func apiVMCreate(w http.ResponseWriter, r *http.Request, userId int, requestByte
 	}
 
 	vmId, err := vmCreate(userId, request.Name, request.PlanId, request.ImageId, VmCreateOptions{
-		KeyID: request.KeyId,
+		KeyIDs:   append([]int{request.KeyId}, request.KeyIds...),
+		UserData: request.UserData,
 	})
 	if err != nil {
 		http.Error(w, "Create failed: "+err.Error(), 400)
@@ -367,15 +489,42 @@ func apiVMInfo(w http.ResponseWriter, r *http.Request, userId int, requestBytes
 		return
 	}
 	vm.LoadInfo()
+	vm.LoadTags()
 
 	var response api.VMInfoResponse
 	response.VirtualMachine = new(api.VirtualMachine)
 	response.Details = new(api.VirtualMachineDetails)
 	copyVM(vm, response.VirtualMachine)
+	response.VirtualMachine.Notes = vm.Notes()
 	copyVMDetails(vm.Info, response.Details)
 	apiResponse(w, 201, response)
 }
 
+func apiVMBulk(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	var request api.VMBulkRequest
+	err := json.Unmarshal(requestBytes, &request)
+	if err != nil {
+		http.Error(w, "Invalid json: "+err.Error(), 400)
+		return
+	}
+
+	results, err := vmBulkAction(userId, request.VmIds, request.Action, request.Value)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	var response api.VMBulkResponse
+	for _, result := range results {
+		resultCopy := &api.VMBulkResult{VmId: result.VmId, Success: result.Error == nil}
+		if result.Error != nil {
+			resultCopy.Error = result.Error.Error()
+			_, resultCopy.Protected = result.Error.(VmProtectedError)
+		}
+		response.Results = append(response.Results, resultCopy)
+	}
+	apiResponse(w, 200, &response)
+}
+
 func apiVMAction(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
 	vmId, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
@@ -417,6 +566,19 @@ func apiVMAction(w http.ResponseWriter, r *http.Request, userId int, requestByte
 		if err == nil {
 			response = api.VMSnapshotResponse{Id: imageId}
 		}
+	} else if request.Action == "rescue" {
+		var password string
+		password, err = vm.Rescue()
+		if err == nil {
+			response = api.VMRescueResponse{Password: password}
+		}
+	} else if request.Action == "unrescue" {
+		err = vm.Unrescue()
+	} else if request.Action == "mount_iso" {
+		imageId, _ := strconv.Atoi(request.Value)
+		err = vm.MountISO(imageId)
+	} else if request.Action == "unmount_iso" {
+		err = vm.UnmountISO()
 	} else {
 		err = vm.Action(request.Action, request.Value)
 	}
@@ -447,9 +609,12 @@ func apiVMReimage(w http.ResponseWriter, r *http.Request, userId int, requestByt
 		return
 	}
 
-	err = vmReimage(userId, vm.Id, request.ImageId)
+	err = vmReimage(userId, vm.Id, request.ImageId, VmReimageOptions{
+		KeyIDs:   request.KeyIds,
+		UserData: request.UserData,
+	})
 	if err != nil {
-		http.Error(w, err.Error(), 400)
+		apiVMError(w, err)
 	} else {
 		apiResponse(w, 200, nil)
 	}
@@ -475,6 +640,60 @@ func apiVMResize(w http.ResponseWriter, r *http.Request, userId int, requestByte
 	}
 
 	err = vm.Resize(request.PlanId)
+	if err != nil {
+		apiVMError(w, err)
+	} else {
+		apiResponse(w, 200, nil)
+	}
+}
+
+func apiVMTags(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	vmId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid VM ID", 400)
+		return
+	}
+	vm := vmGetUser(userId, vmId)
+	if vm == nil {
+		http.Error(w, "No virtual machine with that ID", 404)
+		return
+	}
+
+	var request api.VMTagsRequest
+	err = json.Unmarshal(requestBytes, &request)
+	if err != nil {
+		http.Error(w, "Invalid json: "+err.Error(), 400)
+		return
+	}
+
+	err = vm.SetTags(request.Tags)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+	} else {
+		apiResponse(w, 200, nil)
+	}
+}
+
+func apiVMNotes(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	vmId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid VM ID", 400)
+		return
+	}
+	vm := vmGetUser(userId, vmId)
+	if vm == nil {
+		http.Error(w, "No virtual machine with that ID", 404)
+		return
+	}
+
+	var request api.VMNotesRequest
+	err = json.Unmarshal(requestBytes, &request)
+	if err != nil {
+		http.Error(w, "Invalid json: "+err.Error(), 400)
+		return
+	}
+
+	err = vm.SetNotes(request.Notes)
 	if err != nil {
 		http.Error(w, err.Error(), 400)
 	} else {
@@ -482,6 +701,103 @@ func apiVMResize(w http.ResponseWriter, r *http.Request, userId int, requestByte
 	}
 }
 
+func apiVMClone(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	vmId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid VM ID", 400)
+		return
+	}
+	vm := vmGetUser(userId, vmId)
+	if vm == nil {
+		http.Error(w, "No virtual machine with that ID", 404)
+		return
+	}
+
+	var request api.VMCloneRequest
+	err = json.Unmarshal(requestBytes, &request)
+	if err != nil {
+		http.Error(w, "Invalid json: "+err.Error(), 400)
+		return
+	}
+
+	jobId, err := vm.Clone(request.Name, request.PlanId, request.DeleteImage)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+	} else {
+		apiResponse(w, 201, api.VMCloneResponse{JobId: jobId})
+	}
+}
+
+func apiVMPasswordReset(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	vmId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid VM ID", 400)
+		return
+	}
+	vm := vmGetUser(userId, vmId)
+	if vm == nil {
+		http.Error(w, "No virtual machine with that ID", 404)
+		return
+	}
+
+	password, err := vm.ResetPassword(ExtractIP(r.RemoteAddr))
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+	} else {
+		apiResponse(w, 200, api.VMPasswordResetResponse{Password: password})
+	}
+}
+
+func apiVMMigrate(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	vmId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid VM ID", 400)
+		return
+	}
+	vm := vmGetUser(userId, vmId)
+	if vm == nil {
+		http.Error(w, "No virtual machine with that ID", 404)
+		return
+	}
+
+	var request api.VMMigrateRequest
+	err = json.Unmarshal(requestBytes, &request)
+	if err != nil {
+		http.Error(w, "Invalid json: "+err.Error(), 400)
+		return
+	}
+
+	jobId, err := vm.Migrate(request.Region, request.PlanId, request.DeleteSource)
+	if err != nil {
+		apiVMError(w, err)
+	} else {
+		apiResponse(w, 201, api.VMMigrateResponse{JobId: jobId})
+	}
+}
+
+func apiVMProtection(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	vmId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid VM ID", 400)
+		return
+	}
+	vm := vmGetUser(userId, vmId)
+	if vm == nil {
+		http.Error(w, "No virtual machine with that ID", 404)
+		return
+	}
+
+	var request api.VMProtectionRequest
+	err = json.Unmarshal(requestBytes, &request)
+	if err != nil {
+		http.Error(w, "Invalid json: "+err.Error(), 400)
+		return
+	}
+
+	vm.SetProtected(request.Protected)
+	apiResponse(w, 200, nil)
+}
+
 func apiVMDelete(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
 	vmId, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
@@ -496,7 +812,7 @@ func apiVMDelete(w http.ResponseWriter, r *http.Request, userId int, requestByte
 
 	err = vm.Delete(userId)
 	if err != nil {
-		http.Error(w, err.Error(), 400)
+		apiVMError(w, err)
 	} else {
 		apiResponse(w, 204, nil)
 	}
@@ -601,38 +917,515 @@ func apiVMAddressRdns(w http.ResponseWriter, r *http.Request, userId int, reques
 	}
 }
 
-func apiImageList(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
-	var response api.ImageListResponse
-	for _, image := range imageList(userId) {
+func apiVMJobs(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	vmId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid VM ID", 400)
+		return
+	}
+	vm := vmGetUser(userId, int(vmId))
+	if vm == nil {
+		http.Error(w, "No virtual machine with that ID", 404)
+		return
+	}
+
+	var response api.VMJobsResponse
+	for _, job := range jobListVm(vm.Id) {
+		jobCopy := new(api.Job)
+		copyJob(job, jobCopy)
+		response.Jobs = append(response.Jobs, jobCopy)
+	}
+	apiResponse(w, 200, &response)
+}
+
+func apiVMBackups(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	vmId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid VM ID", 400)
+		return
+	}
+	vm := vmGetUser(userId, vmId)
+	if vm == nil {
+		http.Error(w, "No virtual machine with that ID", 404)
+		return
+	}
+
+	var response api.VMBackupsResponse
+	for _, schedule := range backupScheduleList(vm.Id) {
+		scheduleCopy := new(api.BackupSchedule)
+		copyBackupSchedule(schedule, scheduleCopy)
+		response.Schedules = append(response.Schedules, scheduleCopy)
+	}
+	for _, image := range backupImageList(vm.Id) {
 		imageCopy := new(api.Image)
 		copyImage(image, imageCopy)
-		response.Images = append(response.Images, imageCopy)
+		response.Backups = append(response.Backups, imageCopy)
 	}
 	apiResponse(w, 200, &response)
 }
 
-func apiImageFetch(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
-	var request api.ImageFetchRequest
+func apiVMBackupAdd(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	vmId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid VM ID", 400)
+		return
+	}
+	vm := vmGetUser(userId, vmId)
+	if vm == nil {
+		http.Error(w, "No virtual machine with that ID", 404)
+		return
+	}
 
-	err := json.Unmarshal(requestBytes, &request)
+	var request api.VMBackupAddRequest
+	err = json.Unmarshal(requestBytes, &request)
 	if err != nil {
 		http.Error(w, "Invalid json: "+err.Error(), 400)
 		return
 	}
 
-	imageId, err := imageFetch(userId, request.Region, request.Name, request.Url, request.Format)
+	scheduleId, err := vm.AddBackupSchedule(request.Frequency, request.Hour, request.Weekday, request.Retention)
 	if err != nil {
-		http.Error(w, "Fetch failed: "+err.Error(), 400)
-		return
+		http.Error(w, err.Error(), 400)
 	} else {
-		apiResponse(w, 201, api.ImageFetchResponse{Id: imageId})
+		apiResponse(w, 201, api.VMBackupAddResponse{Id: scheduleId})
 	}
 }
 
-func apiImageInfo(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
-	imageId, err := strconv.Atoi(mux.Vars(r)["id"])
+func apiVMBackupRemove(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	vmId, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
-		http.Error(w, "Invalid image ID", 400)
+		http.Error(w, "Invalid VM ID", 400)
+		return
+	}
+	vm := vmGetUser(userId, vmId)
+	if vm == nil {
+		http.Error(w, "No virtual machine with that ID", 404)
+		return
+	}
+	scheduleId, _ := strconv.Atoi(mux.Vars(r)["schedule"])
+
+	err = vm.RemoveBackupSchedule(scheduleId)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+	} else {
+		apiResponse(w, 200, nil)
+	}
+}
+
+func apiVMPowerSchedules(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	vmId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid VM ID", 400)
+		return
+	}
+	vm := vmGetUser(userId, vmId)
+	if vm == nil {
+		http.Error(w, "No virtual machine with that ID", 404)
+		return
+	}
+
+	var response api.VMPowerSchedulesResponse
+	for _, schedule := range powerScheduleList(vm.Id) {
+		scheduleCopy := new(api.PowerSchedule)
+		copyPowerSchedule(schedule, scheduleCopy)
+		response.Schedules = append(response.Schedules, scheduleCopy)
+	}
+	apiResponse(w, 200, &response)
+}
+
+func apiVMPowerScheduleAdd(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	vmId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid VM ID", 400)
+		return
+	}
+	vm := vmGetUser(userId, vmId)
+	if vm == nil {
+		http.Error(w, "No virtual machine with that ID", 404)
+		return
+	}
+
+	var request api.VMPowerScheduleAddRequest
+	err = json.Unmarshal(requestBytes, &request)
+	if err != nil {
+		http.Error(w, "Invalid json: "+err.Error(), 400)
+		return
+	}
+
+	scheduleId, err := vm.AddPowerSchedule(request.Action, request.Expression, request.Timezone)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+	} else {
+		apiResponse(w, 201, api.VMPowerScheduleAddResponse{Id: scheduleId})
+	}
+}
+
+func apiVMPowerScheduleRemove(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	vmId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid VM ID", 400)
+		return
+	}
+	vm := vmGetUser(userId, vmId)
+	if vm == nil {
+		http.Error(w, "No virtual machine with that ID", 404)
+		return
+	}
+	scheduleId, _ := strconv.Atoi(mux.Vars(r)["schedule"])
+
+	err = vm.RemovePowerSchedule(scheduleId)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+	} else {
+		apiResponse(w, 200, nil)
+	}
+}
+
+func apiVMHealthChecks(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	vmId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid VM ID", 400)
+		return
+	}
+	vm := vmGetUser(userId, vmId)
+	if vm == nil {
+		http.Error(w, "No virtual machine with that ID", 404)
+		return
+	}
+
+	var response api.VMHealthChecksResponse
+	for _, check := range healthCheckList(vm.Id) {
+		checkCopy := new(api.HealthCheck)
+		copyHealthCheck(check, checkCopy)
+		response.Checks = append(response.Checks, checkCopy)
+	}
+	apiResponse(w, 200, &response)
+}
+
+func apiVMHealthCheckAdd(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	vmId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid VM ID", 400)
+		return
+	}
+	vm := vmGetUser(userId, vmId)
+	if vm == nil {
+		http.Error(w, "No virtual machine with that ID", 404)
+		return
+	}
+
+	var request api.VMHealthCheckAddRequest
+	err = json.Unmarshal(requestBytes, &request)
+	if err != nil {
+		http.Error(w, "Invalid json: "+err.Error(), 400)
+		return
+	}
+
+	checkId, err := vm.AddHealthCheck(request.Protocol, request.Port, request.Path, request.Threshold, request.AutoReboot)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+	} else {
+		apiResponse(w, 201, api.VMHealthCheckAddResponse{Id: checkId})
+	}
+}
+
+func apiVMHealthCheckRemove(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	vmId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid VM ID", 400)
+		return
+	}
+	vm := vmGetUser(userId, vmId)
+	if vm == nil {
+		http.Error(w, "No virtual machine with that ID", 404)
+		return
+	}
+	checkId, _ := strconv.Atoi(mux.Vars(r)["check"])
+
+	err = vm.RemoveHealthCheck(checkId)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+	} else {
+		apiResponse(w, 200, nil)
+	}
+}
+
+func apiVMTransfer(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	vmId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid VM ID", 400)
+		return
+	}
+	vm := vmGetUser(userId, vmId)
+	if vm == nil {
+		http.Error(w, "No virtual machine with that ID", 404)
+		return
+	}
+
+	var request api.VMTransferRequest
+	err = json.Unmarshal(requestBytes, &request)
+	if err != nil {
+		http.Error(w, "Invalid json: "+err.Error(), 400)
+		return
+	}
+
+	_, err = vm.TransferStart(request.Recipient)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+	} else {
+		apiResponse(w, 200, nil)
+	}
+}
+
+func apiVMTransferCancel(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	vmId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid VM ID", 400)
+		return
+	}
+	vm := vmGetUser(userId, vmId)
+	if vm == nil {
+		http.Error(w, "No virtual machine with that ID", 404)
+		return
+	}
+
+	err = vm.TransferCancel()
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+	} else {
+		apiResponse(w, 200, nil)
+	}
+}
+
+func apiTransferList(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	var response api.TransferListResponse
+	for _, transfer := range vmTransferListIncoming(userId) {
+		transferCopy := new(api.VmTransfer)
+		copyVmTransfer(transfer, transferCopy)
+		response.Transfers = append(response.Transfers, transferCopy)
+	}
+	apiResponse(w, 200, &response)
+}
+
+func apiTransferAccept(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	transferId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid transfer ID", 400)
+		return
+	}
+
+	vmId, err := vmTransferAccept(userId, transferId, ExtractIP(r.RemoteAddr))
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+	} else {
+		apiResponse(w, 200, api.TransferAcceptResponse{VmId: vmId})
+	}
+}
+
+func apiTransferDecline(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	transferId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid transfer ID", 400)
+		return
+	}
+
+	err = vmTransferDecline(userId, transferId)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+	} else {
+		apiResponse(w, 204, nil)
+	}
+}
+
+func apiVMFirewall(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	vmId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid VM ID", 400)
+		return
+	}
+	vm := vmGetUser(userId, vmId)
+	if vm == nil {
+		http.Error(w, "No virtual machine with that ID", 404)
+		return
+	}
+
+	err = vm.LoadFirewallRules()
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	var response api.VMFirewallResponse
+	for _, rule := range vm.FirewallRules {
+		ruleCopy := new(api.FirewallRule)
+		copyFirewallRule(rule, ruleCopy)
+		response.Rules = append(response.Rules, ruleCopy)
+	}
+	apiResponse(w, 200, &response)
+}
+
+func apiVMFirewallAdd(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	vmId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid VM ID", 400)
+		return
+	}
+	vm := vmGetUser(userId, vmId)
+	if vm == nil {
+		http.Error(w, "No virtual machine with that ID", 404)
+		return
+	}
+
+	var request api.VMFirewallAddRequest
+	err = json.Unmarshal(requestBytes, &request)
+	if err != nil {
+		http.Error(w, "Invalid json: "+err.Error(), 400)
+		return
+	}
+
+	err = vm.AddFirewallRule(request.Protocol, request.PortMin, request.PortMax, request.Cidr)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+	} else {
+		apiResponse(w, 201, nil)
+	}
+}
+
+func apiVMFirewallRemove(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	vmId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid VM ID", 400)
+		return
+	}
+	vm := vmGetUser(userId, vmId)
+	if vm == nil {
+		http.Error(w, "No virtual machine with that ID", 404)
+		return
+	}
+
+	err = vm.RemoveFirewallRule(mux.Vars(r)["rule"])
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+	} else {
+		apiResponse(w, 200, nil)
+	}
+}
+
+func apiVMConsoleLog(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	vmId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid VM ID", 400)
+		return
+	}
+	vm := vmGetUser(userId, vmId)
+	if vm == nil {
+		http.Error(w, "No virtual machine with that ID", 404)
+		return
+	}
+
+	// length is in kilobytes and optional
+	kilobytes := 0
+	if r.URL.Query().Get("length") != "" {
+		kilobytes, err = strconv.Atoi(r.URL.Query().Get("length"))
+		if err != nil {
+			http.Error(w, "Invalid length", 400)
+			return
+		}
+	}
+
+	output, err := vm.ConsoleOutput(kilobytes)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+	} else {
+		apiResponse(w, 200, api.VMConsoleLogResponse{Output: output})
+	}
+}
+
+func apiVMMetrics(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	vmId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid VM ID", 400)
+		return
+	}
+	vm := vmGetUser(userId, vmId)
+	if vm == nil {
+		http.Error(w, "No virtual machine with that ID", 404)
+		return
+	}
+
+	// start and end are unix timestamps, defaulting to the last day
+	end := time.Now()
+	start := end.Add(-24 * time.Hour)
+	if r.URL.Query().Get("start") != "" {
+		timestamp, err := strconv.ParseInt(r.URL.Query().Get("start"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid start time", 400)
+			return
+		}
+		start = time.Unix(timestamp, 0)
+	}
+	if r.URL.Query().Get("end") != "" {
+		timestamp, err := strconv.ParseInt(r.URL.Query().Get("end"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid end time", 400)
+			return
+		}
+		end = time.Unix(timestamp, 0)
+	}
+
+	resolution, samples, err := vm.Metrics(start, end)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	response := api.VMMetricsResponse{
+		Resolution: resolution,
+		Samples:    make([]*api.MetricSample, 0),
+	}
+	for _, sample := range samples {
+		response.Samples = append(response.Samples, &api.MetricSample{
+			Time:       sample.Time.Unix(),
+			Cpu:        sample.Cpu,
+			DiskRead:   sample.DiskRead,
+			DiskWrite:  sample.DiskWrite,
+			NetworkIn:  sample.NetworkIn,
+			NetworkOut: sample.NetworkOut,
+		})
+	}
+	apiResponse(w, 200, response)
+}
+
+func apiImageList(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	var response api.ImageListResponse
+	for _, image := range imageList(userId) {
+		imageCopy := new(api.Image)
+		copyImage(image, imageCopy)
+		response.Images = append(response.Images, imageCopy)
+	}
+	apiResponse(w, 200, &response)
+}
+
+func apiImageFetch(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	var request api.ImageFetchRequest
+
+	err := json.Unmarshal(requestBytes, &request)
+	if err != nil {
+		http.Error(w, "Invalid json: "+err.Error(), 400)
+		return
+	}
+
+	imageId, err := imageFetch(userId, request.Region, request.Name, request.Url, request.Format)
+	if err != nil {
+		http.Error(w, "Fetch failed: "+err.Error(), 400)
+		return
+	} else {
+		apiResponse(w, 201, api.ImageFetchResponse{Id: imageId})
+	}
+}
+
+func apiImageInfo(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	imageId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid image ID", 400)
 		return
 	}
 	image := imageInfo(userId, imageId)
@@ -641,22 +1434,431 @@ func apiImageInfo(w http.ResponseWriter, r *http.Request, userId int, requestByt
 		return
 	}
 
-	var response api.ImageInfoResponse
-	response.Image = new(api.Image)
-	response.Details = new(api.ImageDetails)
-	copyImage(image, response.Image)
-	copyImageDetails(image.Info, response.Details)
-	apiResponse(w, 201, response)
+	var response api.ImageInfoResponse
+	response.Image = new(api.Image)
+	response.Details = new(api.ImageDetails)
+	copyImage(image, response.Image)
+	copyImageDetails(image.Info, response.Details)
+	apiResponse(w, 201, response)
+}
+
+func apiImageDelete(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	imageId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid image ID", 400)
+		return
+	}
+
+	err = imageDelete(userId, imageId)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+	} else {
+		apiResponse(w, 204, nil)
+	}
+}
+
+func apiVMVolumes(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	vmId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid VM ID", 400)
+		return
+	}
+	vm := vmGetUser(userId, vmId)
+	if vm == nil {
+		http.Error(w, "No virtual machine with that ID", 404)
+		return
+	}
+
+	var response api.VolumeListResponse
+	for _, volume := range volumeListVm(vm.Id) {
+		volumeCopy := new(api.Volume)
+		copyVolume(volume, volumeCopy)
+		response.Volumes = append(response.Volumes, volumeCopy)
+	}
+	apiResponse(w, 200, &response)
+}
+
+func apiVMVolumeAttach(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	vmId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid VM ID", 400)
+		return
+	}
+	vm := vmGetUser(userId, vmId)
+	if vm == nil {
+		http.Error(w, "No virtual machine with that ID", 404)
+		return
+	}
+
+	var request api.VMVolumeAttachRequest
+	err = json.Unmarshal(requestBytes, &request)
+	if err != nil {
+		http.Error(w, "Invalid json: "+err.Error(), 400)
+		return
+	}
+
+	err = vm.AttachVolume(request.VolumeId)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+	} else {
+		apiResponse(w, 200, nil)
+	}
+}
+
+func apiVMVolumeDetach(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	vmId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid VM ID", 400)
+		return
+	}
+	vm := vmGetUser(userId, vmId)
+	if vm == nil {
+		http.Error(w, "No virtual machine with that ID", 404)
+		return
+	}
+	volumeId, _ := strconv.Atoi(mux.Vars(r)["volume"])
+
+	err = vm.DetachVolume(volumeId)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+	} else {
+		apiResponse(w, 200, nil)
+	}
 }
 
-func apiImageDelete(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
-	imageId, err := strconv.Atoi(mux.Vars(r)["id"])
+func apiVolumeList(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	var response api.VolumeListResponse
+	for _, volume := range volumeList(userId) {
+		volumeCopy := new(api.Volume)
+		copyVolume(volume, volumeCopy)
+		response.Volumes = append(response.Volumes, volumeCopy)
+	}
+	apiResponse(w, 200, &response)
+}
+
+func apiVolumeCreate(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	var request api.VolumeCreateRequest
+
+	err := json.Unmarshal(requestBytes, &request)
 	if err != nil {
-		http.Error(w, "Invalid image ID", 400)
+		http.Error(w, "Invalid json: "+err.Error(), 400)
 		return
 	}
 
-	err = imageDelete(userId, imageId)
+	volumeId, err := volumeCreate(userId, request.Region, request.Name, request.Size)
+	if err != nil {
+		http.Error(w, "Create failed: "+err.Error(), 400)
+	} else {
+		apiResponse(w, 201, api.VolumeCreateResponse{Id: volumeId})
+	}
+}
+
+func apiVolumeInfo(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	volumeId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid volume ID", 400)
+		return
+	}
+	volume := volumeGet(userId, volumeId)
+	if volume == nil {
+		http.Error(w, "No volume with that ID", 404)
+		return
+	}
+
+	var response api.VolumeInfoResponse
+	response.Volume = new(api.Volume)
+	copyVolume(volume, response.Volume)
+	apiResponse(w, 200, response)
+}
+
+func apiVolumeResize(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	volumeId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid volume ID", 400)
+		return
+	}
+
+	var request api.VolumeResizeRequest
+	err = json.Unmarshal(requestBytes, &request)
+	if err != nil {
+		http.Error(w, "Invalid json: "+err.Error(), 400)
+		return
+	}
+
+	err = volumeResize(userId, volumeId, request.Size)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+	} else {
+		apiResponse(w, 200, nil)
+	}
+}
+
+func apiVolumeDelete(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	volumeId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid volume ID", 400)
+		return
+	}
+
+	err = volumeDelete(userId, volumeId)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+	} else {
+		apiResponse(w, 204, nil)
+	}
+}
+
+func apiVMReservedIps(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	vmId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid VM ID", 400)
+		return
+	}
+	vm := vmGetUser(userId, vmId)
+	if vm == nil {
+		http.Error(w, "No virtual machine with that ID", 404)
+		return
+	}
+
+	var response api.ReservedIpListResponse
+	for _, reservedIp := range reservedIpListVm(vm.Id) {
+		reservedIpCopy := new(api.ReservedIp)
+		copyReservedIp(reservedIp, reservedIpCopy)
+		response.ReservedIps = append(response.ReservedIps, reservedIpCopy)
+	}
+	apiResponse(w, 200, &response)
+}
+
+func apiVMReservedIpAttach(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	vmId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid VM ID", 400)
+		return
+	}
+	vm := vmGetUser(userId, vmId)
+	if vm == nil {
+		http.Error(w, "No virtual machine with that ID", 404)
+		return
+	}
+
+	var request api.VMReservedIpAttachRequest
+	err = json.Unmarshal(requestBytes, &request)
+	if err != nil {
+		http.Error(w, "Invalid json: "+err.Error(), 400)
+		return
+	}
+
+	err = vm.AttachReservedIp(request.ReservedIpId)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+	} else {
+		apiResponse(w, 200, nil)
+	}
+}
+
+func apiVMReservedIpDetach(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	vmId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid VM ID", 400)
+		return
+	}
+	vm := vmGetUser(userId, vmId)
+	if vm == nil {
+		http.Error(w, "No virtual machine with that ID", 404)
+		return
+	}
+	reservedIpId, _ := strconv.Atoi(mux.Vars(r)["reserved_ip"])
+
+	err = vm.DetachReservedIp(reservedIpId)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+	} else {
+		apiResponse(w, 200, nil)
+	}
+}
+
+func apiReservedIpList(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	var response api.ReservedIpListResponse
+	for _, reservedIp := range reservedIpList(userId) {
+		reservedIpCopy := new(api.ReservedIp)
+		copyReservedIp(reservedIp, reservedIpCopy)
+		response.ReservedIps = append(response.ReservedIps, reservedIpCopy)
+	}
+	apiResponse(w, 200, &response)
+}
+
+func apiReservedIpCreate(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	var request api.ReservedIpCreateRequest
+
+	err := json.Unmarshal(requestBytes, &request)
+	if err != nil {
+		http.Error(w, "Invalid json: "+err.Error(), 400)
+		return
+	}
+
+	reservedIpId, err := reservedIpCreate(userId, request.Region)
+	if err != nil {
+		http.Error(w, "Create failed: "+err.Error(), 400)
+	} else {
+		apiResponse(w, 201, api.ReservedIpCreateResponse{Id: reservedIpId})
+	}
+}
+
+func apiReservedIpInfo(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	reservedIpId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid reserved IP ID", 400)
+		return
+	}
+	reservedIp := reservedIpGet(userId, reservedIpId)
+	if reservedIp == nil {
+		http.Error(w, "No reserved IP with that ID", 404)
+		return
+	}
+
+	var response api.ReservedIpInfoResponse
+	response.ReservedIp = new(api.ReservedIp)
+	copyReservedIp(reservedIp, response.ReservedIp)
+	apiResponse(w, 200, response)
+}
+
+func apiReservedIpDelete(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	reservedIpId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid reserved IP ID", 400)
+		return
+	}
+
+	err = reservedIpDelete(userId, reservedIpId)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+	} else {
+		apiResponse(w, 204, nil)
+	}
+}
+
+func apiVMNetworks(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	vmId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid VM ID", 400)
+		return
+	}
+	vm := vmGetUser(userId, vmId)
+	if vm == nil {
+		http.Error(w, "No virtual machine with that ID", 404)
+		return
+	}
+
+	var response api.NetworkListResponse
+	for _, network := range networkListVm(vm.Id) {
+		networkCopy := new(api.Network)
+		copyNetwork(network, networkCopy)
+		response.Networks = append(response.Networks, networkCopy)
+	}
+	apiResponse(w, 200, &response)
+}
+
+func apiVMNetworkAttach(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	vmId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid VM ID", 400)
+		return
+	}
+	vm := vmGetUser(userId, vmId)
+	if vm == nil {
+		http.Error(w, "No virtual machine with that ID", 404)
+		return
+	}
+
+	var request api.VMNetworkAttachRequest
+	err = json.Unmarshal(requestBytes, &request)
+	if err != nil {
+		http.Error(w, "Invalid json: "+err.Error(), 400)
+		return
+	}
+
+	err = vm.AttachNetwork(request.NetworkId)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+	} else {
+		apiResponse(w, 200, nil)
+	}
+}
+
+func apiVMNetworkDetach(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	vmId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid VM ID", 400)
+		return
+	}
+	vm := vmGetUser(userId, vmId)
+	if vm == nil {
+		http.Error(w, "No virtual machine with that ID", 404)
+		return
+	}
+	networkId, _ := strconv.Atoi(mux.Vars(r)["network"])
+
+	err = vm.DetachNetwork(networkId)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+	} else {
+		apiResponse(w, 200, nil)
+	}
+}
+
+func apiNetworkList(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	var response api.NetworkListResponse
+	for _, network := range networkList(userId) {
+		networkCopy := new(api.Network)
+		copyNetwork(network, networkCopy)
+		response.Networks = append(response.Networks, networkCopy)
+	}
+	apiResponse(w, 200, &response)
+}
+
+func apiNetworkCreate(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	var request api.NetworkCreateRequest
+
+	err := json.Unmarshal(requestBytes, &request)
+	if err != nil {
+		http.Error(w, "Invalid json: "+err.Error(), 400)
+		return
+	}
+
+	networkId, err := networkCreate(userId, request.Region, request.Name, request.Cidr)
+	if err != nil {
+		http.Error(w, "Create failed: "+err.Error(), 400)
+	} else {
+		apiResponse(w, 201, api.NetworkCreateResponse{Id: networkId})
+	}
+}
+
+func apiNetworkInfo(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	networkId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid network ID", 400)
+		return
+	}
+	network := networkGet(userId, networkId)
+	if network == nil {
+		http.Error(w, "No network with that ID", 404)
+		return
+	}
+
+	var response api.NetworkInfoResponse
+	response.Network = new(api.Network)
+	copyNetwork(network, response.Network)
+	apiResponse(w, 200, response)
+}
+
+func apiNetworkDelete(w http.ResponseWriter, r *http.Request, userId int, requestBytes []byte) {
+	networkId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid network ID", 400)
+		return
+	}
+
+	err = networkDelete(userId, networkId)
 	if err != nil {
 		http.Error(w, err.Error(), 400)
 	} else {
@@ -10,8 +10,16 @@ import "fmt"
 import "io"
 import "io/ioutil"
 import "net/http"
+import "net/url"
+import "strings"
 import "time"
 
+// HTTP status code of errors caused by an operation on a protected virtual machine.
+const STATUS_VM_PROTECTED = 423
+
+// Returned by the client when the virtual machine must be unprotected before the operation.
+var ErrVmProtected = errors.New("the virtual machine is protected")
+
 type Client struct {
 	Url    string
 	ApiId  string
@@ -30,10 +38,10 @@ func (this *Client) request(method string, path string, requestObj interface{},
 		body = bytes.NewBuffer(requestBytes)
 	}
 
-	// signature is hmac_{apikey}(path|nonce|request)
+	// signature is hmac_{apikey}(path|nonce|request), where path excludes the query string
 	nonce := time.Now().UnixNano()
 	mac := hmac.New(sha512.New, []byte(this.ApiKey))
-	toSign := fmt.Sprintf("%s|%d|%s", path, nonce, string(requestBytes))
+	toSign := fmt.Sprintf("%s|%d|%s", strings.Split(path, "?")[0], nonce, string(requestBytes))
 	mac.Write([]byte(toSign))
 	signature := hex.EncodeToString(mac.Sum(nil))
 
@@ -51,6 +59,8 @@ func (this *Client) request(method string, path string, requestObj interface{},
 	responseBytes, err := ioutil.ReadAll(response.Body)
 	if err != nil {
 		return err
+	} else if response.StatusCode == STATUS_VM_PROTECTED {
+		return ErrVmProtected
 	} else if response.StatusCode < 200 || response.StatusCode > 204 {
 		return errors.New(string(responseBytes))
 	}
@@ -75,8 +85,23 @@ func (this *Client) VmList() ([]*VirtualMachine, error) {
 	}
 }
 
+// Returns the virtual machines that have the tag.
+func (this *Client) VmListTag(tag string) ([]*VirtualMachine, error) {
+	var response VMListResponse
+	err := this.request("GET", "vms?tag="+url.QueryEscape(tag), nil, &response)
+	if err != nil {
+		return nil, err
+	} else {
+		return response.VirtualMachines, nil
+	}
+}
+
 type VmCreateOptions struct {
+	// Deprecated: use KeyIds; if set, the key is authorized along with KeyIds.
 	KeyId int
+
+	KeyIds   []int
+	UserData string
 }
 
 func (this *Client) VmCreate(name string, planId int, imageId int, options *VmCreateOptions) (int, error) {
@@ -88,6 +113,8 @@ func (this *Client) VmCreate(name string, planId int, imageId int, options *VmCr
 
 	if options != nil {
 		request.KeyId = options.KeyId
+		request.KeyIds = options.KeyIds
+		request.UserData = options.UserData
 	}
 
 	var response VMCreateResponse
@@ -117,6 +144,22 @@ func (this *Client) VmAction(vmId int, action string, value string) error {
 	return this.request("POST", fmt.Sprintf("vms/%d/action", vmId), request, nil)
 }
 
+// Performs the action on each of the virtual machines, and returns the per-VM results.
+func (this *Client) VmBulk(vmIds []int, action string, value string) ([]*VMBulkResult, error) {
+	request := VMBulkRequest{
+		VmIds:  vmIds,
+		Action: action,
+		Value:  value,
+	}
+	var response VMBulkResponse
+	err := this.request("POST", "vms/bulk", request, &response)
+	if err != nil {
+		return nil, err
+	} else {
+		return response.Results, nil
+	}
+}
+
 func (this *Client) VmVnc(vmId int) (string, error) {
 	request := VMActionRequest{
 		Action: "vnc",
@@ -144,10 +187,57 @@ func (this *Client) VmSnapshot(vmId int, name string) (int, error) {
 	}
 }
 
-func (this *Client) VmReimage(vmId int, imageId int) error {
+// Boots the virtual machine into rescue mode, returning the rescue password if one was set.
+func (this *Client) VmRescue(vmId int) (string, error) {
+	request := VMActionRequest{
+		Action: "rescue",
+	}
+	var response VMRescueResponse
+	err := this.request("POST", fmt.Sprintf("vms/%d/action", vmId), request, &response)
+	if err != nil {
+		return "", err
+	} else {
+		return response.Password, nil
+	}
+}
+
+func (this *Client) VmUnrescue(vmId int) error {
+	return this.VmAction(vmId, "unrescue", "")
+}
+
+func (this *Client) VmMountISO(vmId int, imageId int) error {
+	return this.VmAction(vmId, "mount_iso", fmt.Sprintf("%d", imageId))
+}
+
+func (this *Client) VmUnmountISO(vmId int) error {
+	return this.VmAction(vmId, "unmount_iso", "")
+}
+
+// Resets the root password of the virtual machine, returning the new password.
+func (this *Client) VmPasswordReset(vmId int) (string, error) {
+	var response VMPasswordResetResponse
+	err := this.request("POST", fmt.Sprintf("vms/%d/password_reset", vmId), nil, &response)
+	if err != nil {
+		return "", err
+	} else {
+		return response.Password, nil
+	}
+}
+
+type VmReimageOptions struct {
+	KeyIds   []int
+	UserData string
+}
+
+// options may be nil.
+func (this *Client) VmReimage(vmId int, imageId int, options *VmReimageOptions) error {
 	request := VMReimageRequest{
 		ImageId: imageId,
 	}
+	if options != nil {
+		request.KeyIds = options.KeyIds
+		request.UserData = options.UserData
+	}
 	return this.request("POST", fmt.Sprintf("vms/%d/reimage", vmId), request, nil)
 }
 
@@ -158,6 +248,63 @@ func (this *Client) VmResize(vmId int, planId int) error {
 	return this.request("POST", fmt.Sprintf("vms/%d/resize", vmId), request, nil)
 }
 
+// Replaces the tags on the virtual machine.
+func (this *Client) VmSetTags(vmId int, tags []string) error {
+	request := VMTagsRequest{
+		Tags: tags,
+	}
+	return this.request("POST", fmt.Sprintf("vms/%d/tags", vmId), request, nil)
+}
+
+func (this *Client) VmSetNotes(vmId int, notes string) error {
+	request := VMNotesRequest{
+		Notes: notes,
+	}
+	return this.request("POST", fmt.Sprintf("vms/%d/notes", vmId), request, nil)
+}
+
+// Starts cloning the virtual machine into a new virtual machine, returning the ID of the clone job.
+// The plan can be zero to use the same plan as the source.
+func (this *Client) VmClone(vmId int, name string, planId int, deleteImage bool) (int, error) {
+	request := VMCloneRequest{
+		Name:        name,
+		PlanId:      planId,
+		DeleteImage: deleteImage,
+	}
+	var response VMCloneResponse
+	err := this.request("POST", fmt.Sprintf("vms/%d/clone", vmId), request, &response)
+	if err != nil {
+		return 0, err
+	} else {
+		return response.JobId, nil
+	}
+}
+
+// Starts migrating the virtual machine to another region, returning the ID of the migration job.
+// The plan can be zero to keep the current plan.
+func (this *Client) VmMigrate(vmId int, region string, planId int, deleteSource bool) (int, error) {
+	request := VMMigrateRequest{
+		Region:       region,
+		PlanId:       planId,
+		DeleteSource: deleteSource,
+	}
+	var response VMMigrateResponse
+	err := this.request("POST", fmt.Sprintf("vms/%d/migrate", vmId), request, &response)
+	if err != nil {
+		return 0, err
+	} else {
+		return response.JobId, nil
+	}
+}
+
+// Sets whether the virtual machine is protected from deletion, re-image, and resize.
+func (this *Client) VmSetProtected(vmId int, protected bool) error {
+	request := VMProtectionRequest{
+		Protected: protected,
+	}
+	return this.request("POST", fmt.Sprintf("vms/%d/protection", vmId), request, nil)
+}
+
 func (this *Client) VmDelete(vmId int) error {
 	return this.request("DELETE", fmt.Sprintf("vms/%d", vmId), nil, nil)
 }
@@ -191,6 +338,197 @@ func (this *Client) VmAddressRdns(vmId int, ip string, hostname string) error {
 	return this.request("POST", fmt.Sprintf("vms/%d/ips/%s/rdns", vmId, ip), request, nil)
 }
 
+func (this *Client) VmJobs(vmId int) ([]*Job, error) {
+	var response VMJobsResponse
+	err := this.request("GET", fmt.Sprintf("vms/%d/jobs", vmId), nil, &response)
+	if err != nil {
+		return nil, err
+	} else {
+		return response.Jobs, nil
+	}
+}
+
+func (this *Client) VmBackups(vmId int) (*VMBackupsResponse, error) {
+	var response VMBackupsResponse
+	err := this.request("GET", fmt.Sprintf("vms/%d/backups", vmId), nil, &response)
+	if err != nil {
+		return nil, err
+	} else {
+		return &response, nil
+	}
+}
+
+// Adds a backup schedule and returns its ID; weekday is ignored for daily schedules.
+func (this *Client) VmBackupAdd(vmId int, frequency string, hour int, weekday int, retention int) (int, error) {
+	request := VMBackupAddRequest{
+		Frequency: frequency,
+		Hour:      hour,
+		Weekday:   weekday,
+		Retention: retention,
+	}
+	var response VMBackupAddResponse
+	err := this.request("POST", fmt.Sprintf("vms/%d/backups", vmId), request, &response)
+	if err != nil {
+		return 0, err
+	} else {
+		return response.Id, nil
+	}
+}
+
+func (this *Client) VmBackupRemove(vmId int, scheduleId int) error {
+	return this.request("DELETE", fmt.Sprintf("vms/%d/backups/%d", vmId, scheduleId), nil, nil)
+}
+
+func (this *Client) VmPowerSchedules(vmId int) ([]*PowerSchedule, error) {
+	var response VMPowerSchedulesResponse
+	err := this.request("GET", fmt.Sprintf("vms/%d/power_schedules", vmId), nil, &response)
+	if err != nil {
+		return nil, err
+	} else {
+		return response.Schedules, nil
+	}
+}
+
+// Adds a power schedule and returns its ID.
+// Action is start, stop, or reboot; expression is a five-field cron expression evaluated in timezone (default UTC).
+func (this *Client) VmPowerScheduleAdd(vmId int, action string, expression string, timezone string) (int, error) {
+	request := VMPowerScheduleAddRequest{
+		Action:     action,
+		Expression: expression,
+		Timezone:   timezone,
+	}
+	var response VMPowerScheduleAddResponse
+	err := this.request("POST", fmt.Sprintf("vms/%d/power_schedules", vmId), request, &response)
+	if err != nil {
+		return 0, err
+	} else {
+		return response.Id, nil
+	}
+}
+
+func (this *Client) VmPowerScheduleRemove(vmId int, scheduleId int) error {
+	return this.request("DELETE", fmt.Sprintf("vms/%d/power_schedules/%d", vmId, scheduleId), nil, nil)
+}
+
+func (this *Client) VmHealthChecks(vmId int) ([]*HealthCheck, error) {
+	var response VMHealthChecksResponse
+	err := this.request("GET", fmt.Sprintf("vms/%d/health_checks", vmId), nil, &response)
+	if err != nil {
+		return nil, err
+	} else {
+		return response.Checks, nil
+	}
+}
+
+// Adds a health check and returns its ID.
+// Protocol is tcp or http; path is only used for http checks and defaults to /.
+func (this *Client) VmHealthCheckAdd(vmId int, protocol string, port int, path string, threshold int, autoReboot bool) (int, error) {
+	request := VMHealthCheckAddRequest{
+		Protocol:   protocol,
+		Port:       port,
+		Path:       path,
+		Threshold:  threshold,
+		AutoReboot: autoReboot,
+	}
+	var response VMHealthCheckAddResponse
+	err := this.request("POST", fmt.Sprintf("vms/%d/health_checks", vmId), request, &response)
+	if err != nil {
+		return 0, err
+	} else {
+		return response.Id, nil
+	}
+}
+
+func (this *Client) VmHealthCheckRemove(vmId int, checkId int) error {
+	return this.request("DELETE", fmt.Sprintf("vms/%d/health_checks/%d", vmId, checkId), nil, nil)
+}
+
+// Starts a transfer of the virtual machine to the account with the given username or email address.
+// The virtual machine moves once the recipient accepts the transfer with TransferAccept.
+func (this *Client) VmTransfer(vmId int, recipient string) error {
+	request := VMTransferRequest{
+		Recipient: recipient,
+	}
+	return this.request("POST", fmt.Sprintf("vms/%d/transfer", vmId), request, nil)
+}
+
+func (this *Client) VmTransferCancel(vmId int) error {
+	return this.request("DELETE", fmt.Sprintf("vms/%d/transfer", vmId), nil, nil)
+}
+
+// Returns the pending transfers to this account.
+func (this *Client) TransferList() ([]*VmTransfer, error) {
+	var response TransferListResponse
+	err := this.request("GET", "transfers", nil, &response)
+	if err != nil {
+		return nil, err
+	} else {
+		return response.Transfers, nil
+	}
+}
+
+// Accepts the transfer and returns the ID of the virtual machine.
+func (this *Client) TransferAccept(transferId int) (int, error) {
+	var response TransferAcceptResponse
+	err := this.request("POST", fmt.Sprintf("transfers/%d/accept", transferId), nil, &response)
+	if err != nil {
+		return 0, err
+	} else {
+		return response.VmId, nil
+	}
+}
+
+func (this *Client) TransferDecline(transferId int) error {
+	return this.request("DELETE", fmt.Sprintf("transfers/%d", transferId), nil, nil)
+}
+
+func (this *Client) VmFirewall(vmId int) ([]*FirewallRule, error) {
+	var response VMFirewallResponse
+	err := this.request("GET", fmt.Sprintf("vms/%d/firewall", vmId), nil, &response)
+	if err != nil {
+		return nil, err
+	} else {
+		return response.Rules, nil
+	}
+}
+
+// Adds an inbound firewall rule; ports are ignored for icmp, and an empty cidr allows any source.
+func (this *Client) VmFirewallAdd(vmId int, protocol string, portMin int, portMax int, cidr string) error {
+	request := VMFirewallAddRequest{
+		Protocol: protocol,
+		PortMin:  portMin,
+		PortMax:  portMax,
+		Cidr:     cidr,
+	}
+	return this.request("POST", fmt.Sprintf("vms/%d/firewall", vmId), request, nil)
+}
+
+func (this *Client) VmFirewallRemove(vmId int, ruleId string) error {
+	return this.request("DELETE", fmt.Sprintf("vms/%d/firewall/%s", vmId, url.PathEscape(ruleId)), nil, nil)
+}
+
+// Returns up to the last kilobytes KB of serial console output, or a default amount if kilobytes is zero.
+func (this *Client) VmConsoleLog(vmId int, kilobytes int) (string, error) {
+	var response VMConsoleLogResponse
+	err := this.request("GET", fmt.Sprintf("vms/%d/console-log?length=%d", vmId, kilobytes), nil, &response)
+	if err != nil {
+		return "", err
+	} else {
+		return response.Output, nil
+	}
+}
+
+// Returns the resolution in seconds and the resource usage samples of the virtual machine between start and end.
+func (this *Client) VmMetrics(vmId int, start time.Time, end time.Time) (int, []*MetricSample, error) {
+	var response VMMetricsResponse
+	err := this.request("GET", fmt.Sprintf("vms/%d/metrics?start=%d&end=%d", vmId, start.Unix(), end.Unix()), nil, &response)
+	if err != nil {
+		return 0, nil, err
+	} else {
+		return response.Resolution, response.Samples, nil
+	}
+}
+
 func (this *Client) ImageList() ([]*Image, error) {
 	var response ImageListResponse
 	err := this.request("GET", "images", nil, &response)
@@ -231,6 +569,195 @@ func (this *Client) ImageDelete(imageId int) error {
 	return this.request("DELETE", fmt.Sprintf("images/%d", imageId), nil, nil)
 }
 
+func (this *Client) VmVolumes(vmId int) ([]*Volume, error) {
+	var response VolumeListResponse
+	err := this.request("GET", fmt.Sprintf("vms/%d/volumes", vmId), nil, &response)
+	if err != nil {
+		return nil, err
+	} else {
+		return response.Volumes, nil
+	}
+}
+
+func (this *Client) VmVolumeAttach(vmId int, volumeId int) error {
+	request := VMVolumeAttachRequest{
+		VolumeId: volumeId,
+	}
+	return this.request("POST", fmt.Sprintf("vms/%d/volumes", vmId), request, nil)
+}
+
+func (this *Client) VmVolumeDetach(vmId int, volumeId int) error {
+	return this.request("DELETE", fmt.Sprintf("vms/%d/volumes/%d", vmId, volumeId), nil, nil)
+}
+
+func (this *Client) VolumeList() ([]*Volume, error) {
+	var response VolumeListResponse
+	err := this.request("GET", "volumes", nil, &response)
+	if err != nil {
+		return nil, err
+	} else {
+		return response.Volumes, nil
+	}
+}
+
+// Creates a volume of the given size in GB and returns its ID.
+func (this *Client) VolumeCreate(region string, name string, size int) (int, error) {
+	request := VolumeCreateRequest{
+		Region: region,
+		Name:   name,
+		Size:   size,
+	}
+	var response VolumeCreateResponse
+	err := this.request("POST", "volumes", request, &response)
+	if err != nil {
+		return 0, err
+	} else {
+		return response.Id, nil
+	}
+}
+
+func (this *Client) VolumeInfo(volumeId int) (*Volume, error) {
+	var response VolumeInfoResponse
+	err := this.request("GET", fmt.Sprintf("volumes/%d", volumeId), nil, &response)
+	if err != nil {
+		return nil, err
+	} else {
+		return response.Volume, nil
+	}
+}
+
+func (this *Client) VolumeResize(volumeId int, size int) error {
+	request := VolumeResizeRequest{
+		Size: size,
+	}
+	return this.request("POST", fmt.Sprintf("volumes/%d/resize", volumeId), request, nil)
+}
+
+func (this *Client) VolumeDelete(volumeId int) error {
+	return this.request("DELETE", fmt.Sprintf("volumes/%d", volumeId), nil, nil)
+}
+
+func (this *Client) VmReservedIps(vmId int) ([]*ReservedIp, error) {
+	var response ReservedIpListResponse
+	err := this.request("GET", fmt.Sprintf("vms/%d/reserved_ips", vmId), nil, &response)
+	if err != nil {
+		return nil, err
+	} else {
+		return response.ReservedIps, nil
+	}
+}
+
+func (this *Client) VmReservedIpAttach(vmId int, reservedIpId int) error {
+	request := VMReservedIpAttachRequest{
+		ReservedIpId: reservedIpId,
+	}
+	return this.request("POST", fmt.Sprintf("vms/%d/reserved_ips", vmId), request, nil)
+}
+
+func (this *Client) VmReservedIpDetach(vmId int, reservedIpId int) error {
+	return this.request("DELETE", fmt.Sprintf("vms/%d/reserved_ips/%d", vmId, reservedIpId), nil, nil)
+}
+
+func (this *Client) ReservedIpList() ([]*ReservedIp, error) {
+	var response ReservedIpListResponse
+	err := this.request("GET", "reserved_ips", nil, &response)
+	if err != nil {
+		return nil, err
+	} else {
+		return response.ReservedIps, nil
+	}
+}
+
+// Allocates a reserved IP in the region and returns its ID.
+func (this *Client) ReservedIpCreate(region string) (int, error) {
+	request := ReservedIpCreateRequest{
+		Region: region,
+	}
+	var response ReservedIpCreateResponse
+	err := this.request("POST", "reserved_ips", request, &response)
+	if err != nil {
+		return 0, err
+	} else {
+		return response.Id, nil
+	}
+}
+
+func (this *Client) ReservedIpInfo(reservedIpId int) (*ReservedIp, error) {
+	var response ReservedIpInfoResponse
+	err := this.request("GET", fmt.Sprintf("reserved_ips/%d", reservedIpId), nil, &response)
+	if err != nil {
+		return nil, err
+	} else {
+		return response.ReservedIp, nil
+	}
+}
+
+func (this *Client) ReservedIpDelete(reservedIpId int) error {
+	return this.request("DELETE", fmt.Sprintf("reserved_ips/%d", reservedIpId), nil, nil)
+}
+
+// Returns the networks that the virtual machine is attached to.
+func (this *Client) VmNetworks(vmId int) ([]*Network, error) {
+	var response NetworkListResponse
+	err := this.request("GET", fmt.Sprintf("vms/%d/networks", vmId), nil, &response)
+	if err != nil {
+		return nil, err
+	} else {
+		return response.Networks, nil
+	}
+}
+
+func (this *Client) VmNetworkAttach(vmId int, networkId int) error {
+	request := VMNetworkAttachRequest{
+		NetworkId: networkId,
+	}
+	return this.request("POST", fmt.Sprintf("vms/%d/networks", vmId), request, nil)
+}
+
+func (this *Client) VmNetworkDetach(vmId int, networkId int) error {
+	return this.request("DELETE", fmt.Sprintf("vms/%d/networks/%d", vmId, networkId), nil, nil)
+}
+
+func (this *Client) NetworkList() ([]*Network, error) {
+	var response NetworkListResponse
+	err := this.request("GET", "networks", nil, &response)
+	if err != nil {
+		return nil, err
+	} else {
+		return response.Networks, nil
+	}
+}
+
+// Creates a private network with the IPv4 subnet and returns its ID.
+func (this *Client) NetworkCreate(region string, name string, cidr string) (int, error) {
+	request := NetworkCreateRequest{
+		Region: region,
+		Name:   name,
+		Cidr:   cidr,
+	}
+	var response NetworkCreateResponse
+	err := this.request("POST", "networks", request, &response)
+	if err != nil {
+		return 0, err
+	} else {
+		return response.Id, nil
+	}
+}
+
+func (this *Client) NetworkInfo(networkId int) (*Network, error) {
+	var response NetworkInfoResponse
+	err := this.request("GET", fmt.Sprintf("networks/%d", networkId), nil, &response)
+	if err != nil {
+		return nil, err
+	} else {
+		return response.Network, nil
+	}
+}
+
+func (this *Client) NetworkDelete(networkId int) error {
+	return this.request("DELETE", fmt.Sprintf("networks/%d", networkId), nil, nil)
+}
+
 func (this *Client) PlanList() ([]*Plan, error) {
 	var response PlanListResponse
 	err := this.request("GET", "plans", nil, &response)
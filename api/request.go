@@ -5,6 +5,12 @@ type VMCreateRequest struct {
 	PlanId  int    `json:"plan_id"`
 	ImageId int    `json:"image_id"`
 	KeyId   int    `json:"key_id"`
+
+	// additional keys, authorized along with KeyId
+	KeyIds []int `json:"key_ids"`
+
+	// cloud-init user-data
+	UserData string `json:"user_data"`
 }
 
 type VMActionRequest struct {
@@ -13,7 +19,74 @@ type VMActionRequest struct {
 }
 
 type VMReimageRequest struct {
-	ImageId int `json:"image_id"`
+	ImageId  int    `json:"image_id"`
+	KeyIds   []int  `json:"key_ids"`
+	UserData string `json:"user_data"`
+}
+
+type VMBackupAddRequest struct {
+	Frequency string `json:"frequency"`
+	Hour      int    `json:"hour"`
+	Weekday   int    `json:"weekday"`
+	Retention int    `json:"retention"`
+}
+
+type VMPowerScheduleAddRequest struct {
+	Action     string `json:"action"`
+	Expression string `json:"expression"`
+	Timezone   string `json:"timezone"`
+}
+
+type VMHealthCheckAddRequest struct {
+	Protocol   string `json:"protocol"`
+	Port       int    `json:"port"`
+	Path       string `json:"path"`
+	Threshold  int    `json:"threshold"`
+	AutoReboot bool   `json:"auto_reboot"`
+}
+
+// Action is start, stop, reboot, delete, tag, or resize.
+// Value is a comma-separated list of tags to add for tag, and the plan ID for resize.
+type VMBulkRequest struct {
+	VmIds  []int  `json:"vm_ids"`
+	Action string `json:"action"`
+	Value  string `json:"value"`
+}
+
+// Recipient is the username or email address of the account to transfer to.
+type VMTransferRequest struct {
+	Recipient string `json:"recipient"`
+}
+
+type VMProtectionRequest struct {
+	Protected bool `json:"protected"`
+}
+
+type VMFirewallAddRequest struct {
+	Protocol string `json:"protocol"`
+	PortMin  int    `json:"port_min"`
+	PortMax  int    `json:"port_max"`
+	Cidr     string `json:"cidr"`
+}
+
+type VMTagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+type VMNotesRequest struct {
+	Notes string `json:"notes"`
+}
+
+type VMCloneRequest struct {
+	Name        string `json:"name"`
+	PlanId      int    `json:"plan_id"`
+	DeleteImage bool   `json:"delete_image"`
+}
+
+type VMMigrateRequest struct {
+	Region       string `json:"region"`
+	PlanId       int    `json:"plan_id"`
+	DeleteSource bool   `json:"delete_source"`
 }
 
 type VMResizeRequest struct {
@@ -36,6 +109,38 @@ type ImageFetchRequest struct {
 	Format string `json:"format"`
 }
 
+type VolumeCreateRequest struct {
+	Region string `json:"region"`
+	Name   string `json:"name"`
+	Size   int    `json:"size"`
+}
+
+type VolumeResizeRequest struct {
+	Size int `json:"size"`
+}
+
+type VMVolumeAttachRequest struct {
+	VolumeId int `json:"volume_id"`
+}
+
+type ReservedIpCreateRequest struct {
+	Region string `json:"region"`
+}
+
+type VMReservedIpAttachRequest struct {
+	ReservedIpId int `json:"reserved_ip_id"`
+}
+
+type NetworkCreateRequest struct {
+	Region string `json:"region"`
+	Name   string `json:"name"`
+	Cidr   string `json:"cidr"`
+}
+
+type VMNetworkAttachRequest struct {
+	NetworkId int `json:"network_id"`
+}
+
 type KeyAddRequest struct {
 	Name string `json:"name"`
 	Key  string `json:"key"`
@@ -4,13 +4,16 @@ type VirtualMachine struct {
 	Id     int `json:"id"`
 	PlanId int `json:"plan_id"`
 
-	Region      string `json:"region"`
-	Name        string `json:"name"`
-	Status      string `json:"status"`
-	TaskPending bool   `json:"task_pending"`
-	ExternalIP  string `json:"external_ip"`
-	PrivateIP   string `json:"private_ip"`
-	CreatedTime int64  `json:"created_time"`
+	Region      string   `json:"region"`
+	Name        string   `json:"name"`
+	Status      string   `json:"status"`
+	TaskPending bool     `json:"task_pending"`
+	ExternalIP  string   `json:"external_ip"`
+	PrivateIP   string   `json:"private_ip"`
+	CreatedTime int64    `json:"created_time"`
+	Tags        []string `json:"tags"`
+	Protected   bool     `json:"protected"`
+	Notes       string   `json:"notes,omitempty"` // only set in VMInfoResponse
 }
 
 type VirtualMachineAction struct {
@@ -22,28 +25,96 @@ type VirtualMachineAction struct {
 }
 
 type VirtualMachineDetails struct {
-	Ip            string                  `json:"ip"`
-	PrivateIp     string                  `json:"private_ip"`
-	Status        string                  `json:"status"`
-	Hostname      string                  `json:"hostname"`
-	BandwidthUsed int64                   `json:"bandwidth_used"`
-	LoginDetails  string                  `json:"login_details"`
-	Details       map[string]string       `json:"details"`
-	Actions       []*VirtualMachineAction `json:"actions"`
-	CanVnc        bool                    `json:"can_vnc"`
-	CanReimage    bool                    `json:"can_reimage"`
-	CanResize     bool                    `json:"can_resize"`
-	CanSnapshot   bool                    `json:"can_snapshot"`
-	CanAddresses  bool                    `json:"can_addresses"`
+	Ip               string                  `json:"ip"`
+	PrivateIp        string                  `json:"private_ip"`
+	Status           string                  `json:"status"`
+	Hostname         string                  `json:"hostname"`
+	BandwidthUsed    int64                   `json:"bandwidth_used"`
+	LoginDetails     string                  `json:"login_details"`
+	Details          map[string]string       `json:"details"`
+	Actions          []*VirtualMachineAction `json:"actions"`
+	CanVnc           bool                    `json:"can_vnc"`
+	CanReimage       bool                    `json:"can_reimage"`
+	CanResize        bool                    `json:"can_resize"`
+	CanSnapshot      bool                    `json:"can_snapshot"`
+	CanAddresses     bool                    `json:"can_addresses"`
+	CanFirewall      bool                    `json:"can_firewall"`
+	CanVolumes       bool                    `json:"can_volumes"`
+	CanConsoleLog    bool                    `json:"can_console_log"`
+	CanMetrics       bool                    `json:"can_metrics"`
+	CanReservedIp    bool                    `json:"can_reserved_ips"`
+	CanNetworks      bool                    `json:"can_networks"`
+	CanRescue        bool                    `json:"can_rescue"`
+	CanPasswordReset bool                    `json:"can_password_reset"`
 }
 
 type IpAddress struct {
 	Ip        string `json:"ip"`
 	PrivateIp string `json:"private_ip"`
+	Family    string `json:"family"`
 	CanRdns   bool   `json:"can_rdns"`
 	Hostname  string `json:"hostname"`
 }
 
+type FirewallRule struct {
+	Id       string `json:"id"`
+	Protocol string `json:"protocol"`
+	PortMin  int    `json:"port_min"`
+	PortMax  int    `json:"port_max"`
+	Cidr     string `json:"cidr"`
+}
+
+type Job struct {
+	Id          int    `json:"id"`
+	Kind        string `json:"kind"`
+	Status      string `json:"status"`
+	Attempts    int    `json:"attempts"`
+	Error       string `json:"error"`
+	Progress    string `json:"progress,omitempty"`
+	CreatedTime int64  `json:"created_time"`
+	UpdatedTime int64  `json:"updated_time"`
+}
+
+type BackupSchedule struct {
+	Id        int    `json:"id"`
+	Frequency string `json:"frequency"`
+	Hour      int    `json:"hour"`
+	Weekday   int    `json:"weekday"`
+	Retention int    `json:"retention"`
+	LastTime  int64  `json:"last_time"`
+}
+
+type PowerSchedule struct {
+	Id         int    `json:"id"`
+	Action     string `json:"action"`
+	Expression string `json:"expression"`
+	Timezone   string `json:"timezone"`
+	LastTime   int64  `json:"last_time"`
+	NextTime   int64  `json:"next_time"`
+}
+
+type HealthCheck struct {
+	Id          int    `json:"id"`
+	Protocol    string `json:"protocol"`
+	Port        int    `json:"port"`
+	Path        string `json:"path"`
+	Threshold   int    `json:"threshold"`
+	AutoReboot  bool   `json:"auto_reboot"`
+	Status      string `json:"status"`
+	Failures    int    `json:"failures"`
+	Message     string `json:"message"`
+	CheckedTime int64  `json:"checked_time"`
+}
+
+type VmTransfer struct {
+	Id           int    `json:"id"`
+	VmId         int    `json:"vm_id"`
+	VmName       string `json:"vm_name"`
+	FromUsername string `json:"from_username"`
+	ToUsername   string `json:"to_username"`
+	CreatedTime  int64  `json:"created_time"`
+}
+
 type Image struct {
 	Id     int    `json:"id"`
 	Region string `json:"region"`
@@ -57,6 +128,33 @@ type ImageDetails struct {
 	Details map[string]string `json:"details"`
 }
 
+type Volume struct {
+	Id          int    `json:"id"`
+	Region      string `json:"region"`
+	Name        string `json:"name"`
+	Size        int    `json:"size"`
+	Status      string `json:"status"`
+	VmId        int    `json:"vm_id"`
+	CreatedTime int64  `json:"created_time"`
+}
+
+type ReservedIp struct {
+	Id          int    `json:"id"`
+	Region      string `json:"region"`
+	Ip          string `json:"ip"`
+	VmId        int    `json:"vm_id"`
+	CreatedTime int64  `json:"created_time"`
+}
+
+type Network struct {
+	Id          int    `json:"id"`
+	Region      string `json:"region"`
+	Name        string `json:"name"`
+	Cidr        string `json:"cidr"`
+	VmIds       []int  `json:"vm_ids,omitempty"`
+	CreatedTime int64  `json:"created_time"`
+}
+
 type Plan struct {
 	Id        int    `json:"id"`
 	Name      string `json:"name"`
@@ -68,9 +166,10 @@ type Plan struct {
 }
 
 type Key struct {
-	Id   int    `json:"id"`
-	Name string `json:"name"`
-	Key  string `json:"key"`
+	Id          int    `json:"id"`
+	Name        string `json:"name"`
+	Key         string `json:"key"`
+	Fingerprint string `json:"fingerprint"`
 }
 
 // responses
@@ -96,10 +195,98 @@ type VMSnapshotResponse struct {
 	Id int `json:"id"`
 }
 
+type VMRescueResponse struct {
+	Password string `json:"password"`
+}
+
+type VMCloneResponse struct {
+	JobId int `json:"job_id"`
+}
+
+type VMPasswordResetResponse struct {
+	Password string `json:"password"`
+}
+
+type VMMigrateResponse struct {
+	JobId int `json:"job_id"`
+}
+
 type VMAddressesResponse struct {
 	Addresses []*IpAddress `json:"addresses"`
 }
 
+type VMFirewallResponse struct {
+	Rules []*FirewallRule `json:"rules"`
+}
+
+type VMConsoleLogResponse struct {
+	Output string `json:"output"`
+}
+
+// Time is a unix timestamp marking the start of the interval that the sample averages over.
+// Cpu is in percent, and the disk and network fields are in bytes per second.
+type MetricSample struct {
+	Time       int64   `json:"time"`
+	Cpu        float64 `json:"cpu"`
+	DiskRead   float64 `json:"disk_read"`
+	DiskWrite  float64 `json:"disk_write"`
+	NetworkIn  float64 `json:"network_in"`
+	NetworkOut float64 `json:"network_out"`
+}
+
+type VMMetricsResponse struct {
+	Resolution int             `json:"resolution"`
+	Samples    []*MetricSample `json:"samples"`
+}
+
+type VMJobsResponse struct {
+	Jobs []*Job `json:"jobs"`
+}
+
+type VMBackupsResponse struct {
+	Schedules []*BackupSchedule `json:"schedules"`
+	Backups   []*Image          `json:"backups"`
+}
+
+type VMBackupAddResponse struct {
+	Id int `json:"id"`
+}
+
+type VMPowerSchedulesResponse struct {
+	Schedules []*PowerSchedule `json:"schedules"`
+}
+
+type VMPowerScheduleAddResponse struct {
+	Id int `json:"id"`
+}
+
+type VMHealthChecksResponse struct {
+	Checks []*HealthCheck `json:"checks"`
+}
+
+type VMHealthCheckAddResponse struct {
+	Id int `json:"id"`
+}
+
+type VMBulkResult struct {
+	VmId      int    `json:"vm_id"`
+	Success   bool   `json:"success"`
+	Error     string `json:"error,omitempty"`
+	Protected bool   `json:"protected,omitempty"` // whether the action failed because the virtual machine is protected
+}
+
+type VMBulkResponse struct {
+	Results []*VMBulkResult `json:"results"`
+}
+
+type TransferListResponse struct {
+	Transfers []*VmTransfer `json:"transfers"`
+}
+
+type TransferAcceptResponse struct {
+	VmId int `json:"vm_id"`
+}
+
 type ImageListResponse struct {
 	Images []*Image `json:"images"`
 }
@@ -113,6 +300,42 @@ type ImageInfoResponse struct {
 	Details *ImageDetails `json:"details"`
 }
 
+type VolumeListResponse struct {
+	Volumes []*Volume `json:"volumes"`
+}
+
+type VolumeCreateResponse struct {
+	Id int `json:"id"`
+}
+
+type VolumeInfoResponse struct {
+	Volume *Volume `json:"volume"`
+}
+
+type ReservedIpListResponse struct {
+	ReservedIps []*ReservedIp `json:"reserved_ips"`
+}
+
+type ReservedIpCreateResponse struct {
+	Id int `json:"id"`
+}
+
+type ReservedIpInfoResponse struct {
+	ReservedIp *ReservedIp `json:"reserved_ip"`
+}
+
+type NetworkListResponse struct {
+	Networks []*Network `json:"networks"`
+}
+
+type NetworkCreateResponse struct {
+	Id int `json:"id"`
+}
+
+type NetworkInfoResponse struct {
+	Network *Network `json:"network"`
+}
+
 type PlanListResponse struct {
 	Plans []*Plan `json:"plans"`
 }
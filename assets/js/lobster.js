@@ -44,4 +44,19 @@ $(document).ready(function() {
 			form.submit();
 		});
 	});
+
+	// bulk virtual machine actions
+	$('#vm_bulk_all').change(function() {
+		$('.vm_bulk_select').prop('checked', $(this).prop('checked'));
+	});
+	$('#vm_bulk_action').change(function() {
+		$('.vm_bulk_value').hide();
+		$('#vm_bulk_' + $(this).val()).show();
+	});
+	$('#vm_bulk_form').submit(function() {
+		if($('#vm_bulk_action').val() == 'delete') {
+			return window.confirm($(this).data('confirmation'));
+		}
+		return true;
+	});
 });
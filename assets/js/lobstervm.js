@@ -90,6 +90,37 @@ function vmStatusUpdate(ttl) {
 	}
 }
 
+// the new password is only kept in the result modal, and cleared when it is closed
+function passwordReset(button) {
+	vmPerform('POST', '/password_reset', null, 'json', function(data) {
+		$('#modalPasswordReset').modal('hide');
+		$('#modal_password_value').text(data.password);
+		$('#modalPasswordResult').modal('show');
+		$('#modalPasswordResult').one('hidden.bs.modal', function() {
+			$('#modal_password_value').text('');
+		});
+		messageUpdate('success', 'The root password has been reset.');
+	}, false, button);
+}
+
+// the page is reloaded after rescue so that the rescue buttons match the new state
+function vmRescue(button) {
+	vmPerform('POST', '/action', {'action': 'rescue'}, 'json', function(data) {
+		$('#modalRescue').modal('hide');
+		if(data.password) {
+			$('#modal_rescue_password_value').text(data.password);
+			$('#modalRescueResult').modal('show');
+			$('#modalRescueResult').one('hidden.bs.modal', function() {
+				$('#modal_rescue_password_value').text('');
+				location.reload();
+			});
+		} else {
+			location.reload();
+		}
+		messageUpdate('success', 'The virtual machine is booting into rescue mode.');
+	}, false, button);
+}
+
 function reloadAddresses() {
 	$('#vm_addresses_table').html('<center><img src="/assets/img/loading.gif"></center>');
 	vmPerform('GET', '/ips', null, 'json', function(data) {
@@ -100,6 +131,7 @@ function reloadAddresses() {
 			h = '<table class="table table-striped">';
 			h += '<tr>';
 			h += '<th>External IP</th>';
+			h += '<th>Family</th>';
 			if(showPrivate) h += '<th>Private IP</th>';
 			if(showHostname) h += '<th>Hostname</th>';
 			h += '<th>Action</th>';
@@ -113,6 +145,11 @@ function reloadAddresses() {
 				} else {
 					h += '<td>None</td>';
 				}
+				if(ip.family == 'ipv6') {
+					h += '<td>IPv6</td>';
+				} else {
+					h += '<td>IPv4</td>';
+				}
 				if(showPrivate) {
 					if(ip.private_ip) {
 						h += '<td>' + escapehtml(ip.private_ip) + '</td>';
@@ -148,18 +185,100 @@ function addressRemove(button, ip, privateIp) {
 function showSetRdns(ip, hostname) {
 	$('#modal_rdns_ip').text(ip);
 	$('#modal_rdns_hostname').val(hostname);
-	$('#modal_rdns_form').attr('action', '/panel/vms/' + $("#js_id").html() + '/ips/' + escapehtml(ip) + '/rdns');
+	$('#modal_rdns_form').attr('action', '/panel/vms/' + $("#js_id").html() + '/ips/' + encodeURIComponent(ip) + '/rdns');
 	$('#modalRdns').modal('show');
 }
 
 function setRdns(button) {
-	vmPerform('POST', '/ips/' + $('#modal_rdns_ip').html() + '/rdns', {'hostname': $('#modal_rdns_hostname').val()}, 'text', function(){}, false, button);
+	vmPerform('POST', '/ips/' + encodeURIComponent($('#modal_rdns_ip').text()) + '/rdns', {'hostname': $('#modal_rdns_hostname').val()}, 'text', function(){}, false, button);
 	$('#modalRdns').modal('hide');
 	reloadAddresses();
 }
 
+function reloadConsoleLog(button) {
+	vmPerform('GET', '/console-log', null, 'json', function(data) {
+		$('#vm_console_output').text(data['output']);
+		$('#vm_console_output').scrollTop($('#vm_console_output')[0].scrollHeight);
+	}, false, button);
+}
+
+// draws series (arrays of values, one per element of times) on the canvas as line graphs
+function drawGraph(canvasId, times, series, colors) {
+	var canvas = document.getElementById(canvasId);
+	var ctx = canvas.getContext('2d');
+	var padLeft = 60, padBottom = 20;
+	var width = canvas.width - padLeft, height = canvas.height - padBottom;
+	ctx.clearRect(0, 0, canvas.width, canvas.height);
+
+	var max = 0;
+	for(var i = 0; i < series.length; i++) {
+		for(var j = 0; j < series[i].length; j++) {
+			max = Math.max(max, series[i][j]);
+		}
+	}
+	if(max == 0) max = 1;
+	var minTime = times[0], maxTime = times[times.length - 1];
+	if(maxTime == minTime) maxTime = minTime + 1;
+
+	// axes and labels
+	ctx.strokeStyle = '#cccccc';
+	ctx.fillStyle = '#666666';
+	ctx.font = '11px sans-serif';
+	ctx.beginPath();
+	ctx.moveTo(padLeft, 0);
+	ctx.lineTo(padLeft, height);
+	ctx.lineTo(canvas.width, height);
+	ctx.stroke();
+	ctx.fillText(max.toFixed(1), 2, 10);
+	ctx.fillText('0', 2, height);
+	ctx.fillText(new Date(minTime * 1000).toLocaleString(), padLeft, canvas.height - 4);
+	var endLabel = new Date(maxTime * 1000).toLocaleString();
+	ctx.fillText(endLabel, canvas.width - ctx.measureText(endLabel).width, canvas.height - 4);
+
+	for(var i = 0; i < series.length; i++) {
+		ctx.strokeStyle = colors[i];
+		ctx.beginPath();
+		for(var j = 0; j < series[i].length; j++) {
+			var x = padLeft + (times[j] - minTime) / (maxTime - minTime) * width;
+			var y = height - series[i][j] / max * height;
+			if(j == 0) ctx.moveTo(x, y);
+			else ctx.lineTo(x, y);
+		}
+		ctx.stroke();
+	}
+}
+
+function reloadMetrics() {
+	var end = Math.floor(Date.now() / 1000);
+	var start = end - parseInt($('#vm_metrics_range').val());
+	vmPerform('GET', '/metrics?start=' + start + '&end=' + end, null, 'json', function(data) {
+		var samples = data['samples'];
+		if(samples.length == 0) {
+			$('#vm_metrics_empty').show();
+		} else {
+			$('#vm_metrics_empty').hide();
+		}
+		var times = [], cpu = [], diskRead = [], diskWrite = [], netIn = [], netOut = [];
+		for(var i = 0; i < samples.length; i++) {
+			times.push(samples[i].time);
+			cpu.push(samples[i].cpu);
+			diskRead.push(samples[i].disk_read / 1024);
+			diskWrite.push(samples[i].disk_write / 1024);
+			netIn.push(samples[i].network_in / 1024);
+			netOut.push(samples[i].network_out / 1024);
+		}
+		drawGraph('vm_metrics_cpu', times, [cpu], ['#337ab7']);
+		drawGraph('vm_metrics_disk', times, [diskRead, diskWrite], ['#337ab7', '#d9534f']);
+		drawGraph('vm_metrics_network', times, [netIn, netOut], ['#337ab7', '#d9534f']);
+	}, true);
+}
+
 $('a[data-toggle="tab"]').on('show.bs.tab', function (e) {
 	if((e.target + "").split("#")[1] == 'vm_addresses') {
 		reloadAddresses();
+	} else if((e.target + "").split("#")[1] == 'vm_console_log') {
+		reloadConsoleLog();
+	} else if((e.target + "").split("#")[1] == 'vm_metrics') {
+		reloadMetrics();
 	}
 });
@@ -0,0 +1,162 @@
+package lobster
+
+import "fmt"
+import "log"
+import "time"
+
+// database objects
+
+type BackupSchedule struct {
+	Id        int
+	VmId      int
+	Frequency string // "daily" or "weekly"
+	Hour      int    // hour of the day (UTC) to take the backup
+	Weekday   int    // for weekly schedules, day of the week (0 is Sunday)
+	Retention int    // number of backups to keep
+	LastTime  time.Time
+}
+
+func backupScheduleListHelper(rows Rows) []*BackupSchedule {
+	defer rows.Close()
+	schedules := make([]*BackupSchedule, 0)
+	for rows.Next() {
+		schedule := BackupSchedule{}
+		rows.Scan(&schedule.Id, &schedule.VmId, &schedule.Frequency, &schedule.Hour, &schedule.Weekday, &schedule.Retention, &schedule.LastTime)
+		schedules = append(schedules, &schedule)
+	}
+	return schedules
+}
+
+const BACKUP_SCHEDULE_QUERY = "SELECT id, vm_id, frequency, hour, weekday, retention, time_last FROM backup_schedules"
+
+func backupScheduleList(vmId int) []*BackupSchedule {
+	return backupScheduleListHelper(db.Query(BACKUP_SCHEDULE_QUERY+" WHERE vm_id = ? ORDER BY id", vmId))
+}
+
+func backupScheduleListAll() []*BackupSchedule {
+	return backupScheduleListHelper(db.Query(BACKUP_SCHEDULE_QUERY + " ORDER BY id"))
+}
+
+// Returns the images that were created by backup schedules of the virtual machine.
+func backupImageList(vmId int) []*Image {
+	return imageListHelper(db.Query(IMAGE_QUERY+" WHERE source_vm = ? AND backup_schedule != 0 ORDER BY id DESC", vmId))
+}
+
+// Returns the most recent time, at or before now, when the schedule should have run.
+func (schedule *BackupSchedule) lastOccurrence(now time.Time) time.Time {
+	now = now.UTC()
+	t := time.Date(now.Year(), now.Month(), now.Day(), schedule.Hour, 0, 0, 0, time.UTC)
+	if t.After(now) {
+		t = t.AddDate(0, 0, -1)
+	}
+	if schedule.Frequency == "weekly" {
+		for int(t.Weekday()) != schedule.Weekday {
+			t = t.AddDate(0, 0, -1)
+		}
+	}
+	return t
+}
+
+func (schedule *BackupSchedule) Due(now time.Time) bool {
+	return schedule.LastTime.Before(schedule.lastOccurrence(now))
+}
+
+func (vm *VirtualMachine) AddBackupSchedule(frequency string, hour int, weekday int, retention int) (int, error) {
+	if frequency != "daily" && frequency != "weekly" {
+		return 0, L.Error("invalid_backup_frequency")
+	} else if hour < 0 || hour > 23 {
+		return 0, L.Error("invalid_backup_hour")
+	} else if frequency == "weekly" && (weekday < 0 || weekday > 6) {
+		return 0, L.Error("invalid_backup_weekday")
+	} else if retention < 1 || retention > MAX_BACKUP_RETENTION {
+		return 0, L.Errorf("invalid_backup_retention", MAX_BACKUP_RETENTION)
+	} else if len(backupScheduleList(vm.Id)) >= MAX_BACKUP_SCHEDULES {
+		return 0, L.Errorf("backup_schedule_limit", MAX_BACKUP_SCHEDULES)
+	}
+
+	// backups are taken with snapshots, and rotated by deleting the images
+	vmi := vmGetInterface(vm.Region)
+	_, canSnapshot := vmi.(VMISnapshot)
+	_, canImages := vmi.(VMIImages)
+	if !canSnapshot || !canImages {
+		return 0, L.Error("vm_backup_unsupported")
+	}
+
+	if frequency == "daily" {
+		weekday = 0
+	}
+
+	// set time_last so that the first backup is taken at the next scheduled time
+	// times are written from Go in UTC since they are compared with time.Now in Due
+	log.Printf("vmAddBackupSchedule(%d, %s, %d, %d, %d)", vm.Id, frequency, hour, weekday, retention)
+	result := db.Exec(
+		"INSERT INTO backup_schedules (vm_id, frequency, hour, weekday, retention, time_last) VALUES (?, ?, ?, ?, ?, ?)",
+		vm.Id, frequency, hour, weekday, retention, time.Now().UTC(),
+	)
+	return result.LastInsertId(), nil
+}
+
+// Removes a backup schedule; backups that were already taken are kept as regular images.
+func (vm *VirtualMachine) RemoveBackupSchedule(scheduleId int) error {
+	result := db.Exec("DELETE FROM backup_schedules WHERE id = ? AND vm_id = ?", scheduleId, vm.Id)
+	if result.RowsAffected() != 1 {
+		return L.Error("invalid_backup_schedule")
+	}
+	db.Exec("UPDATE images SET backup_schedule = 0 WHERE backup_schedule = ?", scheduleId)
+	return nil
+}
+
+// Takes backups for schedules that are due, and deletes backups exceeding the retention.
+// Called from cron; backups are stored as user images, so they are billed through serviceBilling.
+func backupCron() {
+	now := time.Now()
+	for _, schedule := range backupScheduleListAll() {
+		if !schedule.Due(now) {
+			continue
+		}
+
+		vm := vmGet(schedule.VmId)
+		if vm == nil {
+			db.Exec("DELETE FROM backup_schedules WHERE id = ?", schedule.Id)
+			continue
+		} else if vm.TaskPending {
+			// try again on the next run
+			continue
+		}
+
+		// this backup is skipped if it fails, or if the VM is suspended
+		db.Exec("UPDATE backup_schedules SET time_last = ? WHERE id = ?", now.UTC(), schedule.Id)
+		if vm.Suspended != "no" {
+			continue
+		}
+
+		name := vm.Name
+		if len(name) > MAX_VM_NAME_LENGTH-24 {
+			name = name[:MAX_VM_NAME_LENGTH-24]
+		}
+		name = fmt.Sprintf("%s %s %s", name, schedule.Frequency, now.UTC().Format("2006-01-02 15:04"))
+
+		imageId, err := vm.snapshot(name)
+		if err != nil {
+			ReportError(err, "scheduled backup failed", fmt.Sprintf("vm_id=%d, schedule_id=%d", vm.Id, schedule.Id))
+			continue
+		}
+		db.Exec("UPDATE images SET backup_schedule = ? WHERE id = ?", schedule.Id, imageId)
+		backupRotate(vm, schedule)
+	}
+}
+
+// Deletes the oldest backups of the schedule beyond its retention.
+// Pending images are counted but not deleted, since the snapshot may still be in progress.
+func backupRotate(vm *VirtualMachine, schedule *BackupSchedule) {
+	images := imageListHelper(db.Query(IMAGE_QUERY+" WHERE backup_schedule = ? ORDER BY id DESC", schedule.Id))
+	for i, image := range images {
+		if i < schedule.Retention || image.Status == "pending" {
+			continue
+		}
+		err := imageDelete(vm.UserId, image.Id)
+		if err != nil {
+			ReportError(err, "failed to delete old backup", fmt.Sprintf("vm_id=%d, schedule_id=%d, image_id=%d", vm.Id, schedule.Id, image.Id))
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package lobster
+
+import "testing"
+import "time"
+
+func TestBackupScheduleDue(t *testing.T) {
+	// Wednesday
+	now := time.Date(2016, time.March, 2, 10, 30, 0, 0, time.UTC)
+
+	daily := &BackupSchedule{Frequency: "daily", Hour: 3, LastTime: time.Date(2016, time.March, 1, 3, 0, 0, 0, time.UTC)}
+	if !daily.Due(now) {
+		t.Fatal("Daily backup not due after scheduled hour")
+	}
+	daily.LastTime = time.Date(2016, time.March, 2, 3, 0, 5, 0, time.UTC)
+	if daily.Due(now) {
+		t.Fatal("Daily backup due after it already ran today")
+	}
+
+	daily.Hour = 12
+	daily.LastTime = time.Date(2016, time.March, 1, 12, 0, 5, 0, time.UTC)
+	if daily.Due(now) {
+		t.Fatal("Daily backup due before scheduled hour")
+	}
+
+	// weekly on Monday
+	weekly := &BackupSchedule{Frequency: "weekly", Hour: 3, Weekday: 1, LastTime: time.Date(2016, time.February, 25, 0, 0, 0, 0, time.UTC)}
+	if !weekly.Due(now) {
+		t.Fatal("Weekly backup not due after missing scheduled day")
+	}
+	weekly.LastTime = time.Date(2016, time.February, 29, 3, 0, 5, 0, time.UTC)
+	if weekly.Due(now) {
+		t.Fatal("Weekly backup due after it already ran this week")
+	}
+}
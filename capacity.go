@@ -0,0 +1,149 @@
+package lobster
+
+import "fmt"
+import "log"
+import "sync"
+import "time"
+
+// Capacity limits stop plans from being offered in a region once the backend is full.
+// Administrators can limit the number of virtual machines in a region, and of each plan in a region,
+// in the region_capacity table (plan_id zero for the region as a whole); a limit of zero means sold out.
+// Backends implementing VMICapacity additionally report their free resources.
+
+// Virtual machine limits of a region and its plans, for the admin regions page.
+type RegionCapacity struct {
+	Region  string
+	VmCount int
+	Limit   int // -1 if unlimited
+	Plans   []*PlanCapacity
+}
+
+type PlanCapacity struct {
+	Plan    *Plan
+	VmCount int
+	Limit   int // -1 if unlimited
+}
+
+type capacityCacheEntry struct {
+	info *VMICapacityInfo
+	time time.Time
+}
+
+var capacityCache map[string]capacityCacheEntry = make(map[string]capacityCacheEntry)
+var capacityCacheMutex sync.Mutex
+
+// Returns the virtual machine limits of the region, by plan ID, with zero for the region as a whole.
+func regionCapacityLimits(region string) map[int]int {
+	limits := make(map[int]int)
+	rows := db.Query("SELECT plan_id, vm_limit FROM region_capacity WHERE region = ?", region)
+	defer rows.Close()
+	for rows.Next() {
+		var planId, limit int
+		rows.Scan(&planId, &limit)
+		limits[planId] = limit
+	}
+	return limits
+}
+
+// Sets the virtual machine limit of the region (if planId is zero) or of the plan in the region.
+// A negative limit removes it.
+func regionCapacitySet(region string, planId int, limit int) {
+	log.Printf("regionCapacitySet(%s, %d, %d)", region, planId, limit)
+	db.Exec("DELETE FROM region_capacity WHERE region = ? AND plan_id = ?", region, planId)
+	if limit >= 0 {
+		db.Exec("INSERT INTO region_capacity (region, plan_id, vm_limit) VALUES (?, ?, ?)", region, planId, limit)
+	}
+}
+
+func regionCapacitySummary(region string) *RegionCapacity {
+	limits := regionCapacityLimits(region)
+	summary := &RegionCapacity{Region: region, Limit: -1}
+	if limit, ok := limits[0]; ok {
+		summary.Limit = limit
+	}
+	db.QueryRow("SELECT COUNT(*) FROM vms WHERE region = ?", region).Scan(&summary.VmCount)
+	for _, plan := range planListRegion(region) {
+		planCapacity := &PlanCapacity{Plan: plan, Limit: -1}
+		if limit, ok := limits[plan.Id]; ok {
+			planCapacity.Limit = limit
+		}
+		db.QueryRow("SELECT COUNT(*) FROM vms WHERE region = ? AND plan_id = ?", region, plan.Id).Scan(&planCapacity.VmCount)
+		summary.Plans = append(summary.Plans, planCapacity)
+	}
+	return summary
+}
+
+// Returns the free resources reported by the region's backend, or nil if they are unknown.
+func regionCapacityInfo(region string) *VMICapacityInfo {
+	vmi, ok := regionInterface(region).(VMICapacity)
+	if !ok {
+		return nil
+	}
+
+	capacityCacheMutex.Lock()
+	entry, ok := capacityCache[region]
+	capacityCacheMutex.Unlock()
+	if ok && time.Since(entry.time) < CAPACITY_CACHE_TIME*time.Second {
+		return entry.info
+	}
+
+	info, err := vmi.Capacity()
+	if err != nil {
+		// don't stop selling plans because the backend could not report its capacity
+		ReportError(err, "failed to get region capacity", fmt.Sprintf("region: %s", region))
+		info = nil
+	}
+	capacityCacheMutex.Lock()
+	capacityCache[region] = capacityCacheEntry{info: info, time: time.Now()}
+	capacityCacheMutex.Unlock()
+	return info
+}
+
+// Returns whether the requested amount fits in the free amount, which is negative if unknown.
+func capacityFits(free int, amount int) bool {
+	return free < 0 || amount <= 0 || amount <= free
+}
+
+// Checks that a virtual machine on the plan can be provisioned in the region.
+// For resizes, from is the current plan; the region limit then does not apply, and only the
+// additional resources must fit on the backend.
+func regionCapacityCheck(region string, plan *Plan, from *Plan) error {
+	limits := regionCapacityLimits(region)
+	if limit, ok := limits[0]; ok && from == nil {
+		var count int
+		db.QueryRow("SELECT COUNT(*) FROM vms WHERE region = ?", region).Scan(&count)
+		if count >= limit {
+			return L.Error("region_sold_out")
+		}
+	}
+	if limit, ok := limits[plan.Id]; ok {
+		var count int
+		db.QueryRow("SELECT COUNT(*) FROM vms WHERE region = ? AND plan_id = ?", region, plan.Id).Scan(&count)
+		if count >= limit {
+			return L.Errorf("plan_sold_out", plan.Name)
+		}
+	}
+
+	info := regionCapacityInfo(region)
+	if info != nil {
+		ram, cpu, storage := plan.Ram, plan.Cpu, plan.Storage
+		if from != nil {
+			ram, cpu, storage = ram-from.Ram, cpu-from.Cpu, storage-from.Storage
+		}
+		if !capacityFits(info.Ram, ram) || !capacityFits(info.Cpu, cpu) || !capacityFits(info.Storage, storage) {
+			return L.Errorf("plan_sold_out", plan.Name)
+		}
+	}
+	return nil
+}
+
+// Returns the IDs of the plans that cannot currently be provisioned in the region.
+func regionSoldOutPlans(region string, plans []*Plan) map[int]bool {
+	soldOut := make(map[int]bool)
+	for _, plan := range plans {
+		if regionCapacityCheck(region, plan, nil) != nil {
+			soldOut[plan.Id] = true
+		}
+	}
+	return soldOut
+}
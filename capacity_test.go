@@ -0,0 +1,63 @@
+package lobster
+
+import "testing"
+
+type testCapacityVmi struct {
+	TestVmi
+	info *VMICapacityInfo
+}
+
+func (this *testCapacityVmi) Capacity() (*VMICapacityInfo, error) {
+	return this.info, nil
+}
+
+func TestCapacityFits(t *testing.T) {
+	if !capacityFits(-1, 1024) {
+		t.Fatal("Expected unknown free amount to fit")
+	} else if !capacityFits(0, 0) || !capacityFits(0, -512) {
+		t.Fatal("Expected no additional amount to fit")
+	} else if !capacityFits(1024, 1024) {
+		t.Fatal("Expected exact amount to fit")
+	} else if capacityFits(512, 1024) {
+		t.Fatal("Expected larger amount to not fit")
+	}
+}
+
+func TestRegionCapacityCheck(t *testing.T) {
+	TestReset()
+	vmi := &testCapacityVmi{info: &VMICapacityInfo{Ram: 1024, Cpu: -1, Storage: -1}}
+	defer TestRegion("testcapacity", vmi)()
+	delete(capacityCache, "testcapacity")
+	userId := TestUser()
+	vmId := TestVm(userId)
+	db.Exec("UPDATE vms SET region = 'testcapacity' WHERE id = ?", vmId)
+	plan := vmGet(vmId).Plan
+	large := &Plan{Id: plan.Id + 1, Name: "large", Ram: 2048}
+
+	if err := regionCapacityCheck("testcapacity", &plan, nil); err != nil {
+		t.Fatalf("Capacity check failed without limits: %v", err)
+	} else if err := regionCapacityCheck("testcapacity", large, nil); err == nil {
+		t.Fatal("Capacity check passed for plan larger than free resources")
+	} else if err := regionCapacityCheck("testcapacity", large, &plan); err != nil {
+		t.Fatalf("Capacity check failed for resize within free resources: %v", err)
+	}
+
+	// the plan limit is reached by the existing virtual machine
+	regionCapacitySet("testcapacity", plan.Id, 1)
+	if err := regionCapacityCheck("testcapacity", &plan, nil); err == nil {
+		t.Fatal("Capacity check passed for sold out plan")
+	}
+	regionCapacitySet("testcapacity", plan.Id, -1)
+
+	// the region limit does not apply to resizes
+	regionCapacitySet("testcapacity", 0, 1)
+	if err := regionCapacityCheck("testcapacity", &plan, nil); err == nil {
+		t.Fatal("Capacity check passed for full region")
+	} else if err := regionCapacityCheck("testcapacity", &plan, &plan); err != nil {
+		t.Fatalf("Capacity check failed for resize in full region: %v", err)
+	}
+	soldOut := regionSoldOutPlans("testcapacity", []*Plan{&plan})
+	if !soldOut[plan.Id] {
+		t.Fatal("Expected plan to be sold out in full region")
+	}
+}
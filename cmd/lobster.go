@@ -21,6 +21,7 @@ import "github.com/LunaNode/lobster/payment/paypal"
 import "github.com/LunaNode/lobster/payment/stripe"
 
 import "encoding/json"
+import "fmt"
 import "io/ioutil"
 import "log"
 import "os"
@@ -52,6 +53,9 @@ type VmConfig struct {
 	Password string `json:"password"`
 	Tenant   string `json:"tenant"`
 
+	// floating IP pool for reserved IPs (used by openstack)
+	ReservedIpPool string `json:"reserved_ip_pool"`
+
 	// cloudstack options
 	SecretKey string `json:"secret_key"`
 	ZoneID    string `json:"zone_id"`
@@ -94,33 +98,33 @@ type HelperConfig struct {
 	Vm []interface{} `json:"vm"`
 }
 
-func main() {
-	cfgPath := "lobster.cfg"
-	if len(os.Args) >= 2 {
-		cfgPath = os.Args[1]
-	}
-	lobster.Setup(cfgPath)
-
-	// associate core
-	support.Setup()
-
-	// load json configuration
-	jsonPath := cfgPath + ".json"
+// Builds the VM and payment interfaces from the json configuration file.
+// This is called again when the interfaces are reloaded, so it must not exit on errors.
+func loadInterfaces(jsonPath string) (map[string]lobster.VmInterface, map[string]lobster.PaymentInterface, error) {
 	jsonConfigBytes, err := ioutil.ReadFile(jsonPath)
 	if err != nil {
-		log.Fatalf("Error: failed to read json configuration file %s: %s", jsonPath, err.Error())
+		return nil, nil, fmt.Errorf("failed to read json configuration file %s: %s", jsonPath, err.Error())
 	}
 	var jsonConfig JSONConfig
 	err = json.Unmarshal(jsonConfigBytes, &jsonConfig)
 	if err != nil {
-		log.Fatalf("Error: failed to parse json configuration: %s", err.Error())
+		return nil, nil, fmt.Errorf("failed to parse json configuration: %s", err.Error())
 	}
 
+	vmis := make(map[string]lobster.VmInterface)
 	for i, vm := range jsonConfig.Vm {
 		log.Printf("Initializing VM interface %s (type=%s)", vm.Name, vm.Type)
+		if vmis[vm.Name] != nil {
+			return nil, nil, fmt.Errorf("duplicate VM interface for region %s", vm.Name)
+		}
 		var vmi lobster.VmInterface
 		if vm.Type == "openstack" {
-			vmi = openstack.MakeOpenStack(vm.Url, vm.Username, vm.Password, vm.Tenant, vm.NetworkId)
+			osVmi, err := openstack.MakeOpenStack(vm.Url, vm.Username, vm.Password, vm.Tenant, vm.NetworkId)
+			if err != nil {
+				return nil, nil, fmt.Errorf("openstack error for region %s: %v", vm.Name, err)
+			}
+			osVmi.ReservedIpPool = vm.ReservedIpPool
+			vmi = osVmi
 		} else if vm.Type == "solusvm" {
 			vmi = &solusvm.SolusVM{
 				VirtType:  vm.VirtType,
@@ -137,7 +141,11 @@ func main() {
 		} else if vm.Type == "cloudstack" {
 			vmi = cloudstack.MakeCloudStack(vm.Url, vm.ZoneID, vm.NetworkId, vm.ApiKey, vm.SecretKey)
 		} else if vm.Type == "lndynamic" {
-			vmi = lunanode.MakeLunaNode(vm.Region, vm.ApiId, vm.ApiKey)
+			lnVmi, err := lunanode.MakeLunaNode(vm.Region, vm.ApiId, vm.ApiKey)
+			if err != nil {
+				return nil, nil, fmt.Errorf("lndynamic error for region %s: %v", vm.Name, err)
+			}
+			vmi = lnVmi
 		} else if vm.Type == "fake" {
 			vmi = new(vmfake.Fake)
 		} else if vm.Type == "digitalocean" {
@@ -145,13 +153,13 @@ func main() {
 		} else if vm.Type == "vultr" {
 			regionId, err := strconv.Atoi(vm.Region)
 			if err != nil {
-				log.Fatalf("Error: invalid region ID for vultr interface: %s", vm.Region)
+				return nil, nil, fmt.Errorf("invalid region ID for vultr interface: %s", vm.Region)
 			}
 			vmi = vultr.MakeVultr(vm.ApiKey, regionId)
 		} else if vm.Type == "linode" {
 			datacenterId, err := strconv.Atoi(vm.Region)
 			if err != nil {
-				log.Fatalf("Error: invalid datacenter ID for linode interface: %s", vm.Region)
+				return nil, nil, fmt.Errorf("invalid datacenter ID for linode interface: %s", vm.Region)
 			}
 			vmi = linode.MakeLinode(vm.ApiKey, datacenterId)
 		} else if vm.Type == "cloug" {
@@ -160,24 +168,28 @@ func main() {
 			//   re-marshal the corresponding index
 			var helperConfig HelperConfig
 			if err := json.Unmarshal(jsonConfigBytes, &helperConfig); err != nil {
-				log.Fatalf("Error unmarshaling into helper for cloug interface: %v", err)
+				return nil, nil, fmt.Errorf("error unmarshaling into helper for cloug interface: %v", err)
 			}
 			jsonData, err := json.Marshal(helperConfig.Vm[i])
 			if err != nil {
-				log.Fatalf("Error marshaling from helper for cloug interface: %v", err)
+				return nil, nil, fmt.Errorf("error marshaling from helper for cloug interface: %v", err)
 			}
 			vmi, err = cloug.MakeCloug(jsonData, vm.Region)
 			if err != nil {
-				log.Fatalf("Cloug error: %v", err)
+				return nil, nil, fmt.Errorf("cloug error: %v", err)
 			}
 		} else {
-			log.Fatalf("Encountered unrecognized VM interface type %s", vm.Type)
+			return nil, nil, fmt.Errorf("encountered unrecognized VM interface type %s", vm.Type)
 		}
 		log.Println("... initialized successfully")
-		lobster.RegisterVmInterface(vm.Name, vmi)
+		vmis[vm.Name] = vmi
 	}
 
+	payments := make(map[string]lobster.PaymentInterface)
 	for _, payment := range jsonConfig.Payment {
+		if payments[payment.Name] != nil {
+			return nil, nil, fmt.Errorf("duplicate payment interface for method %s", payment.Name)
+		}
 		var pi lobster.PaymentInterface
 		if payment.Type == "paypal" {
 			pi = paypal.MakePaypalPayment(payment.Business, payment.ReturnUrl, payment.RequireShipping)
@@ -188,9 +200,42 @@ func main() {
 		} else if payment.Type == "stripe" {
 			pi = stripe.MakeStripePayment(payment.PrivateKey, payment.PublishableKey)
 		} else {
-			log.Fatalf("Encountered unrecognized payment interface type %s", payment.Type)
+			return nil, nil, fmt.Errorf("encountered unrecognized payment interface type %s", payment.Type)
 		}
-		lobster.RegisterPaymentInterface(payment.Name, pi)
+		payments[payment.Name] = pi
+	}
+
+	return vmis, payments, nil
+}
+
+func main() {
+	cfgPath := "lobster.cfg"
+	if len(os.Args) >= 2 {
+		cfgPath = os.Args[1]
+	}
+	lobster.Setup(cfgPath)
+
+	// associate core
+	support.Setup()
+
+	// load json configuration
+	// VM and payment interfaces can be reloaded later (on SIGHUP or from the admin panel), the rest cannot
+	jsonPath := cfgPath + ".json"
+	err := lobster.SetInterfaceLoader(func() (map[string]lobster.VmInterface, map[string]lobster.PaymentInterface, error) {
+		return loadInterfaces(jsonPath)
+	})
+	if err != nil {
+		log.Fatalf("Error: %s", err.Error())
+	}
+
+	jsonConfigBytes, err := ioutil.ReadFile(jsonPath)
+	if err != nil {
+		log.Fatalf("Error: failed to read json configuration file %s: %s", jsonPath, err.Error())
+	}
+	var jsonConfig JSONConfig
+	err = json.Unmarshal(jsonConfigBytes, &jsonConfig)
+	if err != nil {
+		log.Fatalf("Error: failed to parse json configuration: %s", err.Error())
 	}
 
 	for _, module := range jsonConfig.Module {
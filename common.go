@@ -3,6 +3,7 @@ package lobster
 import "github.com/LunaNode/lobster/utils"
 
 import "fmt"
+import "net"
 import "net/url"
 import "net/http"
 import "runtime/debug"
@@ -15,7 +16,9 @@ const MAX_USERNAME_LENGTH = 128
 const MIN_PASSWORD_LENGTH = 6
 const MAX_PASSWORD_LENGTH = 512
 const MAX_VM_NAME_LENGTH = 64
+const MAX_SSHKEY_NAME_LENGTH = 64
 const MAX_API_RESTRICTION = 512
+const MAX_USER_DATA_LENGTH = 16 * 1024
 
 const SESSION_UID_LENGTH = 64
 const SESSION_COOKIE_NAME = "lobsterSession"
@@ -40,6 +43,79 @@ const MINIMUM_CREDIT = BILLING_PRECISION // minimum credit to do things like cre
 //   instead, this determines how often to apply VM charges and do bandwidth accounting
 const BILLING_VM_FREQUENCY = 1
 
+// backup schedule constants
+const MAX_BACKUP_SCHEDULES = 4  // per virtual machine
+const MAX_BACKUP_RETENTION = 30 // backups kept per schedule
+
+// power schedule constants
+const MAX_POWER_SCHEDULES = 8        // per virtual machine
+const MAX_POWER_SCHEDULE_LENGTH = 64 // length of cron expression
+const POWER_SCHEDULE_GRACE = 60      // minutes after the scheduled time that a missed action still runs
+
+// health check constants
+const MAX_HEALTH_CHECKS = 4           // per virtual machine
+const MAX_HEALTH_CHECK_THRESHOLD = 10 // consecutive failures before a check is down
+const MAX_HEALTH_CHECK_PATH = 255     // length of HTTP path
+const HEALTH_CHECK_TIMEOUT = 10       // seconds
+
+// maximum number of firewall rules per virtual machine
+const MAX_FIREWALL_RULES = 50
+
+// maximum size of a volume in GB
+const MAX_VOLUME_SIZE = 2000
+
+// maximum number of reserved IPs per user
+const MAX_RESERVED_IPS = 16
+
+// private network limits
+const MAX_NETWORKS = 10       // per user
+const MIN_NETWORK_PREFIX = 16 // largest subnet allowed
+const MAX_NETWORK_PREFIX = 29 // smallest subnet allowed
+
+// amount of serial console output to return, in KB
+const CONSOLE_OUTPUT_DEFAULT = 64
+const CONSOLE_OUTPUT_MAX = 1024
+
+// resource usage metrics constants
+//   resolutions are in seconds, and retention (how long samples at each resolution are kept) is in hours
+const METRICS_RESOLUTION_FINE = 300
+const METRICS_RESOLUTION_HOURLY = 3600
+const METRICS_RESOLUTION_DAILY = 86400
+const METRICS_RETENTION_FINE = 48
+const METRICS_RETENTION_HOURLY = 24 * 31
+const METRICS_RETENTION_DAILY = 24 * 366
+
+// virtual machine tag and notes limits
+const MAX_VM_TAGS = 20
+const MAX_VM_TAG_LENGTH = 32
+const MAX_VM_NOTES_LENGTH = 256
+
+// maximum number of virtual machines in one bulk operation
+const MAX_BULK_VMS = 100
+
+// how long a virtual machine transfer can be accepted, in days
+const VM_TRANSFER_EXPIRE_DAYS = 7
+
+// how long free resources reported by VMICapacity are cached, in seconds
+const CAPACITY_CACHE_TIME = 60
+
+// how long a cross-region migration waits for an image or virtual machine, in hours
+const MIGRATE_WAIT_TIMEOUT = 6
+
+// how long cloning waits for the snapshot or new virtual machine, in hours
+const CLONE_WAIT_TIMEOUT = 6
+
+// how often to reconcile virtual machines against the backend, in minutes
+const RECONCILE_INTERVAL = 15
+
+// job queue constants
+const JOB_DEFAULT_ATTEMPTS = 5
+const JOB_RETRY_BACKOFF = 30       // delay in seconds before the first retry, doubled on each subsequent failure
+const JOB_RETRY_BACKOFF_MAX = 3600 // maximum delay in seconds between retries
+const JOB_HISTORY_LIMIT = 20       // number of jobs to show in virtual machine job history
+const JOB_HISTORY_DAYS = 30        // finished jobs are removed after this many days
+const JOB_WAIT_INTERVAL = 30       // delay in seconds before running a job that is waiting again
+
 func checkErr(err error) {
 	if err != nil {
 		panic(err)
@@ -69,9 +145,13 @@ func isPrintable(s string) bool {
 	return true
 }
 
-// Extracts IP address from http.Request.RemoteAddr (127.0.0.1:9999 -> 127.0.0.1)
+// Extracts IP address from http.Request.RemoteAddr (127.0.0.1:9999 -> 127.0.0.1, [::1]:9999 -> ::1)
 func ExtractIP(ipport string) string {
-	return strings.Split(ipport, ":")[0]
+	host, _, err := net.SplitHostPort(ipport)
+	if err != nil {
+		return ipport
+	}
+	return host
 }
 
 // Report should be true unless this error handler is being used in an error reporting function.
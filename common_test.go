@@ -17,3 +17,17 @@ func TestWildcardMatcher(t *testing.T) {
 	testWildcardMatcher(t, "vms/*", "vms/blah", true)
 	testWildcardMatcher(t, "*", "blah", true)
 }
+
+func TestExtractIP(t *testing.T) {
+	expected := map[string]string{
+		"127.0.0.1:9999":   "127.0.0.1",
+		"[::1]:9999":       "::1",
+		"[2001:db8::1]:80": "2001:db8::1",
+		"127.0.0.1":        "127.0.0.1",
+	}
+	for ipport, ip := range expected {
+		if ExtractIP(ipport) != ip {
+			t.Fatalf("ExtractIP(%s) = %s, expected %s", ipport, ExtractIP(ipport), ip)
+		}
+	}
+}
@@ -17,9 +17,21 @@ type ConfigVm struct {
 	MaximumIps int
 }
 
+// Default resource quotas for users, which can be overridden per user; zero means unlimited.
+type ConfigQuota struct {
+	Cpu       int
+	Ram       int
+	Storage   int
+	Ips       int
+	Snapshots int
+	Images    int
+}
+
 type ConfigBilling struct {
 	BandwidthOverageFee float64
 	StorageFee          float64
+	VolumeFee           float64
+	ReservedIpFee       float64
 	Currency            string
 	BillingInterval     int
 	BillingVmMinimum    int
@@ -76,6 +88,7 @@ type ConfigWssh struct {
 type Config struct {
 	Default              ConfigDefault
 	Vm                   ConfigVm
+	Quota                ConfigQuota
 	Billing              ConfigBilling
 	BillingNotifications ConfigBillingNotifications
 	BillingTermination   ConfigBillingTermination
@@ -105,6 +118,13 @@ func LoadConfig(cfgPath string) *Config {
 	if cfg.Billing.StorageFee == 0 {
 		log.Printf("Warning: storage fee not set")
 	}
+	if cfg.Billing.VolumeFee == 0 {
+		log.Printf("Warning: volume fee not set, defaulting to storage fee")
+		cfg.Billing.VolumeFee = cfg.Billing.StorageFee
+	}
+	if cfg.Billing.ReservedIpFee == 0 {
+		log.Printf("Warning: reserved IP fee not set")
+	}
 	if cfg.Billing.BillingInterval == 0 {
 		log.Printf("Warning: billing interval not set, defaulting to 60 minutes")
 		cfg.Billing.BillingInterval = 60
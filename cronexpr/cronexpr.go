@@ -0,0 +1,187 @@
+package cronexpr
+
+// Parses standard five-field cron expressions (minute, hour, day of month, month, day of week).
+// Fields may be *, numbers, ranges (1-5), steps (*/15 or 0-30/10) and lists of these (1,15).
+// Months and days of week may also be given by their three-letter English names (JAN, MON-FRI).
+// As in cron, if both day of month and day of week are restricted, a day matching either one matches.
+
+import "fmt"
+import "strconv"
+import "strings"
+import "time"
+
+// how far ahead Next looks for a matching time, so that expressions like "0 0 30 2 *" terminate
+const MAX_SEARCH_YEARS = 5
+
+type Expression struct {
+	minutes  []bool
+	hours    []bool
+	days     []bool
+	months   []bool
+	weekdays []bool
+
+	// whether the day of month and day of week fields were restricted
+	daysRestricted     bool
+	weekdaysRestricted bool
+}
+
+type field struct {
+	min   int
+	max   int
+	names []string // names for values starting at min, or nil
+}
+
+var fields = []field{
+	{0, 59, nil},
+	{0, 23, nil},
+	{1, 31, nil},
+	{1, 12, []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	{0, 7, []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}}, // 7 is also Sunday
+}
+
+func (f field) parseValue(s string) (int, error) {
+	for i, name := range f.names {
+		if strings.ToLower(s) == name {
+			return f.min + i, nil
+		}
+	}
+	x, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value \"%s\"", s)
+	} else if x < f.min || x > f.max {
+		return 0, fmt.Errorf("value %d out of range %d-%d", x, f.min, f.max)
+	}
+	return x, nil
+}
+
+// Returns the set of values matched by the field, and whether it was restricted (not *).
+func (f field) parse(s string) ([]bool, bool, error) {
+	values := make([]bool, f.max+1)
+	restricted := true
+	for _, part := range strings.Split(s, ",") {
+		step := 1
+		if idx := strings.Index(part, "/"); idx != -1 {
+			var err error
+			step, err = strconv.Atoi(part[idx+1:])
+			if err != nil || step < 1 {
+				return nil, false, fmt.Errorf("invalid step in \"%s\"", part)
+			}
+			part = part[:idx]
+		}
+
+		var start, end int
+		if part == "*" {
+			start, end = f.min, f.max
+			if step == 1 {
+				restricted = false
+			}
+		} else if idx := strings.Index(part, "-"); idx != -1 {
+			var err error
+			start, err = f.parseValue(part[:idx])
+			if err != nil {
+				return nil, false, err
+			}
+			end, err = f.parseValue(part[idx+1:])
+			if err != nil {
+				return nil, false, err
+			} else if end < start {
+				return nil, false, fmt.Errorf("invalid range \"%s\"", part)
+			}
+		} else {
+			var err error
+			start, err = f.parseValue(part)
+			if err != nil {
+				return nil, false, err
+			}
+			end = start
+			if step != 1 {
+				// "5/15" means every 15 starting at 5
+				end = f.max
+			}
+		}
+
+		for x := start; x <= end; x += step {
+			values[x] = true
+		}
+	}
+	return values, restricted, nil
+}
+
+func Parse(s string) (*Expression, error) {
+	parts := strings.Fields(s)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("expected %d fields, got %d", len(fields), len(parts))
+	}
+
+	var sets [][]bool
+	var restricted []bool
+	for i, f := range fields {
+		values, r, err := f.parse(parts[i])
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, values)
+		restricted = append(restricted, r)
+	}
+
+	// day of week 7 is Sunday
+	if sets[4][7] {
+		sets[4][0] = true
+	}
+
+	return &Expression{
+		minutes:            sets[0],
+		hours:              sets[1],
+		days:               sets[2],
+		months:             sets[3],
+		weekdays:           sets[4],
+		daysRestricted:     restricted[2],
+		weekdaysRestricted: restricted[4],
+	}, nil
+}
+
+func (expr *Expression) matchDay(t time.Time) bool {
+	if !expr.months[int(t.Month())] {
+		return false
+	}
+	day := expr.days[t.Day()]
+	weekday := expr.weekdays[int(t.Weekday())]
+	if expr.daysRestricted && expr.weekdaysRestricted {
+		return day || weekday
+	}
+	return day && weekday
+}
+
+// Returns whether the minute containing t matches the expression.
+func (expr *Expression) Match(t time.Time) bool {
+	return expr.matchDay(t) && expr.hours[t.Hour()] && expr.minutes[t.Minute()]
+}
+
+// Returns the first matching minute strictly after t, in t's location.
+// Returns the zero time if there is no match within MAX_SEARCH_YEARS.
+func (expr *Expression) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(MAX_SEARCH_YEARS, 0, 0)
+	for t.Before(limit) {
+		if !expr.matchDay(t) {
+			// skip to the start of the next day
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !expr.hours[t.Hour()] {
+			next := time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			if !next.After(t) {
+				// the next hour is skipped by a daylight saving change
+				next = t.Add(time.Hour).Truncate(time.Hour)
+			}
+			t = next
+			continue
+		}
+		if !expr.minutes[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
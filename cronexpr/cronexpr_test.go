@@ -0,0 +1,62 @@
+package cronexpr
+
+import "testing"
+import "time"
+
+func TestParse(t *testing.T) {
+	for _, s := range []string{"* * * * *", "0 8 * * 1-5", "*/15 0-6,20-23 1,15 JAN-jun sun", "5/10 * * * 7", "0 0 1 1 *"} {
+		if _, err := Parse(s); err != nil {
+			t.Fatalf("Failed to parse %s: %v", s, err)
+		}
+	}
+	for _, s := range []string{"", "* * * *", "* * * * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "5-1 * * * *", "*/0 * * * *", "a * * * *"} {
+		if _, err := Parse(s); err == nil {
+			t.Fatalf("Expected %s to be rejected", s)
+		}
+	}
+}
+
+func testNext(t *testing.T, s string, from time.Time, expected time.Time) {
+	expr, err := Parse(s)
+	if err != nil {
+		t.Fatalf("Failed to parse %s: %v", s, err)
+	}
+	next := expr.Next(from)
+	if !next.Equal(expected) {
+		t.Fatalf("Next(%s) after %v is %v, expected %v", s, from, next, expected)
+	}
+}
+
+func TestNext(t *testing.T) {
+	// Wednesday
+	now := time.Date(2016, time.March, 2, 10, 30, 20, 0, time.UTC)
+	testNext(t, "* * * * *", now, time.Date(2016, time.March, 2, 10, 31, 0, 0, time.UTC))
+	testNext(t, "0 20 * * 1-5", now, time.Date(2016, time.March, 2, 20, 0, 0, 0, time.UTC))
+	testNext(t, "0 8 * * mon-fri", now, time.Date(2016, time.March, 3, 8, 0, 0, 0, time.UTC))
+	testNext(t, "30 10 * * *", now, time.Date(2016, time.March, 3, 10, 30, 0, 0, time.UTC))
+	testNext(t, "*/20 * * * *", now, time.Date(2016, time.March, 2, 10, 40, 0, 0, time.UTC))
+	testNext(t, "0 0 * * 7", now, time.Date(2016, time.March, 6, 0, 0, 0, 0, time.UTC))
+	testNext(t, "0 0 29 2 *", now, time.Date(2020, time.February, 29, 0, 0, 0, 0, time.UTC))
+
+	// either day of month or day of week matches when both are restricted
+	testNext(t, "0 0 15 * fri", now, time.Date(2016, time.March, 4, 0, 0, 0, 0, time.UTC))
+
+	// impossible dates do not match
+	expr, _ := Parse("0 0 30 2 *")
+	if !expr.Next(now).IsZero() {
+		t.Fatal("Expected no match for February 30")
+	}
+
+	// times are matched in the location of the argument
+	location := time.FixedZone("UTC-5", -5*3600)
+	testNext(t, "0 8 * * *", now.In(location), time.Date(2016, time.March, 2, 8, 0, 0, 0, location))
+}
+
+func TestMatch(t *testing.T) {
+	expr, _ := Parse("0 8 * * 1-5")
+	if !expr.Match(time.Date(2016, time.March, 2, 8, 0, 45, 0, time.UTC)) {
+		t.Fatal("Expected weekday 08:00 to match")
+	} else if expr.Match(time.Date(2016, time.March, 5, 8, 0, 0, 0, time.UTC)) {
+		t.Fatal("Expected Saturday 08:00 to not match")
+	}
+}
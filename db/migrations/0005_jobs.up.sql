@@ -0,0 +1,15 @@
+CREATE TABLE jobs (
+	id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+	user_id INT NOT NULL,
+	vm_id INT NOT NULL DEFAULT 0,
+	kind VARCHAR(64) NOT NULL,
+	data TEXT NOT NULL,
+	status ENUM('pending', 'running', 'done', 'error') NOT NULL DEFAULT 'pending',
+	attempts INT NOT NULL DEFAULT 0,
+	error TEXT NOT NULL,
+	time_created TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	time_updated TIMESTAMP DEFAULT 0,
+	time_next TIMESTAMP DEFAULT 0,
+	KEY (status, time_next),
+	KEY (vm_id)
+);
@@ -0,0 +1 @@
+ALTER TABLE sshkeys DROP COLUMN fingerprint;
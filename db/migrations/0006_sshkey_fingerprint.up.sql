@@ -0,0 +1 @@
+ALTER TABLE sshkeys ADD COLUMN fingerprint VARCHAR(128) NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+DROP TABLE backup_schedules;
+ALTER TABLE images DROP COLUMN backup_schedule;
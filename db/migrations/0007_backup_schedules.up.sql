@@ -0,0 +1,12 @@
+CREATE TABLE backup_schedules (
+	id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+	vm_id INT NOT NULL,
+	frequency ENUM('daily', 'weekly') NOT NULL,
+	hour INT NOT NULL,
+	weekday INT NOT NULL DEFAULT 0,
+	retention INT NOT NULL,
+	time_last TIMESTAMP DEFAULT 0,
+	KEY (vm_id)
+);
+
+ALTER TABLE images ADD COLUMN backup_schedule INT NOT NULL DEFAULT 0;
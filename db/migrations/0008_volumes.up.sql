@@ -0,0 +1,13 @@
+CREATE TABLE volumes (
+	id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+	user_id INT NOT NULL,
+	region VARCHAR(64) NOT NULL,
+	name VARCHAR(64) NOT NULL,
+	identification VARCHAR(128) NOT NULL DEFAULT '',
+	size INT NOT NULL,
+	status ENUM ('pending', 'active', 'error') NOT NULL DEFAULT 'pending',
+	vm_id INT NOT NULL DEFAULT 0,
+	time_created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	KEY (user_id),
+	KEY (vm_id)
+);
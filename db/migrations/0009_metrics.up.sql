@@ -0,0 +1,12 @@
+CREATE TABLE vm_metrics (
+	vm_id INT NOT NULL,
+	resolution INT NOT NULL,
+	time TIMESTAMP NOT NULL DEFAULT 0,
+	cpu FLOAT NOT NULL DEFAULT 0,
+	disk_read DOUBLE NOT NULL DEFAULT 0,
+	disk_write DOUBLE NOT NULL DEFAULT 0,
+	network_in DOUBLE NOT NULL DEFAULT 0,
+	network_out DOUBLE NOT NULL DEFAULT 0,
+	PRIMARY KEY (vm_id, resolution, time),
+	KEY (resolution, time)
+);
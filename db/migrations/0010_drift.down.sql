@@ -0,0 +1 @@
+DROP TABLE vm_drift;
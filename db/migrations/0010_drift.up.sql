@@ -0,0 +1,11 @@
+CREATE TABLE vm_drift (
+	id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+	vm_id INT NOT NULL DEFAULT 0,
+	region VARCHAR(64) NOT NULL,
+	identification VARCHAR(128) NOT NULL,
+	kind ENUM ('missing', 'error', 'stopped', 'ip_mismatch', 'orphan') NOT NULL,
+	details VARCHAR(512) NOT NULL DEFAULT '',
+	time_detected TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE KEY (region, identification, kind),
+	KEY (vm_id)
+);
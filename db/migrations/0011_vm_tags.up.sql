@@ -0,0 +1,6 @@
+CREATE TABLE vm_tags (
+	vm_id INT NOT NULL,
+	tag VARCHAR(32) NOT NULL,
+	PRIMARY KEY (vm_id, tag),
+	KEY (tag)
+);
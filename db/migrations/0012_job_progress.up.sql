@@ -0,0 +1 @@
+ALTER TABLE jobs ADD COLUMN progress VARCHAR(64) NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+DROP TABLE reserved_ips;
@@ -0,0 +1,11 @@
+CREATE TABLE reserved_ips (
+	id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+	user_id INT NOT NULL,
+	region VARCHAR(64) NOT NULL,
+	identification VARCHAR(128) NOT NULL,
+	ip VARCHAR(64) NOT NULL,
+	vm_id INT NOT NULL DEFAULT 0,
+	time_created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	KEY (user_id),
+	KEY (vm_id)
+);
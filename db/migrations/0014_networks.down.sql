@@ -0,0 +1,2 @@
+DROP TABLE network_vms;
+DROP TABLE networks;
@@ -0,0 +1,17 @@
+CREATE TABLE networks (
+	id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+	user_id INT NOT NULL,
+	region VARCHAR(64) NOT NULL,
+	name VARCHAR(64) NOT NULL,
+	cidr VARCHAR(64) NOT NULL,
+	identification VARCHAR(128) NOT NULL,
+	time_created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	KEY (user_id)
+);
+
+CREATE TABLE network_vms (
+	network_id INT NOT NULL,
+	vm_id INT NOT NULL,
+	PRIMARY KEY (network_id, vm_id),
+	KEY (vm_id)
+);
@@ -0,0 +1,4 @@
+ALTER TABLE vms MODIFY external_ip VARCHAR(32) NOT NULL DEFAULT 'unknown';
+ALTER TABLE vms MODIFY private_ip VARCHAR(32) NOT NULL DEFAULT 'unknown';
+ALTER TABLE actions MODIFY ip VARCHAR(32) NOT NULL;
+ALTER TABLE antiflood MODIFY ip VARCHAR(32) NOT NULL;
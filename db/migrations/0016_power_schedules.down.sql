@@ -0,0 +1 @@
+DROP TABLE power_schedules;
@@ -0,0 +1,9 @@
+CREATE TABLE power_schedules (
+	id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+	vm_id INT NOT NULL,
+	action ENUM('start', 'stop', 'reboot') NOT NULL,
+	expression VARCHAR(64) NOT NULL,
+	timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+	time_last TIMESTAMP DEFAULT 0,
+	KEY (vm_id)
+);
@@ -0,0 +1,14 @@
+CREATE TABLE health_checks (
+	id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+	vm_id INT NOT NULL,
+	protocol ENUM('tcp', 'http') NOT NULL,
+	port INT NOT NULL,
+	path VARCHAR(255) NOT NULL DEFAULT '',
+	threshold INT NOT NULL DEFAULT 3,
+	auto_reboot TINYINT(1) NOT NULL DEFAULT 0,
+	status ENUM('unknown', 'up', 'down') NOT NULL DEFAULT 'unknown',
+	failures INT NOT NULL DEFAULT 0,
+	message VARCHAR(255) NOT NULL DEFAULT '',
+	time_checked TIMESTAMP DEFAULT 0,
+	KEY (vm_id)
+);
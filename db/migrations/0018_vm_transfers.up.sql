@@ -0,0 +1,9 @@
+CREATE TABLE vm_transfers (
+	id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+	vm_id INT NOT NULL,
+	from_user_id INT NOT NULL,
+	to_user_id INT NOT NULL,
+	time_created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE KEY (vm_id),
+	KEY (to_user_id)
+);
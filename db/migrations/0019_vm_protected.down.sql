@@ -0,0 +1 @@
+ALTER TABLE vms DROP COLUMN protected;
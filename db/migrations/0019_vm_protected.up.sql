@@ -0,0 +1 @@
+ALTER TABLE vms ADD COLUMN protected TINYINT(1) NOT NULL DEFAULT 0;
@@ -0,0 +1,6 @@
+CREATE TABLE user_quotas (
+	user_id INT NOT NULL,
+	resource VARCHAR(16) NOT NULL,
+	quota INT NOT NULL,
+	PRIMARY KEY (user_id, resource)
+);
@@ -0,0 +1,6 @@
+CREATE TABLE region_capacity (
+	region VARCHAR(128) NOT NULL,
+	plan_id INT NOT NULL,
+	vm_limit INT NOT NULL,
+	PRIMARY KEY (region, plan_id)
+);
@@ -0,0 +1,2 @@
+DELETE vm_tags FROM vm_tags JOIN vms ON vms.id = vm_tags.vm_id WHERE vm_tags.user_id != vms.user_id;
+ALTER TABLE vm_tags DROP PRIMARY KEY, DROP COLUMN user_id, ADD PRIMARY KEY (vm_id, tag);
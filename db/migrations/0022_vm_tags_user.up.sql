@@ -0,0 +1,4 @@
+ALTER TABLE vm_tags ADD COLUMN user_id INT NOT NULL DEFAULT 0;
+UPDATE vm_tags, vms SET vm_tags.user_id = vms.user_id WHERE vm_tags.vm_id = vms.id;
+UPDATE vm_tags SET user_id = IFNULL((SELECT charges.user_id FROM charges WHERE charges.k = CONCAT('vm-', vm_tags.vm_id) LIMIT 1), 0) WHERE user_id = 0;
+ALTER TABLE vm_tags DROP PRIMARY KEY, ADD PRIMARY KEY (vm_id, user_id, tag);
@@ -89,7 +89,7 @@ CREATE TABLE region_bandwidth (
 CREATE TABLE actions (
 	id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
 	user_id INT NOT NULL DEFAULT 0,
-	ip VARCHAR(32) NOT NULL,
+	ip VARCHAR(64) NOT NULL,
 	name VARCHAR(256) NOT NULL,
 	details VARCHAR(1024) NOT NULL,
 	time TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
@@ -104,11 +104,12 @@ CREATE TABLE vms (
 	identification VARCHAR(128) NOT NULL DEFAULT '',
 	status ENUM('unknown', 'provisioning', 'active', 'error') NOT NULL DEFAULT 'unknown',
 	task_pending TINYINT(1) NOT NULL DEFAULT 0,
-	external_ip VARCHAR(32) NOT NULL DEFAULT 'unknown',
-	private_ip VARCHAR(32) NOT NULL DEFAULT 'unknown',
+	external_ip VARCHAR(64) NOT NULL DEFAULT 'unknown',
+	private_ip VARCHAR(64) NOT NULL DEFAULT 'unknown',
 	time_created TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 	time_billed TIMESTAMP DEFAULT 0,
 	suspended ENUM ('no', 'manual', 'auto') NOT NULL DEFAULT 'no',
+	protected TINYINT(1) NOT NULL DEFAULT 0,
 	KEY (user_id)
 );
 
@@ -153,7 +154,8 @@ CREATE TABLE images (
 	name VARCHAR(64) NOT NULL,
 	identification VARCHAR(128) NOT NULL DEFAULT '',
 	status ENUM ('pending', 'active', 'error') NOT NULL DEFAULT 'active',
-	source_vm INT NOT NULL DEFAULT -1
+	source_vm INT NOT NULL DEFAULT -1,
+	backup_schedule INT NOT NULL DEFAULT 0
 );
 
 CREATE TABLE charges (
@@ -183,7 +185,7 @@ CREATE TABLE transactions (
 
 CREATE TABLE antiflood (
 	id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
-	ip VARCHAR(32) NOT NULL,
+	ip VARCHAR(64) NOT NULL,
 	action VARCHAR(64) NOT NULL,
 	count INT NOT NULL,
 	time TIMESTAMP NOT NULL
@@ -198,5 +200,160 @@ CREATE TABLE sshkeys (
 	id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
 	user_id INT NOT NULL,
 	name VARCHAR(64) NOT NULL,
-	val VARCHAR(2048) NOT NULL
+	val VARCHAR(2048) NOT NULL,
+	fingerprint VARCHAR(128) NOT NULL DEFAULT ''
+);
+
+CREATE TABLE jobs (
+	id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+	user_id INT NOT NULL,
+	vm_id INT NOT NULL DEFAULT 0,
+	kind VARCHAR(64) NOT NULL,
+	data TEXT NOT NULL,
+	status ENUM('pending', 'running', 'done', 'error') NOT NULL DEFAULT 'pending',
+	attempts INT NOT NULL DEFAULT 0,
+	error TEXT NOT NULL,
+	progress VARCHAR(64) NOT NULL DEFAULT '',
+	time_created TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	time_updated TIMESTAMP DEFAULT 0,
+	time_next TIMESTAMP DEFAULT 0,
+	KEY (status, time_next),
+	KEY (vm_id)
+);
+
+CREATE TABLE backup_schedules (
+	id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+	vm_id INT NOT NULL,
+	frequency ENUM('daily', 'weekly') NOT NULL,
+	hour INT NOT NULL,
+	weekday INT NOT NULL DEFAULT 0,
+	retention INT NOT NULL,
+	time_last TIMESTAMP DEFAULT 0,
+	KEY (vm_id)
+);
+
+CREATE TABLE volumes (
+	id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+	user_id INT NOT NULL,
+	region VARCHAR(64) NOT NULL,
+	name VARCHAR(64) NOT NULL,
+	identification VARCHAR(128) NOT NULL DEFAULT '',
+	size INT NOT NULL,
+	status ENUM ('pending', 'active', 'error') NOT NULL DEFAULT 'pending',
+	vm_id INT NOT NULL DEFAULT 0,
+	time_created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	KEY (user_id),
+	KEY (vm_id)
+);
+
+CREATE TABLE vm_metrics (
+	vm_id INT NOT NULL,
+	resolution INT NOT NULL,
+	time TIMESTAMP NOT NULL DEFAULT 0,
+	cpu FLOAT NOT NULL DEFAULT 0,
+	disk_read DOUBLE NOT NULL DEFAULT 0,
+	disk_write DOUBLE NOT NULL DEFAULT 0,
+	network_in DOUBLE NOT NULL DEFAULT 0,
+	network_out DOUBLE NOT NULL DEFAULT 0,
+	PRIMARY KEY (vm_id, resolution, time),
+	KEY (resolution, time)
+);
+
+CREATE TABLE vm_drift (
+	id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+	vm_id INT NOT NULL DEFAULT 0,
+	region VARCHAR(64) NOT NULL,
+	identification VARCHAR(128) NOT NULL,
+	kind ENUM ('missing', 'error', 'stopped', 'ip_mismatch', 'orphan') NOT NULL,
+	details VARCHAR(512) NOT NULL DEFAULT '',
+	time_detected TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE KEY (region, identification, kind),
+	KEY (vm_id)
+);
+
+CREATE TABLE vm_tags (
+	vm_id INT NOT NULL,
+	user_id INT NOT NULL DEFAULT 0,
+	tag VARCHAR(32) NOT NULL,
+	PRIMARY KEY (vm_id, user_id, tag),
+	KEY (tag)
+);
+
+CREATE TABLE reserved_ips (
+	id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+	user_id INT NOT NULL,
+	region VARCHAR(64) NOT NULL,
+	identification VARCHAR(128) NOT NULL,
+	ip VARCHAR(64) NOT NULL,
+	vm_id INT NOT NULL DEFAULT 0,
+	time_created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	KEY (user_id),
+	KEY (vm_id)
+);
+
+CREATE TABLE networks (
+	id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+	user_id INT NOT NULL,
+	region VARCHAR(64) NOT NULL,
+	name VARCHAR(64) NOT NULL,
+	cidr VARCHAR(64) NOT NULL,
+	identification VARCHAR(128) NOT NULL,
+	time_created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	KEY (user_id)
+);
+
+CREATE TABLE network_vms (
+	network_id INT NOT NULL,
+	vm_id INT NOT NULL,
+	PRIMARY KEY (network_id, vm_id),
+	KEY (vm_id)
+);
+
+CREATE TABLE power_schedules (
+	id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+	vm_id INT NOT NULL,
+	action ENUM('start', 'stop', 'reboot') NOT NULL,
+	expression VARCHAR(64) NOT NULL,
+	timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+	time_last TIMESTAMP DEFAULT 0,
+	KEY (vm_id)
+);
+
+CREATE TABLE health_checks (
+	id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+	vm_id INT NOT NULL,
+	protocol ENUM('tcp', 'http') NOT NULL,
+	port INT NOT NULL,
+	path VARCHAR(255) NOT NULL DEFAULT '',
+	threshold INT NOT NULL DEFAULT 3,
+	auto_reboot TINYINT(1) NOT NULL DEFAULT 0,
+	status ENUM('unknown', 'up', 'down') NOT NULL DEFAULT 'unknown',
+	failures INT NOT NULL DEFAULT 0,
+	message VARCHAR(255) NOT NULL DEFAULT '',
+	time_checked TIMESTAMP DEFAULT 0,
+	KEY (vm_id)
+);
+
+CREATE TABLE vm_transfers (
+	id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+	vm_id INT NOT NULL,
+	from_user_id INT NOT NULL,
+	to_user_id INT NOT NULL,
+	time_created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE KEY (vm_id),
+	KEY (to_user_id)
+);
+
+CREATE TABLE user_quotas (
+	user_id INT NOT NULL,
+	resource VARCHAR(16) NOT NULL,
+	quota INT NOT NULL,
+	PRIMARY KEY (user_id, resource)
+);
+
+CREATE TABLE region_capacity (
+	region VARCHAR(128) NOT NULL,
+	plan_id INT NOT NULL,
+	vm_limit INT NOT NULL,
+	PRIMARY KEY (region, plan_id)
 );
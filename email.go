@@ -50,6 +50,38 @@ type VmCreateErrorEmail struct {
 	Name string
 }
 
+type VmMigrateErrorEmail struct {
+	Id     int
+	Name   string
+	Region string
+}
+
+type VmCloneErrorEmail struct {
+	Id        int
+	Name      string
+	CloneName string
+}
+
+type VmPasswordResetEmail struct {
+	Id   int
+	Name string
+	Ip   string
+}
+
+type VmHealthEmail struct {
+	Id       int
+	Name     string
+	Target   string
+	Message  string
+	Rebooted bool
+}
+
+type VmTransferEmail struct {
+	Id       int
+	Name     string
+	Username string // the current owner
+}
+
 type PaymentProcessedEmail *Transaction
 
 type AccountCreatedEmail struct {
@@ -109,6 +109,16 @@ func imageGetForce(imageId int) *Image {
 }
 
 func imageFetch(userId int, region string, name string, url string, format string) (int, error) {
+	err := userQuotaCheck(userId, map[string]int{"images": 1})
+	if err != nil {
+		return 0, err
+	}
+	return imageFetchForce(userId, region, name, url, format)
+}
+
+// Fetches the image without checking the images quota.
+// Used for migration, where the image is only kept until the virtual machine is created in the destination region.
+func imageFetchForce(userId int, region string, name string, url string, format string) (int, error) {
 	// validate credit
 	user := UserDetails(userId)
 	if user == nil {
@@ -118,8 +128,8 @@ func imageFetch(userId int, region string, name string, url string, format strin
 	}
 
 	// validate region
-	vmi, ok := regionInterfaces[region]
-	if !ok {
+	vmi := regionInterface(region)
+	if vmi == nil {
 		return 0, L.Error("invalid_region")
 	}
 
@@ -208,10 +218,10 @@ func imageInfo(userId int, imageId int) *Image {
 }
 
 func imageAutopopulate(region string) error {
-	if _, ok := regionInterfaces[region]; !ok {
+	if regionInterface(region) == nil {
 		return fmt.Errorf("specified region %s does not exist", region)
 	}
-	vmi, ok := regionInterfaces[region].(VMIImages)
+	vmi, ok := regionInterface(region).(VMIImages)
 	if !ok {
 		return L.Error("operation_unsupported")
 	}
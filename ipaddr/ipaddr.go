@@ -8,7 +8,10 @@ var privateNetworks []*net.IPNet
 
 func Init() {
 	privateNetworks = make([]*net.IPNet, 0)
-	for _, cidr := range []string{"192.168.0.0/16", "172.16.0.0/12", "10.0.0.0/8"} {
+	for _, cidr := range []string{
+		"192.168.0.0/16", "172.16.0.0/12", "10.0.0.0/8", "169.254.0.0/16", // IPv4 private and link-local
+		"fc00::/7", "fe80::/10", // IPv6 unique local and link-local
+	} {
 		_, network, err := net.ParseCIDR(cidr)
 		if err != nil {
 			panic(err)
@@ -23,6 +26,9 @@ func IsPrivate(ipString string) bool {
 	}
 
 	ip := net.ParseIP(ipString)
+	if ip == nil {
+		return false
+	}
 	for _, net := range privateNetworks {
 		if net.Contains(ip) {
 			return true
@@ -39,11 +45,25 @@ func ParseCIDROrIP(s string) *net.IPNet {
 	}
 
 	// else try as IP
-	_, network, err = net.ParseCIDR(s + "/32")
-	if err == nil {
-		return network
-	} else {
+	ip := net.ParseIP(s)
+	if ip == nil {
 		return nil
+	} else if ip.To4() != nil {
+		return &net.IPNet{IP: ip.To4(), Mask: net.CIDRMask(32, 32)}
+	} else {
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}
+	}
+}
+
+// Returns "ipv4" or "ipv6" depending on the address family of the IP, or blank if it cannot be parsed.
+func Family(ipString string) string {
+	ip := net.ParseIP(ipString)
+	if ip == nil {
+		return ""
+	} else if ip.To4() != nil {
+		return "ipv4"
+	} else {
+		return "ipv6"
 	}
 }
 
@@ -52,7 +72,7 @@ func ParseNetworks(s string) ([]*net.IPNet, error) {
 	for _, part := range strings.Split(s, ",") {
 		part = strings.TrimSpace(part)
 		if part != "" {
-			network := ParseCIDROrIP(s)
+			network := ParseCIDROrIP(part)
 			if network != nil {
 				networks = append(networks, network)
 			} else {
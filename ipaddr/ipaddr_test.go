@@ -0,0 +1,61 @@
+package ipaddr
+
+import "testing"
+
+func TestIsPrivate(t *testing.T) {
+	for _, ip := range []string{"10.1.2.3", "172.31.0.1", "192.168.1.1", "169.254.169.254", "fd12:3456::1", "fe80::1"} {
+		if !IsPrivate(ip) {
+			t.Fatalf("Expected %s to be private", ip)
+		}
+	}
+	for _, ip := range []string{"8.8.8.8", "172.32.0.1", "2001:db8::1", "2607:f8b0::1", "invalid"} {
+		if IsPrivate(ip) {
+			t.Fatalf("Expected %s to not be private", ip)
+		}
+	}
+}
+
+func TestParseCIDROrIP(t *testing.T) {
+	expected := map[string]string{
+		"1.2.3.4":         "1.2.3.4/32",
+		"1.2.3.0/24":      "1.2.3.0/24",
+		"2001:db8::1":     "2001:db8::1/128",
+		"2001:db8::/32":   "2001:db8::/32",
+		"2001:db8::5/120": "2001:db8::/120",
+	}
+	for s, network := range expected {
+		result := ParseCIDROrIP(s)
+		if result == nil || result.String() != network {
+			t.Fatalf("Expected %s to parse as %s, got %v", s, network, result)
+		}
+	}
+	if ParseCIDROrIP("invalid") != nil {
+		t.Fatal("Expected invalid to be rejected")
+	}
+}
+
+func TestMatchNetworks(t *testing.T) {
+	networks := "1.2.3.0/24, 2001:db8::/32"
+	for _, ip := range []string{"1.2.3.4", "2001:db8::1", "2001:db8:ffff::1"} {
+		if !MatchNetworks(networks, ip) {
+			t.Fatalf("Expected %s to match %s", ip, networks)
+		}
+	}
+	for _, ip := range []string{"1.2.4.1", "2001:db9::1", "::ffff:1.2.4.1"} {
+		if MatchNetworks(networks, ip) {
+			t.Fatalf("Expected %s to not match %s", ip, networks)
+		}
+	}
+	if _, err := ParseNetworks("1.2.3.4, invalid"); err == nil {
+		t.Fatal("Expected error parsing invalid network")
+	}
+}
+
+func TestFamily(t *testing.T) {
+	expected := map[string]string{"1.2.3.4": "ipv4", "::ffff:1.2.3.4": "ipv4", "2001:db8::1": "ipv6", "invalid": ""}
+	for ip, family := range expected {
+		if Family(ip) != family {
+			t.Fatalf("Expected family of %s to be %s, got %s", ip, family, Family(ip))
+		}
+	}
+}
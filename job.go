@@ -0,0 +1,242 @@
+package lobster
+
+import "encoding/json"
+import "errors"
+import "fmt"
+import "log"
+import "time"
+
+// database objects
+
+type Job struct {
+	Id          int
+	UserId      int
+	VmId        int
+	Kind        string
+	Data        string
+	Status      string
+	Attempts    int
+	Error       string
+	Progress    string // current step of multi-step jobs
+	CreatedTime time.Time
+	UpdatedTime time.Time
+}
+
+// Describes how jobs of a given kind are executed.
+type JobHandler struct {
+	// Performs the job; if an error is returned, the job is retried with exponential
+	//  backoff until MaxAttempts attempts have been made.
+	// Jobs may be interrupted by a restart, in which case they are executed again on
+	//  startup, so Run should be safe to repeat.
+	Run func(job *Job) error
+
+	// Optional, called after the final attempt of the job fails.
+	Fail func(job *Job, err error)
+
+	// Maximum number of attempts, or zero to use JOB_DEFAULT_ATTEMPTS.
+	MaxAttempts int
+}
+
+var jobHandlers map[string]*JobHandler
+
+// Returned by Run to indicate that the job is waiting on something else, such as an image becoming active.
+// The job is run again after JOB_WAIT_INTERVAL without counting as an attempt.
+var errJobWait = errors.New("job is waiting")
+
+func loadJobHandlers() {
+	jobHandlers = make(map[string]*JobHandler)
+	// VmCreate is not idempotent, so a failed create is not retried; see vmCreateJob
+	RegisterJobHandler("vmCreate", &JobHandler{Run: vmCreateJob, Fail: vmCreateJobFail, MaxAttempts: 1})
+	RegisterJobHandler("vmDelete", &JobHandler{Run: vmDeleteJob})
+	RegisterJobHandler("vmSuspend", &JobHandler{Run: vmSuspendJob, MaxAttempts: 3})
+	RegisterJobHandler("vmUnsuspend", &JobHandler{Run: vmUnsuspendJob, MaxAttempts: 3})
+	RegisterJobHandler("vmMigrate", &JobHandler{Run: vmMigrateJob, Fail: vmMigrateJobFail, MaxAttempts: 3})
+	RegisterJobHandler("vmClone", &JobHandler{Run: vmCloneJob, Fail: vmCloneJobFail, MaxAttempts: 3})
+	RegisterJobHandler("networkDelete", &JobHandler{Run: networkDeleteJob})
+}
+
+func RegisterJobHandler(kind string, handler *JobHandler) {
+	if jobHandlers[kind] != nil {
+		log.Fatalf("Duplicate job handler for kind %s", kind)
+	}
+	jobHandlers[kind] = handler
+}
+
+const JOB_QUERY = "SELECT id, user_id, vm_id, kind, data, status, attempts, error, progress, time_created, time_updated FROM jobs"
+
+func jobListHelper(rows Rows) []*Job {
+	defer rows.Close()
+	jobs := make([]*Job, 0)
+	for rows.Next() {
+		job := Job{}
+		rows.Scan(&job.Id, &job.UserId, &job.VmId, &job.Kind, &job.Data, &job.Status, &job.Attempts, &job.Error, &job.Progress, &job.CreatedTime, &job.UpdatedTime)
+		jobs = append(jobs, &job)
+	}
+	return jobs
+}
+
+func jobGet(jobId int) *Job {
+	jobs := jobListHelper(db.Query(JOB_QUERY+" WHERE id = ?", jobId))
+	if len(jobs) == 1 {
+		return jobs[0]
+	} else {
+		return nil
+	}
+}
+
+// Returns the most recent jobs of the specified virtual machine.
+func jobListVm(vmId int) []*Job {
+	return jobListHelper(db.Query(JOB_QUERY+" WHERE vm_id = ? ORDER BY id DESC LIMIT ?", vmId, JOB_HISTORY_LIMIT))
+}
+
+// Queues a new job; data is encoded as JSON and can be decoded by the handler with job.Decode.
+// If vmId is set, the virtual machine is marked as having a pending task until the job terminates.
+func jobCreate(userId int, vmId int, kind string, data interface{}) int {
+	dataBytes, err := json.Marshal(data)
+	checkErr(err)
+	log.Printf("jobCreate(%d, %d, %s)", userId, vmId, kind)
+	result := db.Exec(
+		"INSERT INTO jobs (user_id, vm_id, kind, data, time_updated, time_next) VALUES (?, ?, ?, ?, NOW(), NOW())",
+		userId, vmId, kind, string(dataBytes),
+	)
+	if vmId != 0 {
+		db.Exec("UPDATE vms SET task_pending = 1 WHERE id = ?", vmId)
+	}
+	return result.LastInsertId()
+}
+
+func (job *Job) Decode(v interface{}) error {
+	return json.Unmarshal([]byte(job.Data), v)
+}
+
+// Replaces the job data, so that multi-step jobs can resume from the last completed step.
+func (job *Job) Save(v interface{}) {
+	dataBytes, err := json.Marshal(v)
+	checkErr(err)
+	job.Data = string(dataBytes)
+	db.Exec("UPDATE jobs SET data = ?, time_updated = NOW() WHERE id = ?", job.Data, job.Id)
+}
+
+func (job *Job) SetProgress(progress string) {
+	job.Progress = progress
+	db.Exec("UPDATE jobs SET progress = ?, time_updated = NOW() WHERE id = ?", progress, job.Id)
+}
+
+// Called on startup to requeue jobs that were running when lobster last exited.
+func jobResume() {
+	result := db.Exec("UPDATE jobs SET status = 'pending', time_next = NOW() WHERE status = 'running'")
+	if count := result.RowsAffected(); count > 0 {
+		log.Printf("Resuming %d interrupted jobs", count)
+	}
+}
+
+// Starts pending jobs whose retry time has passed.
+// Jobs on the same virtual machine are executed one at a time in the order that they were created.
+func jobProcess() {
+	defer errorHandler(nil, nil, true)
+	rows := db.Query("SELECT id FROM jobs WHERE status = 'pending' AND time_next <= NOW() ORDER BY id")
+	var jobIds []int
+	for rows.Next() {
+		var jobId int
+		rows.Scan(&jobId)
+		jobIds = append(jobIds, jobId)
+	}
+	rows.Close()
+
+	for _, jobId := range jobIds {
+		job := jobGet(jobId)
+		if job == nil {
+			continue
+		}
+
+		if job.VmId != 0 {
+			var count int
+			db.QueryRow(
+				"SELECT COUNT(*) FROM jobs WHERE vm_id = ? AND id != ? AND (status = 'running' OR (status = 'pending' AND id < ?))",
+				job.VmId, job.Id, job.Id,
+			).Scan(&count)
+			if count > 0 {
+				continue
+			}
+		}
+
+		result := db.Exec("UPDATE jobs SET status = 'running', attempts = attempts + 1, time_updated = NOW() WHERE id = ? AND status = 'pending'", job.Id)
+		if result.RowsAffected() != 1 {
+			continue
+		}
+		job.Status = "running"
+		job.Attempts++
+		go jobRun(job)
+	}
+}
+
+func jobRun(job *Job) {
+	defer errorHandler(nil, nil, true)
+	log.Printf("jobRun(%d, %s, attempt %d)", job.Id, job.Kind, job.Attempts)
+	handler := jobHandlers[job.Kind]
+	var err error
+	maxAttempts := JOB_DEFAULT_ATTEMPTS
+	if handler == nil {
+		err = fmt.Errorf("no handler for job kind %s", job.Kind)
+		maxAttempts = 0
+	} else {
+		err = jobCall(handler, job)
+		if handler.MaxAttempts > 0 {
+			maxAttempts = handler.MaxAttempts
+		}
+	}
+
+	if err == nil {
+		db.Exec("UPDATE jobs SET status = 'done', error = '', time_updated = NOW() WHERE id = ?", job.Id)
+	} else if err == errJobWait {
+		db.Exec(
+			"UPDATE jobs SET status = 'pending', attempts = attempts - 1, time_next = DATE_ADD(NOW(), INTERVAL ? SECOND) WHERE id = ?",
+			JOB_WAIT_INTERVAL, job.Id,
+		)
+	} else if job.Attempts < maxAttempts {
+		log.Printf("Job %d (%s) failed, will retry: %s", job.Id, job.Kind, err.Error())
+		db.Exec(
+			"UPDATE jobs SET status = 'pending', error = ?, time_updated = NOW(), time_next = DATE_ADD(NOW(), INTERVAL ? SECOND) WHERE id = ?",
+			err.Error(), jobBackoff(job.Attempts), job.Id,
+		)
+	} else {
+		db.Exec("UPDATE jobs SET status = 'error', error = ?, time_updated = NOW() WHERE id = ?", err.Error(), job.Id)
+		ReportError(err, "job failed", fmt.Sprintf("job_id=%d, kind=%s, user_id=%d, vm_id=%d, attempts=%d", job.Id, job.Kind, job.UserId, job.VmId, job.Attempts))
+		if handler != nil && handler.Fail != nil {
+			handler.Fail(job, err)
+		}
+	}
+
+	if job.VmId != 0 {
+		jobUpdateVm(job.VmId)
+	}
+}
+
+// Runs the handler, converting a panic into an error so that the job can be retried.
+func jobCall(handler *JobHandler, job *Job) (err error) {
+	defer func() {
+		if re := recover(); re != nil {
+			err = fmt.Errorf("panic: %v", re)
+		}
+	}()
+	return handler.Run(job)
+}
+
+// Returns the number of seconds to wait before retrying a job that has failed the given number of times.
+func jobBackoff(attempts int) int {
+	delay := JOB_RETRY_BACKOFF
+	for i := 1; i < attempts && delay < JOB_RETRY_BACKOFF_MAX; i++ {
+		delay *= 2
+	}
+	if delay > JOB_RETRY_BACKOFF_MAX {
+		delay = JOB_RETRY_BACKOFF_MAX
+	}
+	return delay
+}
+
+// Sets the task_pending flag of the virtual machine based on whether it has outstanding jobs.
+func jobUpdateVm(vmId int) {
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM jobs WHERE vm_id = ? AND status IN ('pending', 'running')", vmId).Scan(&count)
+	db.Exec("UPDATE vms SET task_pending = ? WHERE id = ?", count > 0, vmId)
+}
@@ -0,0 +1,206 @@
+package lobster
+
+import "errors"
+import "testing"
+import "time"
+
+func testWaitJobStatus(t *testing.T, jobId int, status string) *Job {
+	for i := 0; i < 50; i++ {
+		job := jobGet(jobId)
+		if job.Status == status {
+			return job
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("Job %d did not reach status %s", jobId, status)
+	return nil
+}
+
+func testVmTaskPending(vmId int) bool {
+	var taskPending bool
+	db.QueryRow("SELECT task_pending FROM vms WHERE id = ?", vmId).Scan(&taskPending)
+	return taskPending
+}
+
+func TestJobBackoff(t *testing.T) {
+	if jobBackoff(1) != JOB_RETRY_BACKOFF {
+		t.Fatalf("First retry delayed %d seconds, expected %d", jobBackoff(1), JOB_RETRY_BACKOFF)
+	} else if jobBackoff(2) != 2*JOB_RETRY_BACKOFF {
+		t.Fatalf("Second retry delayed %d seconds, expected %d", jobBackoff(2), 2*JOB_RETRY_BACKOFF)
+	} else if jobBackoff(100) != JOB_RETRY_BACKOFF_MAX {
+		t.Fatalf("Retry delayed %d seconds, expected maximum %d", jobBackoff(100), JOB_RETRY_BACKOFF_MAX)
+	}
+}
+
+func TestJobOrdering(t *testing.T) {
+	TestReset()
+	loadJobHandlers()
+	userId := TestUser()
+	vmId := TestVm(userId)
+
+	release := make(chan bool)
+	RegisterJobHandler("test", &JobHandler{
+		Run: func(job *Job) error {
+			<-release
+			return nil
+		},
+	})
+
+	firstId := jobCreate(userId, vmId, "test", nil)
+	secondId := jobCreate(userId, vmId, "test", nil)
+	if !testVmTaskPending(vmId) {
+		t.Fatal("Task pending not set after creating job")
+	}
+
+	// only the first job should start, since jobs on the same VM run one at a time
+	jobProcess()
+	testWaitJobStatus(t, firstId, "running")
+	jobProcess()
+	if jobGet(secondId).Status != "pending" {
+		t.Fatal("Second job started while first job is running")
+	}
+
+	release <- true
+	testWaitJobStatus(t, firstId, "done")
+	if !testVmTaskPending(vmId) {
+		t.Fatal("Task pending cleared while second job is outstanding")
+	}
+
+	jobProcess()
+	release <- true
+	testWaitJobStatus(t, secondId, "done")
+	if testVmTaskPending(vmId) {
+		t.Fatal("Task pending still set after all jobs completed")
+	}
+}
+
+func TestJobRetry(t *testing.T) {
+	TestReset()
+	loadJobHandlers()
+	userId := TestUser()
+	vmId := TestVm(userId)
+
+	failed := make(chan bool, 1)
+	RegisterJobHandler("test", &JobHandler{
+		Run: func(job *Job) error {
+			return errors.New("test failure")
+		},
+		Fail: func(job *Job, err error) {
+			failed <- true
+		},
+		MaxAttempts: 2,
+	})
+
+	jobId := jobCreate(userId, vmId, "test", nil)
+	jobProcess()
+	job := testWaitJobStatus(t, jobId, "pending")
+	if job.Attempts != 1 || job.Error != "test failure" {
+		t.Fatalf("Expected one failed attempt, got attempts=%d, error=%s", job.Attempts, job.Error)
+	}
+
+	// job should not be retried until the backoff delay passes
+	jobProcess()
+	if jobGet(jobId).Attempts != 1 {
+		t.Fatal("Job retried before backoff delay")
+	}
+
+	db.Exec("UPDATE jobs SET time_next = NOW() WHERE id = ?", jobId)
+	jobProcess()
+	testWaitJobStatus(t, jobId, "error")
+	select {
+	case <-failed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Fail not called after final attempt")
+	}
+	if testVmTaskPending(vmId) {
+		t.Fatal("Task pending still set after job failed")
+	}
+}
+
+func TestJobResume(t *testing.T) {
+	TestReset()
+	userId := TestUser()
+	vmId := TestVm(userId)
+	jobId := jobCreate(userId, vmId, "test", nil)
+	db.Exec("UPDATE jobs SET status = 'running', time_next = DATE_ADD(NOW(), INTERVAL 1 HOUR) WHERE id = ?", jobId)
+	jobResume()
+	if jobGet(jobId).Status != "pending" {
+		t.Fatal("Interrupted job not resumed")
+	}
+}
+
+type testCreateVmi struct {
+	TestVmi
+	calls int
+}
+
+func (this *testCreateVmi) VmCreate(vm *VirtualMachine, options *VMIVmCreateOptions) (string, error) {
+	this.calls++
+	return "test", nil
+}
+
+func TestVmCreateJobInterrupted(t *testing.T) {
+	TestReset()
+	vmi := &testCreateVmi{}
+	defer TestRegion("testcreate", vmi)()
+	userId := TestUser()
+	vmId := TestVm(userId)
+	db.Exec("UPDATE vms SET region = 'testcreate', status = 'provisioning' WHERE id = ?", vmId)
+	var planId int
+	db.QueryRow("SELECT plan_id FROM vms WHERE id = ?", vmId).Scan(&planId)
+
+	// a create that was started before lobster exited must not create a second instance
+	jobId := jobCreate(userId, vmId, "vmCreate", vmCreateJobData{PlanId: planId, Started: true})
+	if err := vmCreateJob(jobGet(jobId)); err == nil {
+		t.Fatal("Interrupted create job passed")
+	} else if vmi.calls != 0 {
+		t.Fatal("VmCreate called again after interrupted create")
+	}
+
+	jobId = jobCreate(userId, vmId, "vmCreate", vmCreateJobData{PlanId: planId})
+	if err := vmCreateJob(jobGet(jobId)); err != nil {
+		t.Fatalf("Create job failed: %v", err)
+	} else if vmi.calls != 1 || vmGet(vmId).Identification != "test" {
+		t.Fatal("Virtual machine not created")
+	}
+}
+
+// Deletes the virtual machine while it is being created, as a migration rollback would.
+type testCreateDeleteVmi struct {
+	TestVmi
+	deleted string
+}
+
+func (this *testCreateDeleteVmi) VmCreate(vm *VirtualMachine, options *VMIVmCreateOptions) (string, error) {
+	if err := vmGet(vm.Id).DeleteForce(vm.UserId); err != nil {
+		return "", err
+	}
+	return "test", nil
+}
+
+func (this *testCreateDeleteVmi) VmDelete(vm *VirtualMachine) error {
+	this.deleted = vm.Identification
+	return nil
+}
+
+func TestVmCreateJobDeleted(t *testing.T) {
+	TestReset()
+	vmi := &testCreateDeleteVmi{}
+	defer TestRegion("testcreatedelete", vmi)()
+	userId := TestUser()
+	vmId := TestVm(userId)
+	db.Exec("UPDATE vms SET region = 'testcreatedelete', status = 'provisioning' WHERE id = ?", vmId)
+	var planId int
+	db.QueryRow("SELECT plan_id FROM vms WHERE id = ?", vmId).Scan(&planId)
+
+	if err := vmGet(vmId).Delete(userId); err == nil {
+		t.Fatal("User deleted virtual machine that is still provisioning")
+	}
+
+	jobId := jobCreate(userId, vmId, "vmCreate", vmCreateJobData{PlanId: planId})
+	if err := vmCreateJob(jobGet(jobId)); err != nil {
+		t.Fatalf("Create job failed: %v", err)
+	} else if vmi.deleted != "test" {
+		t.Fatal("Instance not deleted after virtual machine was deleted during creation")
+	}
+}
@@ -13,8 +13,11 @@ import "encoding/binary"
 import "log"
 import "math/rand"
 import "net/http"
+import "os"
+import "os/signal"
 import "strings"
 import "sync"
+import "syscall"
 import "time"
 
 var decoder *schema.Decoder
@@ -62,32 +65,28 @@ func RegisterSplashRoute(path string, template string) {
 	router.HandleFunc(path, getSplashHandler(template))
 }
 
+// Handlers registered with the functions below replace any handler previously registered for the
+// same path and method, so that reloaded interfaces can register their routes again.
+
 func RegisterPanelHandler(path string, f PanelHandlerFunc, onlyPost bool) {
-	result := router.HandleFunc(path, SessionWrap(panelWrap(f)))
-	if onlyPost {
-		result.Methods("POST")
-	}
+	registerRoute(path, SessionWrap(panelWrap(f)), postMethod(onlyPost))
 }
 
 func RegisterAPIHandler(path string, f APIHandlerFunc, method string) {
-	router.HandleFunc(path, apiWrap(f)).Methods(method)
+	registerRoute(path, apiWrap(f), method)
 }
 
 func RegisterAdminHandler(path string, f AdminHandlerFunc, onlyPost bool) {
-	result := router.HandleFunc(path, SessionWrap(adminWrap(f)))
-	if onlyPost {
-		result.Methods("POST")
-	}
+	registerRoute(path, SessionWrap(adminWrap(f)), postMethod(onlyPost))
 }
 
 func RegisterHttpHandler(path string, f http.HandlerFunc, onlyPost bool) {
-	result := router.HandleFunc(path, f)
-	if onlyPost {
-		result.Methods("POST")
-	}
+	registerRoute(path, f, postMethod(onlyPost))
 }
 
 func RegisterVmInterface(region string, vmi VmInterface) {
+	interfacesMutex.Lock()
+	defer interfacesMutex.Unlock()
 	if regionInterfaces[region] != nil {
 		log.Fatalf("Duplicate VM interface for region %s", region)
 	}
@@ -95,6 +94,8 @@ func RegisterVmInterface(region string, vmi VmInterface) {
 }
 
 func RegisterPaymentInterface(method string, payInterface PaymentInterface) {
+	interfacesMutex.Lock()
+	defer interfacesMutex.Unlock()
 	if paymentInterfaces[method] != nil {
 		log.Fatalf("Duplicate payment interface for method %s", method)
 	}
@@ -160,6 +161,7 @@ func Setup(cfgPath string) {
 	loadTemplates()
 	loadEmail()
 	loadPanelWidgets()
+	loadJobHandlers()
 
 	decoder = schema.NewDecoder()
 	decoder.IgnoreUnknownKeys(true)
@@ -183,6 +185,9 @@ func Setup(cfgPath string) {
 	router.HandleFunc("/panel{slash:/*}", RedirectHandler("/panel/dashboard"))
 	RegisterPanelHandler("/panel/dashboard", panelDashboard, false)
 	RegisterPanelHandler("/panel/vms", panelVirtualMachines, false)
+	RegisterPanelHandler("/panel/vms/bulk", panelVMBulk, true)
+	RegisterPanelHandler("/panel/transfer/{id:[0-9]+}/accept", panelTransferAccept, true)
+	RegisterPanelHandler("/panel/transfer/{id:[0-9]+}/decline", panelTransferDecline, true)
 	RegisterPanelHandler("/panel/newvm", panelNewVM, false)
 	RegisterPanelHandler("/panel/newvm/{region:[^/]+}", panelNewVMRegion, false)
 	RegisterPanelHandler("/panel/vm/{id:[0-9]+}", panelVM, false)
@@ -194,8 +199,31 @@ func Setup(cfgPath string) {
 	RegisterPanelHandler("/panel/vm/{id:[0-9]+}/vnc", panelVMVnc, false)
 	RegisterPanelHandler("/panel/vm/{id:[0-9]+}/reimage", panelVMReimage, true)
 	RegisterPanelHandler("/panel/vm/{id:[0-9]+}/rename", panelVMRename, true)
+	RegisterPanelHandler("/panel/vm/{id:[0-9]+}/tags", panelVMTags, true)
+	RegisterPanelHandler("/panel/vm/{id:[0-9]+}/migrate", panelVMMigrate, true)
+	RegisterPanelHandler("/panel/vm/{id:[0-9]+}/clone", panelVMClone, true)
+	RegisterPanelHandler("/panel/vm/{id:[0-9]+}/unrescue", panelVMUnrescue, true)
+	RegisterPanelHandler("/panel/vm/{id:[0-9]+}/iso/mount", panelVMMountISO, true)
+	RegisterPanelHandler("/panel/vm/{id:[0-9]+}/iso/unmount", panelVMUnmountISO, true)
 	RegisterPanelHandler("/panel/vm/{id:[0-9]+}/snapshot", panelVMSnapshot, true)
 	RegisterPanelHandler("/panel/vm/{id:[0-9]+}/resize", panelVMResize, true)
+	RegisterPanelHandler("/panel/vm/{id:[0-9]+}/backups/add", panelVMBackupAdd, true)
+	RegisterPanelHandler("/panel/vm/{id:[0-9]+}/backup/{schedule:[0-9]+}/remove", panelVMBackupRemove, true)
+	RegisterPanelHandler("/panel/vm/{id:[0-9]+}/power/add", panelVMPowerScheduleAdd, true)
+	RegisterPanelHandler("/panel/vm/{id:[0-9]+}/power/{schedule:[0-9]+}/remove", panelVMPowerScheduleRemove, true)
+	RegisterPanelHandler("/panel/vm/{id:[0-9]+}/health/add", panelVMHealthCheckAdd, true)
+	RegisterPanelHandler("/panel/vm/{id:[0-9]+}/transfer", panelVMTransfer, true)
+	RegisterPanelHandler("/panel/vm/{id:[0-9]+}/transfer/cancel", panelVMTransferCancel, true)
+	RegisterPanelHandler("/panel/vm/{id:[0-9]+}/protection", panelVMProtection, true)
+	RegisterPanelHandler("/panel/vm/{id:[0-9]+}/health/{check:[0-9]+}/remove", panelVMHealthCheckRemove, true)
+	RegisterPanelHandler("/panel/vm/{id:[0-9]+}/firewall/add", panelVMFirewallAdd, true)
+	RegisterPanelHandler("/panel/vm/{id:[0-9]+}/firewall/{rule:[^/]+}/remove", panelVMFirewallRemove, true)
+	RegisterPanelHandler("/panel/vm/{id:[0-9]+}/volumes/attach", panelVMVolumeAttach, true)
+	RegisterPanelHandler("/panel/vm/{id:[0-9]+}/volume/{volume:[0-9]+}/detach", panelVMVolumeDetach, true)
+	RegisterPanelHandler("/panel/vm/{id:[0-9]+}/reserved_ips/attach", panelVMReservedIpAttach, true)
+	RegisterPanelHandler("/panel/vm/{id:[0-9]+}/reserved_ip/{reserved_ip:[0-9]+}/detach", panelVMReservedIpDetach, true)
+	RegisterPanelHandler("/panel/vm/{id:[0-9]+}/networks/attach", panelVMNetworkAttach, true)
+	RegisterPanelHandler("/panel/vm/{id:[0-9]+}/network/{network:[0-9]+}/detach", panelVMNetworkDetach, true)
 	RegisterPanelHandler("/panel/billing", panelBilling, false)
 	RegisterPanelHandler("/panel/pay", panelPay, false)
 	RegisterPanelHandler("/panel/charges", panelCharges, false)
@@ -208,6 +236,16 @@ func Setup(cfgPath string) {
 	RegisterPanelHandler("/panel/images/add", panelImageAdd, true)
 	RegisterPanelHandler("/panel/image/{id:[0-9]+}", panelImageDetails, false)
 	RegisterPanelHandler("/panel/image/{id:[0-9]+}/remove", panelImageRemove, true)
+	RegisterPanelHandler("/panel/volumes", panelVolumes, false)
+	RegisterPanelHandler("/panel/volumes/add", panelVolumeAdd, true)
+	RegisterPanelHandler("/panel/volume/{id:[0-9]+}/resize", panelVolumeResize, true)
+	RegisterPanelHandler("/panel/volume/{id:[0-9]+}/remove", panelVolumeRemove, true)
+	RegisterPanelHandler("/panel/reserved_ips", panelReservedIps, false)
+	RegisterPanelHandler("/panel/reserved_ips/add", panelReservedIpAdd, true)
+	RegisterPanelHandler("/panel/reserved_ip/{id:[0-9]+}/remove", panelReservedIpRemove, true)
+	RegisterPanelHandler("/panel/networks", panelNetworks, false)
+	RegisterPanelHandler("/panel/networks/add", panelNetworkAdd, true)
+	RegisterPanelHandler("/panel/network/{id:[0-9]+}/remove", panelNetworkRemove, true)
 	RegisterPanelHandler("/panel/keys", panelKeys, false)
 	RegisterPanelHandler("/panel/keys/add", panelKeyAdd, true)
 	RegisterPanelHandler("/panel/key/{id:[0-9]+}/remove", panelKeyRemove, true)
@@ -216,6 +254,7 @@ func Setup(cfgPath string) {
 	// api routes
 	RegisterAPIHandler("/api/vms", apiVMList, "GET")
 	RegisterAPIHandler("/api/vms", apiVMCreate, "POST")
+	RegisterAPIHandler("/api/vms/bulk", apiVMBulk, "POST")
 	RegisterAPIHandler("/api/vms/{id:[0-9]+}", apiVMInfo, "GET")
 	RegisterAPIHandler("/api/vms/{id:[0-9]+}/action", apiVMAction, "POST")
 	RegisterAPIHandler("/api/vms/{id:[0-9]+}/reimage", apiVMReimage, "POST")
@@ -225,10 +264,58 @@ func Setup(cfgPath string) {
 	RegisterAPIHandler("/api/vms/{id:[0-9]+}/ips/add", apiVMAddressAdd, "POST")
 	RegisterAPIHandler("/api/vms/{id:[0-9]+}/ips/remove", apiVMAddressRemove, "POST") // use POST instead of DELETE since we need both public/private ip
 	RegisterAPIHandler("/api/vms/{id:[0-9]+}/ips/{ip:[^/]+}/rdns", apiVMAddressRdns, "POST")
+	RegisterAPIHandler("/api/vms/{id:[0-9]+}/jobs", apiVMJobs, "GET")
+	RegisterAPIHandler("/api/vms/{id:[0-9]+}/console-log", apiVMConsoleLog, "GET")
+	RegisterAPIHandler("/api/vms/{id:[0-9]+}/metrics", apiVMMetrics, "GET")
+	RegisterAPIHandler("/api/vms/{id:[0-9]+}/tags", apiVMTags, "POST")
+	RegisterAPIHandler("/api/vms/{id:[0-9]+}/notes", apiVMNotes, "POST")
+	RegisterAPIHandler("/api/vms/{id:[0-9]+}/protection", apiVMProtection, "POST")
+	RegisterAPIHandler("/api/vms/{id:[0-9]+}/migrate", apiVMMigrate, "POST")
+	RegisterAPIHandler("/api/vms/{id:[0-9]+}/clone", apiVMClone, "POST")
+	RegisterAPIHandler("/api/vms/{id:[0-9]+}/password_reset", apiVMPasswordReset, "POST")
+	RegisterAPIHandler("/api/vms/{id:[0-9]+}/transfer", apiVMTransfer, "POST")
+	RegisterAPIHandler("/api/vms/{id:[0-9]+}/transfer", apiVMTransferCancel, "DELETE")
+	RegisterAPIHandler("/api/transfers", apiTransferList, "GET")
+	RegisterAPIHandler("/api/transfers/{id:[0-9]+}/accept", apiTransferAccept, "POST")
+	RegisterAPIHandler("/api/transfers/{id:[0-9]+}", apiTransferDecline, "DELETE")
+	RegisterAPIHandler("/api/vms/{id:[0-9]+}/backups", apiVMBackups, "GET")
+	RegisterAPIHandler("/api/vms/{id:[0-9]+}/backups", apiVMBackupAdd, "POST")
+	RegisterAPIHandler("/api/vms/{id:[0-9]+}/backups/{schedule:[0-9]+}", apiVMBackupRemove, "DELETE")
+	RegisterAPIHandler("/api/vms/{id:[0-9]+}/power_schedules", apiVMPowerSchedules, "GET")
+	RegisterAPIHandler("/api/vms/{id:[0-9]+}/power_schedules", apiVMPowerScheduleAdd, "POST")
+	RegisterAPIHandler("/api/vms/{id:[0-9]+}/power_schedules/{schedule:[0-9]+}", apiVMPowerScheduleRemove, "DELETE")
+	RegisterAPIHandler("/api/vms/{id:[0-9]+}/health_checks", apiVMHealthChecks, "GET")
+	RegisterAPIHandler("/api/vms/{id:[0-9]+}/health_checks", apiVMHealthCheckAdd, "POST")
+	RegisterAPIHandler("/api/vms/{id:[0-9]+}/health_checks/{check:[0-9]+}", apiVMHealthCheckRemove, "DELETE")
+	RegisterAPIHandler("/api/vms/{id:[0-9]+}/firewall", apiVMFirewall, "GET")
+	RegisterAPIHandler("/api/vms/{id:[0-9]+}/firewall", apiVMFirewallAdd, "POST")
+	RegisterAPIHandler("/api/vms/{id:[0-9]+}/firewall/{rule:[^/]+}", apiVMFirewallRemove, "DELETE")
+	RegisterAPIHandler("/api/vms/{id:[0-9]+}/volumes", apiVMVolumes, "GET")
+	RegisterAPIHandler("/api/vms/{id:[0-9]+}/volumes", apiVMVolumeAttach, "POST")
+	RegisterAPIHandler("/api/vms/{id:[0-9]+}/volumes/{volume:[0-9]+}", apiVMVolumeDetach, "DELETE")
+	RegisterAPIHandler("/api/vms/{id:[0-9]+}/reserved_ips", apiVMReservedIps, "GET")
+	RegisterAPIHandler("/api/vms/{id:[0-9]+}/reserved_ips", apiVMReservedIpAttach, "POST")
+	RegisterAPIHandler("/api/vms/{id:[0-9]+}/reserved_ips/{reserved_ip:[0-9]+}", apiVMReservedIpDetach, "DELETE")
+	RegisterAPIHandler("/api/vms/{id:[0-9]+}/networks", apiVMNetworks, "GET")
+	RegisterAPIHandler("/api/vms/{id:[0-9]+}/networks", apiVMNetworkAttach, "POST")
+	RegisterAPIHandler("/api/vms/{id:[0-9]+}/networks/{network:[0-9]+}", apiVMNetworkDetach, "DELETE")
 	RegisterAPIHandler("/api/images", apiImageList, "GET")
 	RegisterAPIHandler("/api/images", apiImageFetch, "POST")
 	RegisterAPIHandler("/api/images/{id:[0-9]+}", apiImageInfo, "GET")
 	RegisterAPIHandler("/api/images/{id:[0-9]+}", apiImageDelete, "DELETE")
+	RegisterAPIHandler("/api/volumes", apiVolumeList, "GET")
+	RegisterAPIHandler("/api/volumes", apiVolumeCreate, "POST")
+	RegisterAPIHandler("/api/volumes/{id:[0-9]+}", apiVolumeInfo, "GET")
+	RegisterAPIHandler("/api/volumes/{id:[0-9]+}", apiVolumeDelete, "DELETE")
+	RegisterAPIHandler("/api/volumes/{id:[0-9]+}/resize", apiVolumeResize, "POST")
+	RegisterAPIHandler("/api/reserved_ips", apiReservedIpList, "GET")
+	RegisterAPIHandler("/api/reserved_ips", apiReservedIpCreate, "POST")
+	RegisterAPIHandler("/api/reserved_ips/{id:[0-9]+}", apiReservedIpInfo, "GET")
+	RegisterAPIHandler("/api/reserved_ips/{id:[0-9]+}", apiReservedIpDelete, "DELETE")
+	RegisterAPIHandler("/api/networks", apiNetworkList, "GET")
+	RegisterAPIHandler("/api/networks", apiNetworkCreate, "POST")
+	RegisterAPIHandler("/api/networks/{id:[0-9]+}", apiNetworkInfo, "GET")
+	RegisterAPIHandler("/api/networks/{id:[0-9]+}", apiNetworkDelete, "DELETE")
 	RegisterAPIHandler("/api/plans", apiPlanList, "GET")
 	RegisterAPIHandler("/api/keys", apiKeyList, "GET")
 	RegisterAPIHandler("/api/keys", apiKeyAdd, "POST")
@@ -241,6 +328,7 @@ func Setup(cfgPath string) {
 	RegisterAdminHandler("/admin/user/{id:[0-9]+}/login", adminUserLogin, true)
 	RegisterAdminHandler("/admin/user/{id:[0-9]+}/credit", adminUserCredit, true)
 	RegisterAdminHandler("/admin/user/{id:[0-9]+}/password", adminUserPassword, true)
+	RegisterAdminHandler("/admin/user/{id:[0-9]+}/quotas", adminUserQuotas, true)
 	RegisterAdminHandler("/admin/user/{id:[0-9]+}/disable", adminUserDisable, true)
 	RegisterAdminHandler("/admin/user/{id:[0-9]+}/enable", adminUserEnable, true)
 	RegisterAdminHandler("/admin/vms", adminVirtualMachines, false)
@@ -257,9 +345,13 @@ func Setup(cfgPath string) {
 	RegisterAdminHandler("/admin/plan/{id:[0-9]+}/deassociate/{region:[^/]+}", adminPlanDeassociateRegion, true)
 	RegisterAdminHandler("/admin/plan/{id:[0-9]+}/set", adminPlanSetMetadata, true)
 	RegisterAdminHandler("/admin/plan/{id:[0-9]+}/unset", adminPlanUnsetMetadata, true)
+	RegisterAdminHandler("/admin/drift", adminDrift, false)
+	RegisterAdminHandler("/admin/drift/{id:[0-9]+}/dismiss", adminDriftDismiss, true)
 	RegisterAdminHandler("/admin/regions", adminRegions, false)
+	RegisterAdminHandler("/admin/regions/reload", adminRegionsReload, true)
 	RegisterAdminHandler("/admin/region/{region:[^/]+}/enable", adminRegionEnable, true)
 	RegisterAdminHandler("/admin/region/{region:[^/]+}/disable", adminRegionDisable, true)
+	RegisterAdminHandler("/admin/region/{region:[^/]+}/capacity", adminRegionCapacity, true)
 	RegisterAdminHandler("/admin/images", adminImages, false)
 	RegisterAdminHandler("/admin/images/add", adminImagesAdd, true)
 	RegisterAdminHandler("/admin/image/{id:[0-9]+}/delete", adminImageDelete, true)
@@ -293,6 +385,37 @@ func Run() {
 		}
 	}()
 
+	go func() {
+		for {
+			reconcile()
+			time.Sleep(RECONCILE_INTERVAL * time.Minute)
+		}
+	}()
+
+	// reload interfaces from configuration on SIGHUP
+	if interfaceLoader != nil {
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go func() {
+			for range hup {
+				reloadInterfacesSignal()
+			}
+		}()
+	}
+
+	// job queue, resuming any jobs that were interrupted by the last shutdown
+	jobResume()
+	go func() {
+		for {
+			jobProcess()
+			time.Sleep(5 * time.Second)
+		}
+	}()
+
+	routesMutex.Lock()
+	routesServing = true
+	routesMutex.Unlock()
+
 	httpServer := &http.Server{
 		Addr:    cfg.Http.Addr,
 		Handler: LobsterHandler(context.ClearHandler(router)),
@@ -318,13 +441,26 @@ func cron() {
 		userBilling(userId)
 	}
 
-	serviceBilling()
+	// each task recovers separately, so that a panic does not skip the tasks after it and the cleanup
+	cronTask(serviceBilling)
+	cronTask(backupCron)
+	cronTask(powerScheduleCron)
+	cronTask(healthCheckCron)
+	cronTask(metricsCron)
+	cronTask(drainCron)
 
 	// cleanup
 	db.Exec("DELETE FROM form_tokens WHERE time < DATE_SUB(NOW(), INTERVAL 1 HOUR)")
 	db.Exec("DELETE FROM sessions WHERE active_time < DATE_SUB(NOW(), INTERVAL 1 HOUR)")
 	db.Exec("DELETE FROM antiflood WHERE time < DATE_SUB(NOW(), INTERVAL 2 HOUR)")
 	db.Exec("DELETE FROM pwreset_tokens WHERE time < DATE_SUB(NOW(), INTERVAL ? MINUTE)", PWRESET_EXPIRE_MINUTES)
+	db.Exec("DELETE FROM jobs WHERE status IN ('done', 'error') AND time_updated < DATE_SUB(NOW(), INTERVAL ? DAY)", JOB_HISTORY_DAYS)
+	db.Exec("DELETE FROM vm_transfers WHERE time_created < DATE_SUB(NOW(), INTERVAL ? DAY)", VM_TRANSFER_EXPIRE_DAYS)
+}
+
+func cronTask(f func()) {
+	defer errorHandler(nil, nil, true)
+	f()
 }
 
 func cached() {
@@ -344,4 +480,6 @@ func cached() {
 			}
 		}
 	}
+
+	volumeUpdatePending()
 }
@@ -0,0 +1,192 @@
+package lobster
+
+import "log"
+import "time"
+
+// interface objects
+
+// Resource usage of a virtual machine, averaged over an interval starting at Time.
+type MetricSample struct {
+	Time       time.Time
+	Cpu        float64 // utilization in percent
+	DiskRead   float64 // bytes per second
+	DiskWrite  float64
+	NetworkIn  float64
+	NetworkOut float64
+}
+
+// time of the last collection, so that the VM interfaces are polled once per METRICS_RESOLUTION_FINE
+var metricsLastCollect time.Time
+
+// Averages samples into buckets of the specified resolution in seconds.
+// Returned samples are sorted by time, and their Time is set to the start of the bucket.
+func metricsAggregate(samples []*MetricSample, resolution int) []*MetricSample {
+	var buckets []*MetricSample
+	var counts []int
+	bucketIndex := make(map[int64]int)
+	for _, sample := range samples {
+		bucketTime := sample.Time.Unix() / int64(resolution) * int64(resolution)
+		idx, ok := bucketIndex[bucketTime]
+		if !ok {
+			idx = len(buckets)
+			bucketIndex[bucketTime] = idx
+			buckets = append(buckets, &MetricSample{Time: time.Unix(bucketTime, 0).UTC()})
+			counts = append(counts, 0)
+		}
+		bucket := buckets[idx]
+		bucket.Cpu += sample.Cpu
+		bucket.DiskRead += sample.DiskRead
+		bucket.DiskWrite += sample.DiskWrite
+		bucket.NetworkIn += sample.NetworkIn
+		bucket.NetworkOut += sample.NetworkOut
+		counts[idx]++
+	}
+
+	for i, bucket := range buckets {
+		n := float64(counts[i])
+		bucket.Cpu /= n
+		bucket.DiskRead /= n
+		bucket.DiskWrite /= n
+		bucket.NetworkIn /= n
+		bucket.NetworkOut /= n
+	}
+
+	// insertion sort, since samples are usually already ordered
+	for i := 1; i < len(buckets); i++ {
+		for j := i; j > 0 && buckets[j].Time.Before(buckets[j-1].Time); j-- {
+			buckets[j], buckets[j-1] = buckets[j-1], buckets[j]
+		}
+	}
+	return buckets
+}
+
+func metricsListHelper(rows Rows) []*MetricSample {
+	defer rows.Close()
+	samples := make([]*MetricSample, 0)
+	for rows.Next() {
+		sample := MetricSample{}
+		rows.Scan(&sample.Time, &sample.Cpu, &sample.DiskRead, &sample.DiskWrite, &sample.NetworkIn, &sample.NetworkOut)
+		samples = append(samples, &sample)
+	}
+	return samples
+}
+
+func metricsList(vmId int, resolution int, start time.Time, end time.Time) []*MetricSample {
+	return metricsListHelper(
+		db.Query(
+			"SELECT time, cpu, disk_read, disk_write, network_in, network_out FROM vm_metrics "+
+				"WHERE vm_id = ? AND resolution = ? AND time >= ? AND time < ? ORDER BY time",
+			vmId, resolution, start.UTC(), end.UTC(),
+		),
+	)
+}
+
+func metricsInsert(vmId int, resolution int, sample *MetricSample) {
+	db.Exec(
+		"REPLACE INTO vm_metrics (vm_id, resolution, time, cpu, disk_read, disk_write, network_in, network_out) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		vmId, resolution, sample.Time.UTC(), sample.Cpu, sample.DiskRead, sample.DiskWrite, sample.NetworkIn, sample.NetworkOut,
+	)
+}
+
+// Selects the finest resolution that is still retained for the start of the range.
+func metricsResolution(start time.Time, end time.Time) int {
+	age := time.Since(start)
+	if age <= METRICS_RETENTION_FINE*time.Hour && end.Sub(start) <= 48*time.Hour {
+		return METRICS_RESOLUTION_FINE
+	} else if age <= METRICS_RETENTION_HOURLY*time.Hour {
+		return METRICS_RESOLUTION_HOURLY
+	} else {
+		return METRICS_RESOLUTION_DAILY
+	}
+}
+
+// Returns the resolution in seconds and the stored samples of the virtual machine between start and end.
+func (vm *VirtualMachine) Metrics(start time.Time, end time.Time) (int, []*MetricSample, error) {
+	if !end.After(start) {
+		return 0, nil, L.Error("invalid_metrics_range")
+	} else if _, ok := vmGetInterface(vm.Region).(VMIMetrics); !ok {
+		return 0, nil, L.Error("operation_unsupported")
+	}
+	resolution := metricsResolution(start, end)
+	return resolution, metricsList(vm.Id, resolution, start, end), nil
+}
+
+// Collects samples from the VM interfaces, and updates rollups.
+// Called from cron; collection only happens once per METRICS_RESOLUTION_FINE.
+func metricsCron() {
+	now := time.Now().UTC()
+	if now.Sub(metricsLastCollect) < METRICS_RESOLUTION_FINE*time.Second {
+		return
+	}
+	metricsLastCollect = now
+
+	for _, vm := range vmListAll() {
+		if vm.Identification == "" || vm.Status != "active" {
+			continue
+		}
+		vmi, ok := regionInterface(vm.Region).(VMIMetrics)
+		if ok {
+			metricsCollect(vm, vmi, now)
+		}
+	}
+
+	metricsRollup(METRICS_RESOLUTION_FINE, METRICS_RESOLUTION_HOURLY, now.Add(-3*time.Hour))
+	metricsRollup(METRICS_RESOLUTION_HOURLY, METRICS_RESOLUTION_DAILY, now.Add(-48*time.Hour))
+
+	db.Exec("DELETE FROM vm_metrics WHERE resolution = ? AND time < ?", METRICS_RESOLUTION_FINE, now.Add(-METRICS_RETENTION_FINE*time.Hour))
+	db.Exec("DELETE FROM vm_metrics WHERE resolution = ? AND time < ?", METRICS_RESOLUTION_HOURLY, now.Add(-METRICS_RETENTION_HOURLY*time.Hour))
+	db.Exec("DELETE FROM vm_metrics WHERE resolution = ? AND time < ?", METRICS_RESOLUTION_DAILY, now.Add(-METRICS_RETENTION_DAILY*time.Hour))
+}
+
+// Stores complete fine-resolution buckets since the last stored bucket of the virtual machine.
+func metricsCollect(vm *VirtualMachine, vmi VMIMetrics, now time.Time) {
+	since := now.Add(-time.Hour)
+	rows := db.Query("SELECT time FROM vm_metrics WHERE vm_id = ? AND resolution = ? ORDER BY time DESC LIMIT 1", vm.Id, METRICS_RESOLUTION_FINE)
+	if rows.Next() {
+		var lastTime time.Time
+		rows.Scan(&lastTime)
+		if lastTime.After(since) {
+			since = lastTime.Add(METRICS_RESOLUTION_FINE * time.Second)
+		}
+	}
+	rows.Close()
+
+	samples, err := vmi.VmMetrics(vm, since)
+	if err != nil {
+		// not reported, since a backend outage would otherwise generate an error for every VM
+		log.Printf("Failed to collect metrics for VM %d: %s", vm.Id, err.Error())
+		return
+	}
+
+	for _, sample := range metricsAggregate(samples, METRICS_RESOLUTION_FINE) {
+		if sample.Time.Before(since) || sample.Time.Add(METRICS_RESOLUTION_FINE*time.Second).After(now) {
+			// skip buckets that we already have or that are not yet complete
+			continue
+		}
+		metricsInsert(vm.Id, METRICS_RESOLUTION_FINE, sample)
+	}
+}
+
+// Recomputes rollups at resolution to from samples at resolution from, for buckets starting after since.
+// Buckets are recomputed on every run, so that incomplete buckets are updated as samples are added.
+func metricsRollup(from int, to int, since time.Time) {
+	start := time.Unix(since.Unix()/int64(to)*int64(to), 0).UTC()
+	rows := db.Query(
+		"SELECT vm_id, time, cpu, disk_read, disk_write, network_in, network_out FROM vm_metrics WHERE resolution = ? AND time >= ?",
+		from, start,
+	)
+	vmSamples := make(map[int][]*MetricSample)
+	for rows.Next() {
+		var vmId int
+		sample := MetricSample{}
+		rows.Scan(&vmId, &sample.Time, &sample.Cpu, &sample.DiskRead, &sample.DiskWrite, &sample.NetworkIn, &sample.NetworkOut)
+		vmSamples[vmId] = append(vmSamples[vmId], &sample)
+	}
+	rows.Close()
+
+	for vmId, samples := range vmSamples {
+		for _, sample := range metricsAggregate(samples, to) {
+			metricsInsert(vmId, to, sample)
+		}
+	}
+}
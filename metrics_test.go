@@ -0,0 +1,23 @@
+package lobster
+
+import "testing"
+import "time"
+
+func TestMetricsAggregate(t *testing.T) {
+	base := time.Unix(1500000000/300*300, 0).UTC()
+	samples := []*MetricSample{
+		{Time: base.Add(6 * time.Minute), Cpu: 30, NetworkIn: 300},
+		{Time: base, Cpu: 10, NetworkIn: 100},
+		{Time: base.Add(time.Minute), Cpu: 20, NetworkIn: 200},
+	}
+	buckets := metricsAggregate(samples, 300)
+	if len(buckets) != 2 {
+		t.Fatalf("Expected 2 buckets, got %d", len(buckets))
+	} else if !buckets[0].Time.Equal(base) || !buckets[1].Time.Equal(base.Add(5*time.Minute)) {
+		t.Fatalf("Unexpected bucket times %v, %v", buckets[0].Time, buckets[1].Time)
+	} else if buckets[0].Cpu != 15 || buckets[0].NetworkIn != 150 {
+		t.Fatalf("First bucket averages incorrect (cpu=%f, network_in=%f)", buckets[0].Cpu, buckets[0].NetworkIn)
+	} else if buckets[1].Cpu != 30 {
+		t.Fatalf("Second bucket cpu %f does not match 30", buckets[1].Cpu)
+	}
+}
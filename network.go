@@ -0,0 +1,266 @@
+package lobster
+
+import "github.com/LunaNode/lobster/ipaddr"
+
+import "log"
+import "net"
+import "time"
+
+// database objects
+
+// Private network in a region that the user's virtual machines can be attached to.
+type Network struct {
+	Id             int
+	UserId         int
+	Region         string
+	Name           string
+	Cidr           string
+	Identification string
+	CreatedTime    time.Time
+
+	VmIds []int // set by networkList and networkGet
+}
+
+func networkListHelper(rows Rows) []*Network {
+	defer rows.Close()
+	networks := make([]*Network, 0)
+	for rows.Next() {
+		network := Network{}
+		rows.Scan(&network.Id, &network.UserId, &network.Region, &network.Name, &network.Cidr, &network.Identification, &network.CreatedTime)
+		networks = append(networks, &network)
+	}
+	return networks
+}
+
+const NETWORK_QUERY = "SELECT id, user_id, region, name, cidr, identification, time_created FROM networks"
+
+// Sets the member virtual machines on the networks.
+func networkLoadVms(networks []*Network) {
+	for _, network := range networks {
+		network.VmIds = make([]int, 0)
+		rows := db.Query("SELECT vm_id FROM network_vms WHERE network_id = ? ORDER BY vm_id", network.Id)
+		for rows.Next() {
+			var vmId int
+			rows.Scan(&vmId)
+			network.VmIds = append(network.VmIds, vmId)
+		}
+		rows.Close()
+	}
+}
+
+func (network *Network) HasVm(vmId int) bool {
+	for _, id := range network.VmIds {
+		if id == vmId {
+			return true
+		}
+	}
+	return false
+}
+
+func networkList(userId int) []*Network {
+	networks := networkListHelper(db.Query(NETWORK_QUERY+" WHERE user_id = ? ORDER BY region, name", userId))
+	networkLoadVms(networks)
+	return networks
+}
+
+// Returns networks of the user in the virtual machine's region that it is not attached to.
+func networkListAvailable(vm *VirtualMachine) []*Network {
+	return networkListHelper(db.Query(
+		NETWORK_QUERY+" WHERE user_id = ? AND region = ? AND id NOT IN (SELECT network_id FROM network_vms WHERE vm_id = ?) ORDER BY name",
+		vm.UserId, vm.Region, vm.Id,
+	))
+}
+
+// Returns the networks that the virtual machine is attached to.
+func networkListVm(vmId int) []*Network {
+	return networkListHelper(db.Query(NETWORK_QUERY+" WHERE id IN (SELECT network_id FROM network_vms WHERE vm_id = ?) ORDER BY name", vmId))
+}
+
+func networkGet(userId int, networkId int) *Network {
+	networks := networkListHelper(db.Query(NETWORK_QUERY+" WHERE id = ? AND user_id = ?", networkId, userId))
+	if len(networks) == 1 {
+		networkLoadVms(networks)
+		return networks[0]
+	} else {
+		return nil
+	}
+}
+
+// Returns whether networks can be created in the region.
+func regionCanNetworks(region string) bool {
+	_, ok := regionInterface(region).(VMINetworks)
+	return ok
+}
+
+// Parses a private IPv4 network for use as a network's subnet.
+// Returns nil if the CIDR is invalid, not private, or outside the allowed prefix lengths.
+func networkParseCidr(cidr string) *net.IPNet {
+	ip, network, err := net.ParseCIDR(cidr)
+	if err != nil || ip.To4() == nil || !ip.Equal(network.IP) {
+		return nil
+	}
+	ones, _ := network.Mask.Size()
+	if ones < MIN_NETWORK_PREFIX || ones > MAX_NETWORK_PREFIX {
+		return nil
+	}
+
+	// the private ranges are at least /16, so the subnet is private if its first address is
+	if !ipaddr.IsPrivate(network.IP.String()) {
+		return nil
+	}
+	return network
+}
+
+func networkCreate(userId int, region string, name string, cidr string) (int, error) {
+	err := vmNameOk(name)
+	if err != nil {
+		return 0, err
+	}
+	subnet := networkParseCidr(cidr)
+	if subnet == nil {
+		return 0, L.Errorf("invalid_network_cidr", MIN_NETWORK_PREFIX, MAX_NETWORK_PREFIX)
+	}
+
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM networks WHERE user_id = ?", userId).Scan(&count)
+	if count >= MAX_NETWORKS {
+		return 0, L.Errorf("exceeded_network_limit", MAX_NETWORKS)
+	}
+
+	// validate region
+	vmi := regionInterface(region)
+	if vmi == nil || !regionEnabled(region) {
+		return 0, L.Error("invalid_region")
+	}
+	vmiNetworks, ok := vmi.(VMINetworks)
+	if !ok {
+		return 0, L.Error("operation_unsupported")
+	}
+
+	log.Printf("networkCreate(%d, %s, %s, %s)", userId, region, name, subnet.String())
+	network := &Network{
+		UserId: userId,
+		Region: region,
+		Name:   name,
+		Cidr:   subnet.String(),
+	}
+	networkIdentification, err := vmiNetworks.NetworkCreate(network)
+	if err != nil {
+		return 0, err
+	}
+	result := db.Exec(
+		"INSERT INTO networks (user_id, region, name, cidr, identification) VALUES (?, ?, ?, ?, ?)",
+		userId, region, name, network.Cidr, networkIdentification,
+	)
+	return result.LastInsertId(), nil
+}
+
+func networkDelete(userId int, networkId int) error {
+	network := networkGet(userId, networkId)
+	if network == nil {
+		return L.Error("invalid_network")
+	} else if len(network.VmIds) > 0 {
+		return L.Error("network_in_use")
+	}
+
+	vmi, ok := vmGetInterface(network.Region).(VMINetworks)
+	if !ok {
+		return L.Error("operation_unsupported")
+	}
+
+	log.Printf("networkDelete(%d, %d)", userId, networkId)
+	err := vmi.NetworkDelete(network)
+	if err != nil {
+		return err
+	}
+	db.Exec("DELETE FROM networks WHERE id = ?", network.Id)
+	return nil
+}
+
+// Deletes the network even if virtual machines are attached, e.g. when the account is terminated.
+// The back-end network is deleted by a job, which is retried until the deletion of attached virtual machines
+// (also done by jobs) has removed their interfaces.
+func networkDeleteForce(networkId int) {
+	networks := networkListHelper(db.Query(NETWORK_QUERY+" WHERE id = ?", networkId))
+	if len(networks) != 1 {
+		return
+	}
+	network := networks[0]
+
+	log.Printf("networkDeleteForce(%d)", network.Id)
+	if _, ok := regionInterface(network.Region).(VMINetworks); ok {
+		jobCreate(network.UserId, 0, "networkDelete", networkDeleteJobData{Region: network.Region, Name: network.Name, Identification: network.Identification})
+	}
+	db.Exec("DELETE FROM network_vms WHERE network_id = ?", network.Id)
+	db.Exec("DELETE FROM networks WHERE id = ?", network.Id)
+}
+
+type networkDeleteJobData struct {
+	Region         string
+	Name           string
+	Identification string
+}
+
+func networkDeleteJob(job *Job) error {
+	var data networkDeleteJobData
+	err := job.Decode(&data)
+	if err != nil {
+		return err
+	}
+	vmi, ok := vmGetInterface(data.Region).(VMINetworks)
+	if !ok {
+		return L.Error("operation_unsupported")
+	}
+	return vmi.NetworkDelete(&Network{
+		UserId:         job.UserId,
+		Region:         data.Region,
+		Name:           data.Name,
+		Identification: data.Identification,
+	})
+}
+
+func (vm *VirtualMachine) AttachNetwork(networkId int) error {
+	network := networkGet(vm.UserId, networkId)
+	if network == nil {
+		return L.Error("invalid_network")
+	} else if network.Region != vm.Region {
+		return L.Error("network_wrong_region")
+	} else if network.HasVm(vm.Id) {
+		return L.Error("network_already_attached")
+	}
+
+	log.Printf("vmAttachNetwork(%d, %d)", vm.Id, networkId)
+	return vm.do(func(vm *VirtualMachine) error {
+		vmi, ok := vmGetInterface(vm.Region).(VMINetworks)
+		if !ok {
+			return L.Error("operation_unsupported")
+		}
+		err := vmi.NetworkAttach(vm, network)
+		if err != nil {
+			return err
+		}
+		db.Exec("INSERT IGNORE INTO network_vms (network_id, vm_id) VALUES (?, ?)", network.Id, vm.Id)
+		return nil
+	})
+}
+
+func (vm *VirtualMachine) DetachNetwork(networkId int) error {
+	network := networkGet(vm.UserId, networkId)
+	if network == nil || !network.HasVm(vm.Id) {
+		return L.Error("invalid_network")
+	}
+
+	log.Printf("vmDetachNetwork(%d, %d)", vm.Id, networkId)
+	return vm.do(func(vm *VirtualMachine) error {
+		vmi, ok := vmGetInterface(vm.Region).(VMINetworks)
+		if !ok {
+			return L.Error("operation_unsupported")
+		}
+		err := vmi.NetworkDetach(vm, network)
+		if err != nil {
+			return err
+		}
+		db.Exec("DELETE FROM network_vms WHERE network_id = ? AND vm_id = ?", network.Id, vm.Id)
+		return nil
+	})
+}
@@ -0,0 +1,82 @@
+package lobster
+
+import "testing"
+
+func TestNetworkParseCidr(t *testing.T) {
+	valid := map[string]string{
+		"10.0.0.0/24":    "10.0.0.0/24",
+		"192.168.8.0/29": "192.168.8.0/29",
+		"172.16.0.0/16":  "172.16.0.0/16",
+	}
+	for cidr, expected := range valid {
+		network := networkParseCidr(cidr)
+		if network == nil || network.String() != expected {
+			t.Fatalf("Expected %s to parse as %s, got %v", cidr, expected, network)
+		}
+	}
+
+	// host bits set, public, too large or small, IPv6, and missing prefix
+	for _, cidr := range []string{"10.0.0.1/24", "8.8.8.0/24", "10.0.0.0/8", "10.0.0.0/30", "fd00::/64", "10.0.0.0"} {
+		if networkParseCidr(cidr) != nil {
+			t.Fatalf("Expected %s to be rejected", cidr)
+		}
+	}
+}
+
+type testNetworkVmi struct {
+	TestVmi
+	deleted []string
+}
+
+func (this *testNetworkVmi) NetworkCreate(network *Network) (string, error) {
+	return "", nil
+}
+
+func (this *testNetworkVmi) NetworkDelete(network *Network) error {
+	this.deleted = append(this.deleted, network.Identification)
+	return nil
+}
+
+func (this *testNetworkVmi) NetworkAttach(vm *VirtualMachine, network *Network) error {
+	return nil
+}
+
+func (this *testNetworkVmi) NetworkDetach(vm *VirtualMachine, network *Network) error {
+	return nil
+}
+
+func TestBillingTerminateNetworks(t *testing.T) {
+	TestReset()
+	cfg.BillingNotifications.LowBalanceIntervals = 1
+	vmi := &testNetworkVmi{}
+	defer TestRegion("testnetwork", vmi)()
+
+	// the user has a virtual machine on a network, and is past the termination threshold
+	userId := TestUser()
+	db.Exec("UPDATE users SET credit = -1000, billing_low_count = 10 WHERE id = ?", userId)
+	vmId := TestVm(userId)
+	db.Exec("UPDATE vms SET region = 'testnetwork', identification = 'vm' WHERE id = ?", vmId)
+	networkId := db.Exec("INSERT INTO networks (user_id, region, name, cidr, identification) VALUES (?, 'testnetwork', 'a', '10.0.0.0/24', 'a')", userId).LastInsertId()
+	db.Exec("INSERT INTO network_vms (network_id, vm_id) VALUES (?, ?)", networkId, vmId)
+
+	testForceUserBilling(userId)
+	if len(networkList(userId)) != 0 {
+		t.Fatal("Network not deleted on account termination")
+	}
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM network_vms WHERE network_id = ?", networkId).Scan(&count)
+	if count != 0 {
+		t.Fatal("Network members not deleted on account termination")
+	}
+
+	// the back-end network is deleted by a job
+	var jobId int
+	db.QueryRow("SELECT id FROM jobs WHERE user_id = ? AND kind = 'networkDelete'", userId).Scan(&jobId)
+	if jobId == 0 {
+		t.Fatal("No job to delete the network on the back-end")
+	} else if err := networkDeleteJob(jobGet(jobId)); err != nil {
+		t.Fatalf("Network delete job failed: %v", err)
+	} else if len(vmi.deleted) != 1 || vmi.deleted[0] != "a" {
+		t.Fatalf("Expected network to be deleted on the back-end, got %v", vmi.deleted)
+	}
+}
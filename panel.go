@@ -7,6 +7,7 @@ import "github.com/gorilla/mux"
 import "fmt"
 import "net/http"
 import "strconv"
+import "strings"
 import "time"
 
 type FrameParams struct {
@@ -53,6 +54,7 @@ type PanelDashboardParams struct {
 	VirtualMachines  []*VirtualMachine
 	CreditSummary    *CreditSummary
 	BandwidthSummary map[string]*BandwidthSummary
+	Quotas           []*QuotaResource
 	WidgetData       map[string]interface{}
 }
 
@@ -62,6 +64,7 @@ func panelDashboard(w http.ResponseWriter, r *http.Request, session *Session, fr
 	params.VirtualMachines = vmList(session.UserId)
 	params.CreditSummary = UserCreditSummary(session.UserId)
 	params.BandwidthSummary = UserBandwidthSummary(session.UserId)
+	params.Quotas = userQuotaSummary(session.UserId)
 	params.WidgetData = make(map[string]interface{})
 	for name, widget := range panelWidgets {
 		params.WidgetData[name] = widget.Prepare(session)
@@ -72,24 +75,91 @@ func panelDashboard(w http.ResponseWriter, r *http.Request, session *Session, fr
 type PanelVirtualMachinesParams struct {
 	Frame           FrameParams
 	VirtualMachines []*VirtualMachine
+	Tags            []string
+	Tag             string // the tag being filtered on, if any
+	Plans           []*Plan
+	Transfers       []*VmTransfer // incoming
+	Token           string
 }
 
 func panelVirtualMachines(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
 	params := PanelVirtualMachinesParams{}
 	params.Frame = frameParams
-	params.VirtualMachines = vmList(session.UserId)
+	params.Tag = r.URL.Query().Get("tag")
+	if params.Tag != "" {
+		params.VirtualMachines = vmListTag(session.UserId, params.Tag)
+	} else {
+		params.VirtualMachines = vmList(session.UserId)
+	}
+	params.Tags = tagList(session.UserId)
+	for _, plan := range planList() {
+		if plan.Enabled {
+			params.Plans = append(params.Plans, plan)
+		}
+	}
+	params.Transfers = vmTransferListIncoming(session.UserId)
+	params.Token = CSRFGenerate(session)
 	RenderTemplate(w, "panel", "vms", params)
 }
 
+type VMBulkForm struct {
+	VmIds  []int  `schema:"vm_ids"`
+	Action string `schema:"action"`
+	Tags   string `schema:"tags"`
+	PlanId int    `schema:"plan_id"`
+}
+
+func panelVMBulk(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	form := new(VMBulkForm)
+	err := decoder.Decode(form, r.PostForm)
+	if err != nil {
+		http.Redirect(w, r, "/panel/vms", 303)
+		return
+	}
+
+	value := ""
+	if form.Action == "tag" {
+		value = form.Tags
+	} else if form.Action == "resize" {
+		value = strconv.Itoa(form.PlanId)
+	}
+
+	results, err := vmBulkAction(session.UserId, form.VmIds, form.Action, value)
+	if err != nil {
+		RedirectMessage(w, r, "/panel/vms", L.FormatError(err))
+		return
+	}
+
+	var failures []string
+	for _, result := range results {
+		if result.Error != nil {
+			failures = append(failures, fmt.Sprintf("%s (%d): %s", result.Name, result.VmId, result.Error.Error()))
+		} else {
+			LogAction(session.UserId, ExtractIP(r.RemoteAddr), "Bulk VM action", fmt.Sprintf("VM ID: %d; Action: %s; Value: %s", result.VmId, form.Action, value))
+		}
+	}
+	if len(failures) > 0 {
+		RedirectMessage(w, r, "/panel/vms", L.FormatError(L.Errorf("vm_bulk_failed", len(failures), len(results), strings.Join(failures, "; "))))
+	} else {
+		RedirectMessage(w, r, "/panel/vms", L.Successf("vm_bulk_success", form.Action, len(results)))
+	}
+}
+
 type PanelNewVMParams struct {
 	Frame   FrameParams
 	Regions []string
+	SoldOut map[string]bool // regions where no plan is available
 }
 
 func panelNewVM(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
 	params := PanelNewVMParams{}
 	params.Frame = frameParams
 	params.Regions = regionList()
+	params.SoldOut = make(map[string]bool)
+	for _, region := range params.Regions {
+		plans := planListRegion(region)
+		params.SoldOut[region] = len(plans) > 0 && len(regionSoldOutPlans(region, plans)) == len(plans)
+	}
 	RenderTemplate(w, "panel", "newvm", params)
 }
 
@@ -99,14 +169,18 @@ type PanelNewVMRegionParams struct {
 	PublicImages []*Image
 	UserImages   []*Image
 	Plans        []*Plan
+	SoldOut      map[int]bool // plans that cannot currently be provisioned
 	Keys         []*SSHKey
+	CanUserData  bool
+	UserDataKeys bool // whether selected keys are still authorized when user-data is provided
 	Token        string
 }
 type NewVMRegionForm struct {
-	Name    string `schema:"name"`
-	PlanId  int    `schema:"plan_id"`
-	ImageId int    `schema:"image_id"`
-	KeyId   int    `schema:"key_id"`
+	Name     string `schema:"name"`
+	PlanId   int    `schema:"plan_id"`
+	ImageId  int    `schema:"image_id"`
+	KeyIds   []int  `schema:"key_ids"`
+	UserData string `schema:"user_data"`
 }
 
 func panelNewVMRegion(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
@@ -120,7 +194,7 @@ func panelNewVMRegion(w http.ResponseWriter, r *http.Request, session *Session,
 			return
 		}
 
-		vmId, err := vmCreate(session.UserId, form.Name, form.PlanId, form.ImageId, VmCreateOptions{KeyID: form.KeyId})
+		vmId, err := vmCreate(session.UserId, form.Name, form.PlanId, form.ImageId, VmCreateOptions{KeyIDs: form.KeyIds, UserData: form.UserData})
 		if err != nil {
 			RedirectMessage(w, r, "/panel/newvm/"+region, L.FormatError(err))
 		} else {
@@ -134,7 +208,10 @@ func panelNewVMRegion(w http.ResponseWriter, r *http.Request, session *Session,
 	params.Frame = frameParams
 	params.Region = region
 	params.Plans = planListRegion(region)
+	params.SoldOut = regionSoldOutPlans(region, params.Plans)
 	params.Keys = keyList(session.UserId)
+	params.CanUserData = regionCanUserData(region)
+	params.UserDataKeys = regionCanUserDataKeys(region)
 	params.Token = CSRFGenerate(session)
 
 	for _, image := range imageListRegion(session.UserId, region) {
@@ -149,11 +226,31 @@ func panelNewVMRegion(w http.ResponseWriter, r *http.Request, session *Session,
 }
 
 type PanelVMParams struct {
-	Frame  FrameParams
-	Vm     *VirtualMachine
-	Images []*Image
-	Plans  []*Plan
-	Token  string
+	Frame              FrameParams
+	Vm                 *VirtualMachine
+	Images             []*Image
+	ISOImages          []*Image // active images in the region, which can be mounted in rescue mode
+	Plans              []*Plan
+	Jobs               []*Job
+	Keys               []*SSHKey
+	BackupSchedules    []*BackupSchedule
+	PowerSchedules     []*PowerSchedule
+	HealthChecks       []*HealthCheck
+	Transfer           *VmTransfer // pending outgoing transfer, if any
+	Backups            []*Image
+	Volumes            []*Volume
+	AvailableVolumes   []*Volume
+	ReservedIps        []*ReservedIp
+	AvailableIps       []*ReservedIp
+	Networks           []*Network
+	AvailableNetworks  []*Network
+	Notes              string
+	ConfirmName        bool // whether dangerous actions require the name to be typed
+	MigrateRegions     []*MigrateRegion
+	CanReimageUserData bool
+	CanReimageKeys     bool
+	Rescue             string // VM_RESCUE_RESCUE or VM_RESCUE_ISO if in rescue mode, or empty otherwise
+	Token              string
 }
 
 func panelVM(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
@@ -168,6 +265,13 @@ func panelVM(w http.ResponseWriter, r *http.Request, session *Session, framePara
 		return
 	}
 	vm.LoadInfo()
+	vm.LoadTags()
+	if vm.Info.CanFirewall {
+		err := vm.LoadFirewallRules()
+		if err != nil {
+			frameParams.Message = L.FormatError(err)
+		}
+	}
 
 	frameParams.Styles = []string{"ladda"}
 	frameParams.Scripts = []string{"spin", "ladda", "lobstervm"}
@@ -175,7 +279,31 @@ func panelVM(w http.ResponseWriter, r *http.Request, session *Session, framePara
 	params.Frame = frameParams
 	params.Vm = vm
 	params.Images = imageListRegion(session.UserId, vm.Region)
+	for _, image := range params.Images {
+		if image.Status == "active" {
+			params.ISOImages = append(params.ISOImages, image)
+		}
+	}
 	params.Plans = planListRegion(vm.Region)
+	params.Jobs = jobListVm(vm.Id)
+	params.Keys = keyList(session.UserId)
+	params.BackupSchedules = backupScheduleList(vm.Id)
+	params.Backups = backupImageList(vm.Id)
+	params.PowerSchedules = powerScheduleList(vm.Id)
+	params.HealthChecks = healthCheckList(vm.Id)
+	params.Transfer = vmTransferGetVm(vm.Id)
+	params.Volumes = volumeListVm(vm.Id)
+	params.AvailableVolumes = volumeListAvailable(session.UserId, vm.Region)
+	params.ReservedIps = reservedIpListVm(vm.Id)
+	params.AvailableIps = reservedIpListAvailable(session.UserId, vm.Region)
+	params.Networks = networkListVm(vm.Id)
+	params.AvailableNetworks = networkListAvailable(vm)
+	params.Notes = vm.Notes()
+	params.ConfirmName = vm.ConfirmName()
+	params.MigrateRegions = migrateRegionList(vm.Region)
+	params.CanReimageUserData = regionCanReimageUserData(vm.Region)
+	params.CanReimageKeys = regionCanReimageKeys(vm.Region)
+	params.Rescue = vm.RescueState()
 	params.Token = CSRFGenerate(session)
 	RenderTemplate(w, "panel", "vm", params)
 }
@@ -236,8 +364,12 @@ func panelVMDelete(w http.ResponseWriter, r *http.Request, session *Session, fra
 	vm, err := panelVMProcess(r, session)
 	if err != nil {
 		RedirectMessage(w, r, "/panel/vms", L.FormatError(err))
+		return
+	}
+	err = vm.checkConfirmName(r.PostFormValue("confirm_name"))
+	if err == nil {
+		err = vm.Delete(session.UserId)
 	}
-	err = vm.Delete(session.UserId)
 	if err != nil {
 		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.FormatError(err))
 	} else {
@@ -275,7 +407,10 @@ func panelVMVnc(w http.ResponseWriter, r *http.Request, session *Session, frameP
 }
 
 type VMReimageForm struct {
-	Image int `schema:"image"`
+	Image       int    `schema:"image"`
+	KeyIds      []int  `schema:"key_ids"`
+	UserData    string `schema:"user_data"`
+	ConfirmName string `schema:"confirm_name"`
 }
 
 func panelVMReimage(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
@@ -292,7 +427,15 @@ func panelVMReimage(w http.ResponseWriter, r *http.Request, session *Session, fr
 		return
 	}
 
-	err = vmReimage(session.UserId, vmId, form.Image)
+	vm := vmGetUser(session.UserId, vmId)
+	if vm == nil {
+		RedirectMessage(w, r, "/panel/vms", L.FormattedError("vm_not_found"))
+		return
+	}
+	err = vm.checkConfirmName(form.ConfirmName)
+	if err == nil {
+		err = vmReimage(session.UserId, vmId, form.Image, VmReimageOptions{KeyIDs: form.KeyIds, UserData: form.UserData})
+	}
 	if err != nil {
 		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vmId), L.FormatError(err))
 	} else {
@@ -316,240 +459,1002 @@ func panelVMSnapshot(w http.ResponseWriter, r *http.Request, session *Session, f
 	}
 }
 
-type VMResizeForm struct {
-	PlanId int `schema:"plan_id"`
+type VMBackupAddForm struct {
+	Frequency string `schema:"frequency"`
+	Hour      int    `schema:"hour"`
+	Weekday   int    `schema:"weekday"`
+	Retention int    `schema:"retention"`
 }
 
-func panelVMResize(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+func panelVMBackupAdd(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
 	vm, err := panelVMProcess(r, session)
 	if err != nil {
 		RedirectMessage(w, r, "/panel/vms", L.FormatError(err))
+		return
 	}
 
-	form := new(VMResizeForm)
+	form := new(VMBackupAddForm)
 	err = decoder.Decode(form, r.PostForm)
 	if err != nil {
 		http.Redirect(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), 303)
 		return
 	}
 
-	err = vm.Resize(form.PlanId)
+	_, err = vm.AddBackupSchedule(form.Frequency, form.Hour, form.Weekday, form.Retention)
 	if err != nil {
 		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.FormatError(err))
 	} else {
-		LogAction(session.UserId, ExtractIP(r.RemoteAddr), "Resize", fmt.Sprintf("VM ID: %d; New Plan: %s", vm.Id, form.PlanId))
-		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.Success("vm_resized"))
+		LogAction(session.UserId, ExtractIP(r.RemoteAddr), "Add backup schedule", fmt.Sprintf("VM ID: %d; Frequency: %s; Hour: %d; Weekday: %d; Retention: %d", vm.Id, form.Frequency, form.Hour, form.Weekday, form.Retention))
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.Success("backup_schedule_added"))
 	}
 }
 
-func panelVMRename(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+func panelVMBackupRemove(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
 	vm, err := panelVMProcess(r, session)
 	if err != nil {
 		RedirectMessage(w, r, "/panel/vms", L.FormatError(err))
+		return
 	}
-	err = vm.Rename(r.PostFormValue("name"))
+	scheduleId, _ := strconv.Atoi(mux.Vars(r)["schedule"])
+
+	err = vm.RemoveBackupSchedule(scheduleId)
 	if err != nil {
 		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.FormatError(err))
 	} else {
-		LogAction(session.UserId, ExtractIP(r.RemoteAddr), "Rename VM", fmt.Sprintf("VM ID: %d; Name: %d", vm.Id, r.PostFormValue("name")))
-		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.Success("vm_renamed"))
+		LogAction(session.UserId, ExtractIP(r.RemoteAddr), "Remove backup schedule", fmt.Sprintf("VM ID: %d; Schedule ID: %d", vm.Id, scheduleId))
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.Success("backup_schedule_removed"))
 	}
 }
 
-type PanelBillingParams struct {
-	Frame          FrameParams
-	CreditSummary  *CreditSummary
-	PaymentMethods []string
-}
-
-func panelBilling(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
-	params := PanelBillingParams{}
-	params.Frame = frameParams
-	params.CreditSummary = UserCreditSummary(session.UserId)
-	params.PaymentMethods = paymentMethodList()
-	RenderTemplate(w, "panel", "billing", params)
-}
-
-type PayForm struct {
-	Gateway string  `schema:"gateway"`
-	Amount  float64 `schema:"amount"`
+type VMPowerScheduleAddForm struct {
+	Action     string `schema:"action"`
+	Expression string `schema:"expression"`
+	Timezone   string `schema:"timezone"`
 }
 
-func panelPay(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
-	form := new(PayForm)
-	err := decoder.Decode(form, r.Form)
+func panelVMPowerScheduleAdd(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	vm, err := panelVMProcess(r, session)
 	if err != nil {
-		http.Redirect(w, r, "/panel/billing", 303)
+		RedirectMessage(w, r, "/panel/vms", L.FormatError(err))
 		return
 	}
 
-	user := UserDetails(session.UserId)
-	paymentHandle(form.Gateway, w, r, frameParams, session.UserId, user.Username, form.Amount)
-}
-
-type PanelChargesParams struct {
-	Frame   FrameParams
-	Year    int
-	Month   time.Month
-	Charges []*Charge
+	form := new(VMPowerScheduleAddForm)
+	err = decoder.Decode(form, r.PostForm)
+	if err != nil {
+		http.Redirect(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), 303)
+		return
+	}
 
-	Previous time.Time
-	Next     time.Time
+	_, err = vm.AddPowerSchedule(form.Action, form.Expression, form.Timezone)
+	if err != nil {
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.FormatError(err))
+	} else {
+		LogAction(session.UserId, ExtractIP(r.RemoteAddr), "Add power schedule", fmt.Sprintf("VM ID: %d; Action: %s; Expression: %s; Timezone: %s", vm.Id, form.Action, form.Expression, form.Timezone))
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.Success("power_schedule_added"))
+	}
 }
 
-func panelCharges(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
-	year, err := strconv.Atoi(mux.Vars(r)["year"])
+func panelVMPowerScheduleRemove(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	vm, err := panelVMProcess(r, session)
 	if err != nil {
-		year = time.Now().Year()
+		RedirectMessage(w, r, "/panel/vms", L.FormatError(err))
+		return
 	}
-	month, err := strconv.Atoi(mux.Vars(r)["month"])
+	scheduleId, _ := strconv.Atoi(mux.Vars(r)["schedule"])
+
+	err = vm.RemovePowerSchedule(scheduleId)
 	if err != nil {
-		month = int(time.Now().Month())
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.FormatError(err))
+	} else {
+		LogAction(session.UserId, ExtractIP(r.RemoteAddr), "Remove power schedule", fmt.Sprintf("VM ID: %d; Schedule ID: %d", vm.Id, scheduleId))
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.Success("power_schedule_removed"))
 	}
-
-	requestTime := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
-
-	params := PanelChargesParams{}
-	params.Frame = frameParams
-	params.Year = year
-	params.Month = time.Month(month)
-	params.Charges = ChargeList(session.UserId, params.Year, params.Month)
-	params.Previous = requestTime.AddDate(0, -1, 0)
-	params.Next = requestTime.AddDate(0, 1, 0)
-	RenderTemplate(w, "panel", "charges", params)
 }
 
-type PanelAccountParams struct {
-	Frame FrameParams
-	User  *User
-	Keys  []*ApiKey
-	Token string
+type VMHealthCheckAddForm struct {
+	Protocol   string `schema:"protocol"`
+	Port       int    `schema:"port"`
+	Path       string `schema:"path"`
+	Threshold  int    `schema:"threshold"`
+	AutoReboot bool   `schema:"auto_reboot"`
 }
 
-func panelAccount(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
-	params := PanelAccountParams{}
-	params.Frame = frameParams
-	params.User = UserDetails(session.UserId)
-	params.Keys = apiList(session.UserId)
-	params.Token = CSRFGenerate(session)
-	RenderTemplate(w, "panel", "account", params)
-}
+func panelVMHealthCheckAdd(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	vm, err := panelVMProcess(r, session)
+	if err != nil {
+		RedirectMessage(w, r, "/panel/vms", L.FormatError(err))
+		return
+	}
 
-type AccountPasswordForm struct {
-	OldPassword        string `schema:"old_password"`
-	NewPassword        string `schema:"new_password"`
-	NewPasswordConfirm string `schema:"new_password_confirm"`
+	form := new(VMHealthCheckAddForm)
+	err = decoder.Decode(form, r.PostForm)
+	if err != nil {
+		http.Redirect(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), 303)
+		return
+	}
+
+	_, err = vm.AddHealthCheck(form.Protocol, form.Port, form.Path, form.Threshold, form.AutoReboot)
+	if err != nil {
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.FormatError(err))
+	} else {
+		LogAction(session.UserId, ExtractIP(r.RemoteAddr), "Add health check", fmt.Sprintf("VM ID: %d; Protocol: %s; Port: %d; Path: %s; Threshold: %d; Auto reboot: %v", vm.Id, form.Protocol, form.Port, form.Path, form.Threshold, form.AutoReboot))
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.Success("health_check_added"))
+	}
 }
 
-func panelAccountPassword(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
-	form := new(AccountPasswordForm)
-	err := decoder.Decode(form, r.PostForm)
+func panelVMHealthCheckRemove(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	vm, err := panelVMProcess(r, session)
 	if err != nil {
-		http.Redirect(w, r, "/panel/account", 303)
+		RedirectMessage(w, r, "/panel/vms", L.FormatError(err))
 		return
-	} else if form.NewPassword != form.NewPasswordConfirm {
-		RedirectMessage(w, r, "/panel/account", L.FormattedError("new_password_mismatch"))
 	}
+	checkId, _ := strconv.Atoi(mux.Vars(r)["check"])
 
-	err = authChangePassword(ExtractIP(r.RemoteAddr), session.UserId, form.OldPassword, form.NewPassword)
+	err = vm.RemoveHealthCheck(checkId)
 	if err != nil {
-		RedirectMessage(w, r, "/panel/account", L.FormatError(err))
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.FormatError(err))
 	} else {
-		RedirectMessage(w, r, "/panel/account", L.Success("password_changed"))
+		LogAction(session.UserId, ExtractIP(r.RemoteAddr), "Remove health check", fmt.Sprintf("VM ID: %d; Check ID: %d", vm.Id, checkId))
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.Success("health_check_removed"))
 	}
 }
 
-type ApiAddForm struct {
-	Label          string `schema:"label"`
-	RestrictAction string `schema:"restrict_action"`
-	RestrictIp     string `schema:"restrict_ip"`
+type VMTransferForm struct {
+	Recipient string `schema:"recipient"`
 }
 
-func panelApiAdd(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
-	form := new(ApiAddForm)
-	err := decoder.Decode(form, r.PostForm)
+func panelVMTransfer(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	vm, err := panelVMProcess(r, session)
 	if err != nil {
-		http.Redirect(w, r, "/panel/account", 303)
+		RedirectMessage(w, r, "/panel/vms", L.FormatError(err))
 		return
 	}
 
-	key, err := apiCreate(session.UserId, form.Label, form.RestrictAction, form.RestrictIp)
+	form := new(VMTransferForm)
+	err = decoder.Decode(form, r.PostForm)
 	if err != nil {
-		RedirectMessage(w, r, "/panel/account", L.FormatError(err))
+		http.Redirect(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), 303)
+		return
+	}
+
+	toUser, err := vm.TransferStart(form.Recipient)
+	if err != nil {
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.FormatError(err))
 	} else {
-		RedirectMessage(w, r, "/panel/account", L.Successf("api_added", key.ApiId, key.ApiKey))
+		LogAction(session.UserId, ExtractIP(r.RemoteAddr), "Start VM transfer", fmt.Sprintf("VM ID: %d; To: %s", vm.Id, toUser.Username))
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.Success("vm_transfer_started"))
 	}
 }
 
-func panelApiRemove(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
-	id, err := strconv.Atoi(mux.Vars(r)["id"])
+func panelVMTransferCancel(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	vm, err := panelVMProcess(r, session)
 	if err != nil {
-		RedirectMessage(w, r, "/panel/account", L.FormattedError("invalid_id"))
+		RedirectMessage(w, r, "/panel/vms", L.FormatError(err))
 		return
 	}
-	apiDelete(session.UserId, id)
-	RedirectMessage(w, r, "/panel/account", L.Success("api_deleted"))
-}
 
-type PanelImagesParams struct {
-	Frame   FrameParams
-	Images  []*Image
-	Regions []string
-	Token   string
+	err = vm.TransferCancel()
+	if err != nil {
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.FormatError(err))
+	} else {
+		LogAction(session.UserId, ExtractIP(r.RemoteAddr), "Cancel VM transfer", fmt.Sprintf("VM ID: %d", vm.Id))
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.Success("vm_transfer_cancelled"))
+	}
 }
 
-func panelImages(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
-	params := PanelImagesParams{}
-	params.Frame = frameParams
-	params.Regions = regionList()
-	params.Token = CSRFGenerate(session)
+type VMProtectionForm struct {
+	Protected   bool `schema:"protected"`
+	ConfirmName bool `schema:"confirm_name"`
+}
 
-	for _, image := range imageList(session.UserId) {
-		if image.UserId == session.UserId {
-			params.Images = append(params.Images, image)
-		}
+func panelVMProtection(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	vm, err := panelVMProcess(r, session)
+	if err != nil {
+		RedirectMessage(w, r, "/panel/vms", L.FormatError(err))
+		return
 	}
 
-	RenderTemplate(w, "panel", "images", params)
-}
+	form := new(VMProtectionForm)
+	err = decoder.Decode(form, r.PostForm)
+	if err != nil {
+		http.Redirect(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), 303)
+		return
+	}
 
-type ImageAddForm struct {
-	Region   string `schema:"region"`
-	Name     string `schema:"name"`
-	Location string `schema:"location"`
-	Format   string `schema:"format"`
+	vm.SetProtected(form.Protected)
+	vm.SetConfirmName(form.ConfirmName)
+	LogAction(session.UserId, ExtractIP(r.RemoteAddr), "Set VM protection", fmt.Sprintf("VM ID: %d; Protected: %t; Confirm name: %t", vm.Id, form.Protected, form.ConfirmName))
+	RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.Success("vm_protection_updated"))
 }
 
-func panelImageAdd(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
-	form := new(ImageAddForm)
-	err := decoder.Decode(form, r.PostForm)
+func panelTransferAccept(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	transferId, _ := strconv.Atoi(mux.Vars(r)["id"])
+	vmId, err := vmTransferAccept(session.UserId, transferId, ExtractIP(r.RemoteAddr))
 	if err != nil {
-		http.Redirect(w, r, "/panel/images", 303)
-		return
+		RedirectMessage(w, r, "/panel/vms", L.FormatError(err))
+	} else {
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vmId), L.Success("vm_transfer_accepted"))
 	}
+}
 
-	_, err = imageFetch(session.UserId, form.Region, form.Name, form.Location, form.Format)
+func panelTransferDecline(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	transferId, _ := strconv.Atoi(mux.Vars(r)["id"])
+	err := vmTransferDecline(session.UserId, transferId)
 	if err != nil {
-		RedirectMessage(w, r, "/panel/images", L.FormatError(err))
+		RedirectMessage(w, r, "/panel/vms", L.FormatError(err))
 	} else {
-		LogAction(session.UserId, ExtractIP(r.RemoteAddr), "Add image", fmt.Sprintf("Location: %s; Format: %s; Name: %s", form.Location, form.Format, form.Name))
-		RedirectMessage(w, r, "/panel/images", L.Success("image_downloading"))
+		LogAction(session.UserId, ExtractIP(r.RemoteAddr), "Decline VM transfer", fmt.Sprintf("Transfer ID: %d", transferId))
+		RedirectMessage(w, r, "/panel/vms", L.Success("vm_transfer_declined"))
 	}
 }
 
-func panelImageRemove(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
-	imageId, err := strconv.Atoi(mux.Vars(r)["id"])
+type VMVolumeAttachForm struct {
+	VolumeId int `schema:"volume_id"`
+}
+
+func panelVMVolumeAttach(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	vm, err := panelVMProcess(r, session)
 	if err != nil {
-		RedirectMessage(w, r, "/panel/images", L.FormattedError("invalid_image"))
+		RedirectMessage(w, r, "/panel/vms", L.FormatError(err))
 		return
 	}
 
-	err = imageDelete(session.UserId, imageId)
+	form := new(VMVolumeAttachForm)
+	err = decoder.Decode(form, r.PostForm)
 	if err != nil {
-		RedirectMessage(w, r, "/panel/images", L.FormatError(err))
-	} else {
-		LogAction(session.UserId, ExtractIP(r.RemoteAddr), "Remove image", fmt.Sprintf("ID: %d", imageId))
-		RedirectMessage(w, r, "/panel/images", L.Success("image_deleted"))
+		http.Redirect(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), 303)
+		return
+	}
+
+	err = vm.AttachVolume(form.VolumeId)
+	if err != nil {
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.FormatError(err))
+	} else {
+		LogAction(session.UserId, ExtractIP(r.RemoteAddr), "Attach volume", fmt.Sprintf("VM ID: %d; Volume ID: %d", vm.Id, form.VolumeId))
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.Success("volume_attached"))
+	}
+}
+
+func panelVMVolumeDetach(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	vm, err := panelVMProcess(r, session)
+	if err != nil {
+		RedirectMessage(w, r, "/panel/vms", L.FormatError(err))
+		return
+	}
+	volumeId, _ := strconv.Atoi(mux.Vars(r)["volume"])
+
+	err = vm.DetachVolume(volumeId)
+	if err != nil {
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.FormatError(err))
+	} else {
+		LogAction(session.UserId, ExtractIP(r.RemoteAddr), "Detach volume", fmt.Sprintf("VM ID: %d; Volume ID: %d", vm.Id, volumeId))
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.Success("volume_detached"))
+	}
+}
+
+type VMReservedIpAttachForm struct {
+	ReservedIpId int `schema:"reserved_ip_id"`
+}
+
+func panelVMReservedIpAttach(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	vm, err := panelVMProcess(r, session)
+	if err != nil {
+		RedirectMessage(w, r, "/panel/vms", L.FormatError(err))
+		return
+	}
+
+	form := new(VMReservedIpAttachForm)
+	err = decoder.Decode(form, r.PostForm)
+	if err != nil {
+		http.Redirect(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), 303)
+		return
+	}
+
+	err = vm.AttachReservedIp(form.ReservedIpId)
+	if err != nil {
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.FormatError(err))
+	} else {
+		LogAction(session.UserId, ExtractIP(r.RemoteAddr), "Attach reserved IP", fmt.Sprintf("VM ID: %d; Reserved IP ID: %d", vm.Id, form.ReservedIpId))
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.Success("reserved_ip_attached"))
+	}
+}
+
+func panelVMReservedIpDetach(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	vm, err := panelVMProcess(r, session)
+	if err != nil {
+		RedirectMessage(w, r, "/panel/vms", L.FormatError(err))
+		return
+	}
+	reservedIpId, _ := strconv.Atoi(mux.Vars(r)["reserved_ip"])
+
+	err = vm.DetachReservedIp(reservedIpId)
+	if err != nil {
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.FormatError(err))
+	} else {
+		LogAction(session.UserId, ExtractIP(r.RemoteAddr), "Detach reserved IP", fmt.Sprintf("VM ID: %d; Reserved IP ID: %d", vm.Id, reservedIpId))
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.Success("reserved_ip_detached"))
+	}
+}
+
+type VMNetworkAttachForm struct {
+	NetworkId int `schema:"network_id"`
+}
+
+func panelVMNetworkAttach(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	vm, err := panelVMProcess(r, session)
+	if err != nil {
+		RedirectMessage(w, r, "/panel/vms", L.FormatError(err))
+		return
+	}
+
+	form := new(VMNetworkAttachForm)
+	err = decoder.Decode(form, r.PostForm)
+	if err != nil {
+		http.Redirect(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), 303)
+		return
+	}
+
+	err = vm.AttachNetwork(form.NetworkId)
+	if err != nil {
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.FormatError(err))
+	} else {
+		LogAction(session.UserId, ExtractIP(r.RemoteAddr), "Attach network", fmt.Sprintf("VM ID: %d; Network ID: %d", vm.Id, form.NetworkId))
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.Success("network_attached"))
+	}
+}
+
+func panelVMNetworkDetach(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	vm, err := panelVMProcess(r, session)
+	if err != nil {
+		RedirectMessage(w, r, "/panel/vms", L.FormatError(err))
+		return
+	}
+	networkId, _ := strconv.Atoi(mux.Vars(r)["network"])
+
+	err = vm.DetachNetwork(networkId)
+	if err != nil {
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.FormatError(err))
+	} else {
+		LogAction(session.UserId, ExtractIP(r.RemoteAddr), "Detach network", fmt.Sprintf("VM ID: %d; Network ID: %d", vm.Id, networkId))
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.Success("network_detached"))
+	}
+}
+
+type VMFirewallAddForm struct {
+	Protocol string `schema:"protocol"`
+	PortMin  int    `schema:"port_min"`
+	PortMax  int    `schema:"port_max"`
+	Cidr     string `schema:"cidr"`
+}
+
+func panelVMFirewallAdd(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	vm, err := panelVMProcess(r, session)
+	if err != nil {
+		RedirectMessage(w, r, "/panel/vms", L.FormatError(err))
+		return
+	}
+
+	form := new(VMFirewallAddForm)
+	err = decoder.Decode(form, r.PostForm)
+	if err != nil {
+		http.Redirect(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), 303)
+		return
+	}
+
+	err = vm.AddFirewallRule(form.Protocol, form.PortMin, form.PortMax, form.Cidr)
+	if err != nil {
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.FormatError(err))
+	} else {
+		LogAction(session.UserId, ExtractIP(r.RemoteAddr), "Add firewall rule", fmt.Sprintf("VM ID: %d; Protocol: %s; Ports: %d-%d; CIDR: %s", vm.Id, form.Protocol, form.PortMin, form.PortMax, form.Cidr))
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.Success("firewall_rule_added"))
+	}
+}
+
+func panelVMFirewallRemove(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	vm, err := panelVMProcess(r, session)
+	if err != nil {
+		RedirectMessage(w, r, "/panel/vms", L.FormatError(err))
+		return
+	}
+	ruleId := mux.Vars(r)["rule"]
+
+	err = vm.RemoveFirewallRule(ruleId)
+	if err != nil {
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.FormatError(err))
+	} else {
+		LogAction(session.UserId, ExtractIP(r.RemoteAddr), "Remove firewall rule", fmt.Sprintf("VM ID: %d; Rule ID: %s", vm.Id, ruleId))
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.Success("firewall_rule_removed"))
+	}
+}
+
+type VMResizeForm struct {
+	PlanId      int    `schema:"plan_id"`
+	ConfirmName string `schema:"confirm_name"`
+}
+
+func panelVMResize(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	vm, err := panelVMProcess(r, session)
+	if err != nil {
+		RedirectMessage(w, r, "/panel/vms", L.FormatError(err))
+	}
+
+	form := new(VMResizeForm)
+	err = decoder.Decode(form, r.PostForm)
+	if err != nil {
+		http.Redirect(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), 303)
+		return
+	}
+
+	err = vm.checkConfirmName(form.ConfirmName)
+	if err == nil {
+		err = vm.Resize(form.PlanId)
+	}
+	if err != nil {
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.FormatError(err))
+	} else {
+		LogAction(session.UserId, ExtractIP(r.RemoteAddr), "Resize", fmt.Sprintf("VM ID: %d; New Plan: %s", vm.Id, form.PlanId))
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.Success("vm_resized"))
+	}
+}
+
+func panelVMRename(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	vm, err := panelVMProcess(r, session)
+	if err != nil {
+		RedirectMessage(w, r, "/panel/vms", L.FormatError(err))
+	}
+	err = vm.Rename(r.PostFormValue("name"))
+	if err != nil {
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.FormatError(err))
+	} else {
+		LogAction(session.UserId, ExtractIP(r.RemoteAddr), "Rename VM", fmt.Sprintf("VM ID: %d; Name: %d", vm.Id, r.PostFormValue("name")))
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.Success("vm_renamed"))
+	}
+}
+
+type VMTagsForm struct {
+	Tags  string `schema:"tags"`
+	Notes string `schema:"notes"`
+}
+
+func panelVMTags(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	vm, err := panelVMProcess(r, session)
+	if err != nil {
+		RedirectMessage(w, r, "/panel/vms", L.FormatError(err))
+		return
+	}
+	form := new(VMTagsForm)
+	err = decoder.Decode(form, r.PostForm)
+	if err != nil {
+		http.Redirect(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), 303)
+		return
+	}
+	err = vm.SetNotes(form.Notes)
+	if err == nil {
+		err = vm.SetTags(tagsParse(form.Tags))
+	}
+	if err != nil {
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.FormatError(err))
+	} else {
+		LogAction(session.UserId, ExtractIP(r.RemoteAddr), "Update VM tags", fmt.Sprintf("VM ID: %d; Tags: %s", vm.Id, form.Tags))
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.Success("vm_tags_updated"))
+	}
+}
+
+type VMCloneForm struct {
+	Name        string `schema:"name"`
+	PlanId      int    `schema:"plan_id"`
+	DeleteImage bool   `schema:"delete_image"`
+}
+
+func panelVMClone(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	vm, err := panelVMProcess(r, session)
+	if err != nil {
+		RedirectMessage(w, r, "/panel/vms", L.FormatError(err))
+		return
+	}
+	form := new(VMCloneForm)
+	err = decoder.Decode(form, r.PostForm)
+	if err != nil {
+		http.Redirect(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), 303)
+		return
+	}
+	_, err = vm.Clone(form.Name, form.PlanId, form.DeleteImage)
+	if err != nil {
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.FormatError(err))
+	} else {
+		LogAction(session.UserId, ExtractIP(r.RemoteAddr), "Clone VM", fmt.Sprintf("VM ID: %d; Name: %s; Plan ID: %d", vm.Id, form.Name, form.PlanId))
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.Success("vm_clone_started"))
+	}
+}
+
+func panelVMUnrescue(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	vm, err := panelVMProcess(r, session)
+	if err != nil {
+		RedirectMessage(w, r, "/panel/vms", L.FormatError(err))
+		return
+	}
+	err = vm.Unrescue()
+	if err != nil {
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.FormatError(err))
+	} else {
+		LogAction(session.UserId, ExtractIP(r.RemoteAddr), "Exit VM rescue", fmt.Sprintf("VM ID: %d", vm.Id))
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.Success("vm_unrescued"))
+	}
+}
+
+type VMMountISOForm struct {
+	Image int `schema:"image"`
+}
+
+func panelVMMountISO(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	vm, err := panelVMProcess(r, session)
+	if err != nil {
+		RedirectMessage(w, r, "/panel/vms", L.FormatError(err))
+		return
+	}
+	form := new(VMMountISOForm)
+	err = decoder.Decode(form, r.PostForm)
+	if err != nil {
+		http.Redirect(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), 303)
+		return
+	}
+	err = vm.MountISO(form.Image)
+	if err != nil {
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.FormatError(err))
+	} else {
+		LogAction(session.UserId, ExtractIP(r.RemoteAddr), "Mount VM ISO", fmt.Sprintf("VM ID: %d; Image: %d", vm.Id, form.Image))
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.Success("vm_iso_mounted"))
+	}
+}
+
+func panelVMUnmountISO(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	vm, err := panelVMProcess(r, session)
+	if err != nil {
+		RedirectMessage(w, r, "/panel/vms", L.FormatError(err))
+		return
+	}
+	err = vm.UnmountISO()
+	if err != nil {
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.FormatError(err))
+	} else {
+		LogAction(session.UserId, ExtractIP(r.RemoteAddr), "Unmount VM ISO", fmt.Sprintf("VM ID: %d", vm.Id))
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.Success("vm_iso_unmounted"))
+	}
+}
+
+type VMMigrateForm struct {
+	Region       string `schema:"region"`
+	PlanId       int    `schema:"plan_id"`
+	DeleteSource bool   `schema:"delete_source"`
+}
+
+func panelVMMigrate(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	vm, err := panelVMProcess(r, session)
+	if err != nil {
+		RedirectMessage(w, r, "/panel/vms", L.FormatError(err))
+		return
+	}
+	form := new(VMMigrateForm)
+	err = decoder.Decode(form, r.PostForm)
+	if err != nil {
+		http.Redirect(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), 303)
+		return
+	}
+	_, err = vm.Migrate(form.Region, form.PlanId, form.DeleteSource)
+	if err != nil {
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.FormatError(err))
+	} else {
+		LogAction(session.UserId, ExtractIP(r.RemoteAddr), "Migrate VM", fmt.Sprintf("VM ID: %d; Region: %s; Plan ID: %d; Delete source: %t", vm.Id, form.Region, form.PlanId, form.DeleteSource))
+		RedirectMessage(w, r, fmt.Sprintf("/panel/vm/%d", vm.Id), L.Success("vm_migrate_started"))
+	}
+}
+
+type PanelBillingParams struct {
+	Frame          FrameParams
+	CreditSummary  *CreditSummary
+	PaymentMethods []string
+	TagCosts       []*TagCost // for the current month
+}
+
+func panelBilling(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	params := PanelBillingParams{}
+	params.Frame = frameParams
+	params.CreditSummary = UserCreditSummary(session.UserId)
+	params.PaymentMethods = paymentMethodList()
+	now := time.Now().UTC()
+	params.TagCosts = tagCostBreakdown(session.UserId, now.Year(), now.Month())
+	RenderTemplate(w, "panel", "billing", params)
+}
+
+type PayForm struct {
+	Gateway string  `schema:"gateway"`
+	Amount  float64 `schema:"amount"`
+}
+
+func panelPay(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	form := new(PayForm)
+	err := decoder.Decode(form, r.Form)
+	if err != nil {
+		http.Redirect(w, r, "/panel/billing", 303)
+		return
+	}
+
+	user := UserDetails(session.UserId)
+	paymentHandle(form.Gateway, w, r, frameParams, session.UserId, user.Username, form.Amount)
+}
+
+type PanelChargesParams struct {
+	Frame   FrameParams
+	Year    int
+	Month   time.Month
+	Charges []*Charge
+
+	Previous time.Time
+	Next     time.Time
+}
+
+func panelCharges(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	year, err := strconv.Atoi(mux.Vars(r)["year"])
+	if err != nil {
+		year = time.Now().Year()
+	}
+	month, err := strconv.Atoi(mux.Vars(r)["month"])
+	if err != nil {
+		month = int(time.Now().Month())
+	}
+
+	requestTime := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+
+	params := PanelChargesParams{}
+	params.Frame = frameParams
+	params.Year = year
+	params.Month = time.Month(month)
+	params.Charges = ChargeList(session.UserId, params.Year, params.Month)
+	params.Previous = requestTime.AddDate(0, -1, 0)
+	params.Next = requestTime.AddDate(0, 1, 0)
+	RenderTemplate(w, "panel", "charges", params)
+}
+
+type PanelAccountParams struct {
+	Frame FrameParams
+	User  *User
+	Keys  []*ApiKey
+	Token string
+}
+
+func panelAccount(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	params := PanelAccountParams{}
+	params.Frame = frameParams
+	params.User = UserDetails(session.UserId)
+	params.Keys = apiList(session.UserId)
+	params.Token = CSRFGenerate(session)
+	RenderTemplate(w, "panel", "account", params)
+}
+
+type AccountPasswordForm struct {
+	OldPassword        string `schema:"old_password"`
+	NewPassword        string `schema:"new_password"`
+	NewPasswordConfirm string `schema:"new_password_confirm"`
+}
+
+func panelAccountPassword(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	form := new(AccountPasswordForm)
+	err := decoder.Decode(form, r.PostForm)
+	if err != nil {
+		http.Redirect(w, r, "/panel/account", 303)
+		return
+	} else if form.NewPassword != form.NewPasswordConfirm {
+		RedirectMessage(w, r, "/panel/account", L.FormattedError("new_password_mismatch"))
+	}
+
+	err = authChangePassword(ExtractIP(r.RemoteAddr), session.UserId, form.OldPassword, form.NewPassword)
+	if err != nil {
+		RedirectMessage(w, r, "/panel/account", L.FormatError(err))
+	} else {
+		RedirectMessage(w, r, "/panel/account", L.Success("password_changed"))
+	}
+}
+
+type ApiAddForm struct {
+	Label          string `schema:"label"`
+	RestrictAction string `schema:"restrict_action"`
+	RestrictIp     string `schema:"restrict_ip"`
+}
+
+func panelApiAdd(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	form := new(ApiAddForm)
+	err := decoder.Decode(form, r.PostForm)
+	if err != nil {
+		http.Redirect(w, r, "/panel/account", 303)
+		return
+	}
+
+	key, err := apiCreate(session.UserId, form.Label, form.RestrictAction, form.RestrictIp)
+	if err != nil {
+		RedirectMessage(w, r, "/panel/account", L.FormatError(err))
+	} else {
+		RedirectMessage(w, r, "/panel/account", L.Successf("api_added", key.ApiId, key.ApiKey))
+	}
+}
+
+func panelApiRemove(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		RedirectMessage(w, r, "/panel/account", L.FormattedError("invalid_id"))
+		return
+	}
+	apiDelete(session.UserId, id)
+	RedirectMessage(w, r, "/panel/account", L.Success("api_deleted"))
+}
+
+type PanelImagesParams struct {
+	Frame   FrameParams
+	Images  []*Image
+	Regions []string
+	Token   string
+}
+
+func panelImages(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	params := PanelImagesParams{}
+	params.Frame = frameParams
+	params.Regions = regionList()
+	params.Token = CSRFGenerate(session)
+
+	for _, image := range imageList(session.UserId) {
+		if image.UserId == session.UserId {
+			params.Images = append(params.Images, image)
+		}
+	}
+
+	RenderTemplate(w, "panel", "images", params)
+}
+
+type ImageAddForm struct {
+	Region   string `schema:"region"`
+	Name     string `schema:"name"`
+	Location string `schema:"location"`
+	Format   string `schema:"format"`
+}
+
+func panelImageAdd(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	form := new(ImageAddForm)
+	err := decoder.Decode(form, r.PostForm)
+	if err != nil {
+		http.Redirect(w, r, "/panel/images", 303)
+		return
+	}
+
+	_, err = imageFetch(session.UserId, form.Region, form.Name, form.Location, form.Format)
+	if err != nil {
+		RedirectMessage(w, r, "/panel/images", L.FormatError(err))
+	} else {
+		LogAction(session.UserId, ExtractIP(r.RemoteAddr), "Add image", fmt.Sprintf("Location: %s; Format: %s; Name: %s", form.Location, form.Format, form.Name))
+		RedirectMessage(w, r, "/panel/images", L.Success("image_downloading"))
+	}
+}
+
+func panelImageRemove(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	imageId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		RedirectMessage(w, r, "/panel/images", L.FormattedError("invalid_image"))
+		return
+	}
+
+	err = imageDelete(session.UserId, imageId)
+	if err != nil {
+		RedirectMessage(w, r, "/panel/images", L.FormatError(err))
+	} else {
+		LogAction(session.UserId, ExtractIP(r.RemoteAddr), "Remove image", fmt.Sprintf("ID: %d", imageId))
+		RedirectMessage(w, r, "/panel/images", L.Success("image_deleted"))
+	}
+}
+
+type PanelVolumesParams struct {
+	Frame   FrameParams
+	Volumes []*Volume
+	Regions []string
+	Token   string
+}
+
+func panelVolumes(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	params := PanelVolumesParams{}
+	params.Frame = frameParams
+	params.Volumes = volumeList(session.UserId)
+	params.Token = CSRFGenerate(session)
+
+	for _, region := range regionList() {
+		if regionCanVolumes(region) {
+			params.Regions = append(params.Regions, region)
+		}
+	}
+
+	RenderTemplate(w, "panel", "volumes", params)
+}
+
+type VolumeAddForm struct {
+	Region string `schema:"region"`
+	Name   string `schema:"name"`
+	Size   int    `schema:"size"`
+}
+
+func panelVolumeAdd(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	form := new(VolumeAddForm)
+	err := decoder.Decode(form, r.PostForm)
+	if err != nil {
+		http.Redirect(w, r, "/panel/volumes", 303)
+		return
+	}
+
+	volumeId, err := volumeCreate(session.UserId, form.Region, form.Name, form.Size)
+	if err != nil {
+		RedirectMessage(w, r, "/panel/volumes", L.FormatError(err))
+	} else {
+		LogAction(session.UserId, ExtractIP(r.RemoteAddr), "Create volume", fmt.Sprintf("ID: %d; Region: %s; Name: %s; Size: %d GB", volumeId, form.Region, form.Name, form.Size))
+		RedirectMessage(w, r, "/panel/volumes", L.Success("volume_creating"))
+	}
+}
+
+type VolumeResizeForm struct {
+	Size int `schema:"size"`
+}
+
+func panelVolumeResize(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	volumeId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		RedirectMessage(w, r, "/panel/volumes", L.FormattedError("invalid_volume"))
+		return
+	}
+
+	form := new(VolumeResizeForm)
+	err = decoder.Decode(form, r.PostForm)
+	if err != nil {
+		http.Redirect(w, r, "/panel/volumes", 303)
+		return
+	}
+
+	err = volumeResize(session.UserId, volumeId, form.Size)
+	if err != nil {
+		RedirectMessage(w, r, "/panel/volumes", L.FormatError(err))
+	} else {
+		LogAction(session.UserId, ExtractIP(r.RemoteAddr), "Resize volume", fmt.Sprintf("ID: %d; Size: %d GB", volumeId, form.Size))
+		RedirectMessage(w, r, "/panel/volumes", L.Success("volume_resizing"))
+	}
+}
+
+func panelVolumeRemove(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	volumeId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		RedirectMessage(w, r, "/panel/volumes", L.FormattedError("invalid_volume"))
+		return
+	}
+
+	err = volumeDelete(session.UserId, volumeId)
+	if err != nil {
+		RedirectMessage(w, r, "/panel/volumes", L.FormatError(err))
+	} else {
+		LogAction(session.UserId, ExtractIP(r.RemoteAddr), "Delete volume", fmt.Sprintf("ID: %d", volumeId))
+		RedirectMessage(w, r, "/panel/volumes", L.Success("volume_deleted"))
+	}
+}
+
+type PanelReservedIpsParams struct {
+	Frame       FrameParams
+	ReservedIps []*ReservedIp
+	Regions     []string
+	Token       string
+}
+
+func panelReservedIps(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	params := PanelReservedIpsParams{}
+	params.Frame = frameParams
+	params.ReservedIps = reservedIpList(session.UserId)
+	params.Token = CSRFGenerate(session)
+
+	for _, region := range regionList() {
+		if regionCanReservedIps(region) {
+			params.Regions = append(params.Regions, region)
+		}
+	}
+
+	RenderTemplate(w, "panel", "reserved_ips", params)
+}
+
+type ReservedIpAddForm struct {
+	Region string `schema:"region"`
+}
+
+func panelReservedIpAdd(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	form := new(ReservedIpAddForm)
+	err := decoder.Decode(form, r.PostForm)
+	if err != nil {
+		http.Redirect(w, r, "/panel/reserved_ips", 303)
+		return
+	}
+
+	reservedIpId, err := reservedIpCreate(session.UserId, form.Region)
+	if err != nil {
+		RedirectMessage(w, r, "/panel/reserved_ips", L.FormatError(err))
+	} else {
+		LogAction(session.UserId, ExtractIP(r.RemoteAddr), "Allocate reserved IP", fmt.Sprintf("ID: %d; Region: %s", reservedIpId, form.Region))
+		RedirectMessage(w, r, "/panel/reserved_ips", L.Success("reserved_ip_allocated"))
+	}
+}
+
+func panelReservedIpRemove(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	reservedIpId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		RedirectMessage(w, r, "/panel/reserved_ips", L.FormattedError("invalid_reserved_ip"))
+		return
+	}
+
+	err = reservedIpDelete(session.UserId, reservedIpId)
+	if err != nil {
+		RedirectMessage(w, r, "/panel/reserved_ips", L.FormatError(err))
+	} else {
+		LogAction(session.UserId, ExtractIP(r.RemoteAddr), "Release reserved IP", fmt.Sprintf("ID: %d", reservedIpId))
+		RedirectMessage(w, r, "/panel/reserved_ips", L.Success("reserved_ip_released"))
+	}
+}
+
+type PanelNetworksParams struct {
+	Frame    FrameParams
+	Networks []*Network
+	Regions  []string
+	Token    string
+}
+
+func panelNetworks(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	params := PanelNetworksParams{}
+	params.Frame = frameParams
+	params.Networks = networkList(session.UserId)
+	params.Token = CSRFGenerate(session)
+
+	for _, region := range regionList() {
+		if regionCanNetworks(region) {
+			params.Regions = append(params.Regions, region)
+		}
+	}
+
+	RenderTemplate(w, "panel", "networks", params)
+}
+
+type NetworkAddForm struct {
+	Region string `schema:"region"`
+	Name   string `schema:"name"`
+	Cidr   string `schema:"cidr"`
+}
+
+func panelNetworkAdd(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	form := new(NetworkAddForm)
+	err := decoder.Decode(form, r.PostForm)
+	if err != nil {
+		http.Redirect(w, r, "/panel/networks", 303)
+		return
+	}
+
+	networkId, err := networkCreate(session.UserId, form.Region, form.Name, form.Cidr)
+	if err != nil {
+		RedirectMessage(w, r, "/panel/networks", L.FormatError(err))
+	} else {
+		LogAction(session.UserId, ExtractIP(r.RemoteAddr), "Create network", fmt.Sprintf("ID: %d; Region: %s; Name: %s; CIDR: %s", networkId, form.Region, form.Name, form.Cidr))
+		RedirectMessage(w, r, "/panel/networks", L.Success("network_created"))
+	}
+}
+
+func panelNetworkRemove(w http.ResponseWriter, r *http.Request, session *Session, frameParams FrameParams) {
+	networkId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		RedirectMessage(w, r, "/panel/networks", L.FormattedError("invalid_network"))
+		return
+	}
+
+	err = networkDelete(session.UserId, networkId)
+	if err != nil {
+		RedirectMessage(w, r, "/panel/networks", L.FormatError(err))
+	} else {
+		LogAction(session.UserId, ExtractIP(r.RemoteAddr), "Delete network", fmt.Sprintf("ID: %d", networkId))
+		RedirectMessage(w, r, "/panel/networks", L.Success("network_deleted"))
 	}
 }
 
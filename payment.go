@@ -9,6 +9,8 @@ type PaymentInterface interface {
 var paymentInterfaces map[string]PaymentInterface = make(map[string]PaymentInterface)
 
 func paymentMethodList() []string {
+	interfacesMutex.RLock()
+	defer interfacesMutex.RUnlock()
 	var methods []string
 	for method := range paymentInterfaces {
 		methods = append(methods, method)
@@ -22,8 +24,8 @@ func paymentHandle(method string, w http.ResponseWriter, r *http.Request, frameP
 		return
 	}
 
-	payInterface, ok := paymentInterfaces[method]
-	if ok {
+	payInterface := paymentInterface(method)
+	if payInterface != nil {
 		payInterface.Payment(w, r, frameParams, userId, username, amount)
 	} else {
 		RedirectMessage(w, r, "/panel/billing", L.FormattedError("invalid_payment_method"))
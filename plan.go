@@ -146,7 +146,7 @@ func planDisable(planId int) {
 }
 
 func planAssociateRegion(planId int, region string, identification string) error {
-	if _, ok := regionInterfaces[region]; !ok {
+	if regionInterface(region) == nil {
 		return fmt.Errorf("specified region %s does not exist", region)
 	}
 
@@ -166,10 +166,10 @@ func planDeassociateRegion(planId int, region string) {
 }
 
 func planAutopopulate(region string) error {
-	if _, ok := regionInterfaces[region]; !ok {
+	if regionInterface(region) == nil {
 		return fmt.Errorf("specified region %s does not exist", region)
 	}
-	vmi, ok := regionInterfaces[region].(VMIPlans)
+	vmi, ok := regionInterface(region).(VMIPlans)
 	if !ok {
 		return L.Error("region_plans_unsupported")
 	}
@@ -0,0 +1,159 @@
+package lobster
+
+import "log"
+
+// Resource quotas limit the total resources of a user's virtual machines, volumes and images.
+// Defaults come from the quota section of the configuration, and can be overridden per user.
+// A quota of zero means that the resource is unlimited.
+
+// Resources with quotas, in the order that they are displayed.
+var QUOTA_RESOURCES = []string{"cpu", "ram", "storage", "ips", "snapshots", "images"}
+
+// Usage of one resource against the user's quota, for display.
+type QuotaResource struct {
+	Resource string
+	Used     int
+	Quota    int  // zero if unlimited
+	Default  int  // configured default quota
+	Override bool // whether Quota is a per-user override rather than the default
+}
+
+func quotaResourceOk(resource string) bool {
+	for _, r := range QUOTA_RESOURCES {
+		if r == resource {
+			return true
+		}
+	}
+	return false
+}
+
+func quotaDefaults() map[string]int {
+	return map[string]int{
+		"cpu":       cfg.Quota.Cpu,
+		"ram":       cfg.Quota.Ram,
+		"storage":   cfg.Quota.Storage,
+		"ips":       cfg.Quota.Ips,
+		"snapshots": cfg.Quota.Snapshots,
+		"images":    cfg.Quota.Images,
+	}
+}
+
+// Returns the per-user quota overrides.
+func userQuotaOverrides(userId int) map[string]int {
+	overrides := make(map[string]int)
+	rows := db.Query("SELECT resource, quota FROM user_quotas WHERE user_id = ?", userId)
+	defer rows.Close()
+	for rows.Next() {
+		var resource string
+		var quota int
+		rows.Scan(&resource, &quota)
+		overrides[resource] = quota
+	}
+	return overrides
+}
+
+// Returns the quotas that apply to the user, taking overrides over the defaults.
+func userQuotas(userId int) map[string]int {
+	quotas := quotaDefaults()
+	for resource, quota := range userQuotaOverrides(userId) {
+		quotas[resource] = quota
+	}
+	return quotas
+}
+
+// Returns the user's current usage of each resource.
+// Addresses are counted from the last time that each virtual machine's addresses were loaded,
+// since they are only known by the back-end; virtual machines are assumed to have one until then.
+func userQuotaUsage(userId int) map[string]int {
+	usage := make(map[string]int)
+	var cpu, ram, storage, volumeStorage, vmIps, reservedIps, snapshots, images int
+	db.QueryRow(
+		"SELECT IFNULL(SUM(plans.cpu), 0), IFNULL(SUM(plans.ram), 0), IFNULL(SUM(plans.storage), 0) "+
+			"FROM vms JOIN plans ON plans.id = vms.plan_id WHERE vms.user_id = ?",
+		userId,
+	).Scan(&cpu, &ram, &storage)
+
+	// volumes count regardless of status, since pending volumes are already allocated by the back-end
+	db.QueryRow("SELECT IFNULL(SUM(size), 0) FROM volumes WHERE user_id = ?", userId).Scan(&volumeStorage)
+	db.QueryRow(
+		"SELECT IFNULL(SUM(IFNULL(vm_metadata.v, 1)), 0) FROM vms "+
+			"LEFT JOIN vm_metadata ON vm_metadata.vm_id = vms.id AND vm_metadata.k = 'address_count' "+
+			"WHERE vms.user_id = ?",
+		userId,
+	).Scan(&vmIps)
+	db.QueryRow("SELECT COUNT(*) FROM reserved_ips WHERE user_id = ? AND vm_id = 0", userId).Scan(&reservedIps)
+	db.QueryRow("SELECT COUNT(*) FROM images WHERE user_id = ? AND source_vm != -1 AND backup_schedule = 0", userId).Scan(&snapshots)
+	db.QueryRow("SELECT COUNT(*) FROM images WHERE user_id = ? AND source_vm = -1", userId).Scan(&images)
+	usage["cpu"] = cpu
+	usage["ram"] = ram
+	usage["storage"] = storage + volumeStorage
+	usage["ips"] = vmIps + reservedIps
+	usage["snapshots"] = snapshots
+	usage["images"] = images
+	return usage
+}
+
+// Returns usage against quota for each resource.
+func userQuotaSummary(userId int) []*QuotaResource {
+	defaults := quotaDefaults()
+	overrides := userQuotaOverrides(userId)
+	usage := userQuotaUsage(userId)
+	summary := make([]*QuotaResource, 0, len(QUOTA_RESOURCES))
+	for _, resource := range QUOTA_RESOURCES {
+		item := &QuotaResource{
+			Resource: resource,
+			Used:     usage[resource],
+			Quota:    defaults[resource],
+			Default:  defaults[resource],
+		}
+		if quota, ok := overrides[resource]; ok {
+			item.Quota = quota
+			item.Override = true
+		}
+		summary = append(summary, item)
+	}
+	return summary
+}
+
+// Checks that the user can allocate the requested amount of each resource without exceeding their quotas.
+// Amounts that are zero or negative, e.g. when resizing to a smaller plan, are always allowed.
+func userQuotaCheck(userId int, request map[string]int) error {
+	quotas := userQuotas(userId)
+	var usage map[string]int
+	for _, resource := range QUOTA_RESOURCES {
+		amount := request[resource]
+		if amount <= 0 || quotas[resource] <= 0 {
+			continue
+		}
+		if usage == nil {
+			usage = userQuotaUsage(userId)
+		}
+		if usage[resource]+amount > quotas[resource] {
+			return L.Errorf("exceeded_quota", L.T("quota_"+resource), quotas[resource])
+		}
+	}
+	return nil
+}
+
+// Returns the resources used by a virtual machine on the plan.
+func planQuotaRequest(plan *Plan) map[string]int {
+	return map[string]int{
+		"cpu":     plan.Cpu,
+		"ram":     plan.Ram,
+		"storage": plan.Storage,
+		"ips":     1,
+	}
+}
+
+// Sets a per-user quota override, or removes it if quota is negative so that the default applies.
+func userQuotaSet(userId int, resource string, quota int) error {
+	if !quotaResourceOk(resource) {
+		return L.Error("invalid_quota_resource")
+	}
+	log.Printf("userQuotaSet(%d, %s, %d)", userId, resource, quota)
+	db.Exec("DELETE FROM user_quotas WHERE user_id = ? AND resource = ?", userId, resource)
+	if quota >= 0 {
+		db.Exec("INSERT INTO user_quotas (user_id, resource, quota) VALUES (?, ?, ?)", userId, resource, quota)
+	}
+	return nil
+}
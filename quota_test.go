@@ -0,0 +1,50 @@
+package lobster
+
+import "testing"
+
+func TestUserQuota(t *testing.T) {
+	TestReset()
+	cfg.Quota.Cpu = 2
+	cfg.Quota.Ips = 2
+	userId := TestUser()
+	vmId := TestVm(userId)
+	db.Exec("INSERT INTO images (user_id, region, name, source_vm) VALUES (?, 'test', 'snapshot', ?)", userId, vmId)
+	db.Exec("INSERT INTO images (user_id, region, name, source_vm, backup_schedule) VALUES (?, 'test', 'backup', ?, 1)", userId, vmId)
+
+	usage := userQuotaUsage(userId)
+	if usage["cpu"] != 1 || usage["ram"] != 512 || usage["storage"] != 15 {
+		t.Fatalf("Unexpected plan usage: %v", usage)
+	} else if usage["ips"] != 1 {
+		t.Fatalf("Expected one IP for the virtual machine, got %d", usage["ips"])
+	} else if usage["snapshots"] != 1 || usage["images"] != 0 {
+		t.Fatalf("Expected one snapshot and no images, got %d and %d", usage["snapshots"], usage["images"])
+	}
+
+	if err := userQuotaCheck(userId, map[string]int{"cpu": 1}); err != nil {
+		t.Fatalf("Quota check failed within quota: %v", err)
+	} else if err := userQuotaCheck(userId, map[string]int{"cpu": 2}); err == nil {
+		t.Fatal("Quota check passed beyond quota")
+	} else if err := userQuotaCheck(userId, map[string]int{"cpu": -1, "ram": 100000}); err != nil {
+		t.Fatalf("Quota check failed for unlimited resource: %v", err)
+	}
+
+	// addresses are counted from the cached count once loaded
+	vm := vmGet(vmId)
+	vm.SetMetadata("address_count", "2")
+	if err := userQuotaCheck(userId, map[string]int{"ips": 1}); err == nil {
+		t.Fatal("Quota check passed beyond IP quota")
+	}
+
+	// overrides take precedence over the default, and zero is unlimited
+	userQuotaSet(userId, "cpu", 0)
+	if err := userQuotaCheck(userId, map[string]int{"cpu": 10}); err != nil {
+		t.Fatalf("Quota check failed with unlimited override: %v", err)
+	}
+	userQuotaSet(userId, "cpu", -1)
+	if err := userQuotaCheck(userId, map[string]int{"cpu": 10}); err == nil {
+		t.Fatal("Quota check passed after override was removed")
+	}
+	if err := userQuotaSet(userId, "bogus", 1); err == nil {
+		t.Fatal("Quota set for invalid resource")
+	}
+}
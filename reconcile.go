@@ -0,0 +1,229 @@
+package lobster
+
+import "encoding/json"
+import "errors"
+import "fmt"
+import "strings"
+import "time"
+
+// database objects
+
+// Difference between the state lobster records for a virtual machine and its state on the backend.
+type Drift struct {
+	Id             int
+	VmId           int // zero for orphans, which are backend instances that lobster doesn't know about
+	Region         string
+	Identification string
+	Kind           string // "missing", "error", "stopped", "ip_mismatch", or "orphan"
+	Details        string
+	DetectedTime   time.Time
+
+	Vm *VirtualMachine // set by driftList if VmId is non-zero and the VM exists
+}
+
+// drift kinds that are cleared automatically once the virtual machine is no longer in that state;
+// other kinds (ip_mismatch) describe an event, and are kept until dismissed by an administrator
+var driftTransientKinds []string = []string{"missing", "error", "stopped"}
+
+func driftListHelper(rows Rows) []*Drift {
+	defer rows.Close()
+	drifts := make([]*Drift, 0)
+	for rows.Next() {
+		drift := Drift{}
+		rows.Scan(&drift.Id, &drift.VmId, &drift.Region, &drift.Identification, &drift.Kind, &drift.Details, &drift.DetectedTime)
+		drifts = append(drifts, &drift)
+	}
+	return drifts
+}
+
+const DRIFT_QUERY = "SELECT id, vm_id, region, identification, kind, details, time_detected FROM vm_drift"
+
+func driftList() []*Drift {
+	drifts := driftListHelper(db.Query(DRIFT_QUERY + " ORDER BY region, vm_id, kind"))
+	for _, drift := range drifts {
+		if drift.VmId != 0 {
+			drift.Vm = vmGet(drift.VmId)
+		}
+	}
+	return drifts
+}
+
+func driftDismiss(driftId int) {
+	db.Exec("DELETE FROM vm_drift WHERE id = ?", driftId)
+}
+
+// Records the drift, and alerts administrators if it was not already recorded.
+// Stopped virtual machines are recorded but not reported, since users may shut down from inside the guest.
+func driftRecord(drift *Drift) {
+	if len(drift.Details) > 512 {
+		drift.Details = drift.Details[:512]
+	}
+	result := db.Exec(
+		"INSERT IGNORE INTO vm_drift (vm_id, region, identification, kind, details) VALUES (?, ?, ?, ?, ?)",
+		drift.VmId, drift.Region, drift.Identification, drift.Kind, drift.Details,
+	)
+	if result.RowsAffected() == 0 {
+		db.Exec(
+			"UPDATE vm_drift SET details = ? WHERE region = ? AND identification = ? AND kind = ?",
+			drift.Details, drift.Region, drift.Identification, drift.Kind,
+		)
+		return
+	}
+
+	if drift.Kind != "stopped" {
+		ReportError(
+			errors.New(drift.Details),
+			fmt.Sprintf("reconcile detected drift (%s)", drift.Kind),
+			fmt.Sprintf("vm_id=%d, region=%s, identification=%s", drift.VmId, drift.Region, drift.Identification),
+		)
+	}
+}
+
+// Returns whether unfinished jobs may be creating instances in the region, and the identifications
+// of instances that unfinished jobs are deleting from it.
+// Deleted virtual machines are already gone from the vms table while their vmDelete job runs.
+func reconcileJobInstances(region string) (bool, map[string]bool) {
+	var count int
+	db.QueryRow(
+		"SELECT COUNT(*) FROM jobs LEFT JOIN vms ON vms.id = jobs.vm_id "+
+			"WHERE jobs.status IN ('pending', 'running') AND "+
+			"((jobs.kind = 'vmCreate' AND vms.region = ?) OR jobs.kind IN ('vmClone', 'vmMigrate'))",
+		region,
+	).Scan(&count)
+
+	deleting := make(map[string]bool)
+	rows := db.Query("SELECT data FROM jobs WHERE status IN ('pending', 'running') AND kind = 'vmDelete'")
+	defer rows.Close()
+	for rows.Next() {
+		var data vmDeleteJobData
+		var dataString string
+		rows.Scan(&dataString)
+		if json.Unmarshal([]byte(dataString), &data) == nil && data.Region == region && data.Identification != "" {
+			deleting[data.Identification] = true
+		}
+	}
+	return count > 0, deleting
+}
+
+// Periodically compares virtual machines against the backend; called from its own goroutine in Run.
+func reconcile() {
+	defer errorHandler(nil, nil, true)
+
+	regionVms := make(map[string][]*VirtualMachine)
+	for _, vm := range vmListAll() {
+		regionVms[vm.Region] = append(regionVms[vm.Region], vm)
+	}
+	for region, vmi := range regionInterfaceList() {
+		reconcileRegion(region, vmi, regionVms[region])
+	}
+
+	// drift of virtual machines that have since been deleted
+	db.Exec("DELETE FROM vm_drift WHERE vm_id != 0 AND vm_id NOT IN (SELECT id FROM vms)")
+}
+
+func reconcileRegion(region string, vmi VmInterface, vms []*VirtualMachine) {
+	// listed is nil if the backend cannot enumerate its instances
+	var listed map[string]*VirtualMachine
+	vmiList, ok := vmi.(VMIList)
+	if ok {
+		instances, err := vmiList.VmList()
+		if err != nil {
+			ReportError(err, "reconcile: failed to list backend instances", "region="+region)
+		} else {
+			listed = make(map[string]*VirtualMachine)
+			for _, instance := range instances {
+				listed[instance.Identification] = instance
+			}
+		}
+	}
+
+	known := make(map[string]bool)
+	for _, vm := range vms {
+		if vm.Identification != "" {
+			known[vm.Identification] = true
+		}
+		// VMs that are provisioning or have a pending task are expected to differ from the backend
+		if vm.Identification == "" || vm.Status != "active" || vm.TaskPending {
+			continue
+		}
+
+		var drifts []*Drift
+		if listed != nil && listed[vm.Identification] == nil {
+			drifts = []*Drift{{Kind: "missing", Details: "instance not found on backend"}}
+		} else {
+			info, err := vmi.VmInfo(vm)
+			drifts = reconcileCheck(vm, info, err)
+			if err == nil && info.Ip != "" {
+				db.Exec("UPDATE vms SET external_ip = ?, private_ip = ? WHERE id = ?", info.Ip, info.PrivateIp, vm.Id)
+			}
+		}
+
+		kinds := make(map[string]bool)
+		for _, drift := range drifts {
+			drift.VmId = vm.Id
+			drift.Region = vm.Region
+			drift.Identification = vm.Identification
+			driftRecord(drift)
+			kinds[drift.Kind] = true
+		}
+		for _, kind := range driftTransientKinds {
+			if !kinds[kind] {
+				db.Exec("DELETE FROM vm_drift WHERE vm_id = ? AND kind = ?", vm.Id, kind)
+			}
+		}
+	}
+
+	if listed == nil {
+		return
+	}
+
+	// instances being created or deleted by a job are not orphans
+	creating, deleting := reconcileJobInstances(region)
+	if creating {
+		// the new instance cannot be told apart until its identification is saved, so check again later
+		return
+	}
+	for identification := range deleting {
+		known[identification] = true
+	}
+
+	orphans := make(map[string]bool)
+	for identification, instance := range listed {
+		if known[identification] {
+			continue
+		}
+		orphans[identification] = true
+		driftRecord(&Drift{
+			Region:         region,
+			Identification: identification,
+			Kind:           "orphan",
+			Details:        fmt.Sprintf("unknown backend instance %s (%s)", instance.Name, instance.ExternalIP),
+		})
+	}
+	for _, drift := range driftListHelper(db.Query(DRIFT_QUERY+" WHERE region = ? AND kind = 'orphan'", region)) {
+		if !orphans[drift.Identification] {
+			driftDismiss(drift.Id)
+		}
+	}
+}
+
+// Returns drift between the virtual machine and the result of calling VmInfo on it.
+func reconcileCheck(vm *VirtualMachine, info *VmInfo, err error) []*Drift {
+	if err != nil {
+		return []*Drift{{Kind: "error", Details: err.Error()}}
+	}
+
+	var drifts []*Drift
+	if strings.EqualFold(info.Status, "offline") {
+		drifts = append(drifts, &Drift{Kind: "stopped", Details: "backend reports status " + info.Status})
+	}
+
+	// external_ip is 'unknown' until the addresses are first loaded
+	if info.Ip != "" && vm.ExternalIP != "" && vm.ExternalIP != "unknown" && (info.Ip != vm.ExternalIP || info.PrivateIp != vm.PrivateIP) {
+		drifts = append(drifts, &Drift{
+			Kind:    "ip_mismatch",
+			Details: fmt.Sprintf("addresses changed from %s/%s to %s/%s", vm.ExternalIP, vm.PrivateIP, info.Ip, info.PrivateIp),
+		})
+	}
+	return drifts
+}
@@ -0,0 +1,74 @@
+package lobster
+
+import "errors"
+import "testing"
+
+func TestReconcileCheck(t *testing.T) {
+	vm := &VirtualMachine{ExternalIP: "192.0.2.1", PrivateIP: "10.0.0.1"}
+
+	drifts := reconcileCheck(vm, nil, errors.New("not found"))
+	if len(drifts) != 1 || drifts[0].Kind != "error" {
+		t.Fatal("Expected error drift when VmInfo fails")
+	}
+
+	drifts = reconcileCheck(vm, &VmInfo{Status: "Online", Ip: "192.0.2.1", PrivateIp: "10.0.0.1"}, nil)
+	if len(drifts) != 0 {
+		t.Fatalf("Expected no drift for matching VM, got %d", len(drifts))
+	}
+
+	drifts = reconcileCheck(vm, &VmInfo{Status: "Offline", Ip: "192.0.2.2", PrivateIp: "10.0.0.1"}, nil)
+	if len(drifts) != 2 || drifts[0].Kind != "stopped" || drifts[1].Kind != "ip_mismatch" {
+		t.Fatal("Expected stopped and ip_mismatch drift")
+	}
+
+	// addresses that were never loaded should not be reported as a mismatch
+	vm.ExternalIP = "unknown"
+	drifts = reconcileCheck(vm, &VmInfo{Status: "Online", Ip: "192.0.2.2"}, nil)
+	if len(drifts) != 0 {
+		t.Fatal("Expected no drift when addresses were unknown")
+	}
+}
+
+type testListVmi struct {
+	TestVmi
+	instances []*VirtualMachine
+}
+
+func (this *testListVmi) VmList() ([]*VirtualMachine, error) {
+	return this.instances, nil
+}
+
+func testOrphanCount(region string) int {
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM vm_drift WHERE region = ? AND kind = 'orphan'", region).Scan(&count)
+	return count
+}
+
+func TestReconcileOrphans(t *testing.T) {
+	TestReset()
+	vmi := &testListVmi{instances: []*VirtualMachine{{Identification: "deleting"}}}
+	userId := TestUser()
+
+	// the instance of a virtual machine that is being deleted is not an orphan
+	jobCreate(userId, 0, "vmDelete", vmDeleteJobData{Region: "testorphan", Identification: "deleting"})
+	reconcileRegion("testorphan", vmi, nil)
+	if testOrphanCount("testorphan") != 0 {
+		t.Fatal("Instance being deleted recorded as orphan")
+	}
+
+	// unknown instances are not checked while a virtual machine is being created in the region
+	vmi.instances = append(vmi.instances, &VirtualMachine{Identification: "new"})
+	vmId := TestVm(userId)
+	db.Exec("UPDATE vms SET region = 'testorphan', status = 'provisioning' WHERE id = ?", vmId)
+	createJobId := jobCreate(userId, vmId, "vmCreate", nil)
+	reconcileRegion("testorphan", vmi, nil)
+	if testOrphanCount("testorphan") != 0 {
+		t.Fatal("Instance being created recorded as orphan")
+	}
+
+	db.Exec("UPDATE jobs SET status = 'done' WHERE id = ?", createJobId)
+	reconcileRegion("testorphan", vmi, nil)
+	if testOrphanCount("testorphan") != 1 {
+		t.Fatal("Expected unknown instance to be recorded as orphan")
+	}
+}
@@ -0,0 +1,356 @@
+package lobster
+
+import "fmt"
+import "log"
+import "net/http"
+import "sort"
+import "strings"
+import "sync"
+
+// The VM and payment interfaces can be reloaded from configuration while lobster is running,
+// on SIGHUP or from the admin regions page. The main package builds the interfaces from its
+// configuration through an InterfaceLoader.
+//
+// New interfaces are validated before they replace the running ones, and if any fails, the
+// running interfaces are kept. Regions that are removed from the configuration while they still
+// have virtual machines or other resources are kept in a draining state: they are hidden from
+// users and new resources cannot be created in them, but existing resources can still be managed
+// and deleted. Draining regions are dropped by cron once they are empty.
+//
+// Payment interfaces register their callback routes when they are constructed. Routes registered
+// again replace the handlers of the existing routes, but only once the new interfaces pass validation.
+// Routes with new paths, e.g. a payment method that was not configured at startup, need a restart.
+
+// Builds the interfaces from configuration, keyed by region and by payment method.
+type InterfaceLoader func() (map[string]VmInterface, map[string]PaymentInterface, error)
+
+// Optionally implemented by VM interfaces to check their credentials and configuration, e.g.
+// with a read-only API call. Interfaces that do not implement it are checked with PlanList or
+// ImageList where available.
+type VMIValidate interface {
+	Validate() error
+}
+
+// Like VMIValidate, for payment interfaces.
+type PaymentValidate interface {
+	Validate() error
+}
+
+// Summary of a reload, for the admin regions page.
+type InterfaceReloadResult struct {
+	Regions        []string // regions in the new configuration
+	PaymentMethods []string
+	Removed        []string // regions that were dropped immediately
+	Draining       []string // regions that are draining
+}
+
+// guards regionInterfaces, paymentInterfaces, and drainingRegions
+var interfacesMutex sync.RWMutex
+var drainingRegions map[string]bool = make(map[string]bool)
+
+var interfaceLoader InterfaceLoader
+
+// serializes reloads, which are slow since they validate every interface
+var interfaceReloadMutex sync.Mutex
+
+// Returns the interface of the region, or nil if there is none.
+func regionInterface(region string) VmInterface {
+	interfacesMutex.RLock()
+	defer interfacesMutex.RUnlock()
+	return regionInterfaces[region]
+}
+
+// Returns a copy of the region interfaces, for iteration.
+func regionInterfaceList() map[string]VmInterface {
+	interfacesMutex.RLock()
+	defer interfacesMutex.RUnlock()
+	vmis := make(map[string]VmInterface)
+	for region, vmi := range regionInterfaces {
+		vmis[region] = vmi
+	}
+	return vmis
+}
+
+func regionDraining(region string) bool {
+	interfacesMutex.RLock()
+	defer interfacesMutex.RUnlock()
+	return drainingRegions[region]
+}
+
+func paymentInterface(method string) PaymentInterface {
+	interfacesMutex.RLock()
+	defer interfacesMutex.RUnlock()
+	return paymentInterfaces[method]
+}
+
+// Sets the function that builds the interfaces from configuration, and registers the interfaces that it returns.
+// Interfaces are not validated here, so that lobster can start while a backend is unavailable.
+func SetInterfaceLoader(loader InterfaceLoader) error {
+	vmis, payments, err := loader()
+	if err != nil {
+		return err
+	}
+	interfaceLoader = loader
+	for region, vmi := range vmis {
+		RegisterVmInterface(region, vmi)
+	}
+	for method, payInterface := range payments {
+		RegisterPaymentInterface(method, payInterface)
+	}
+	return nil
+}
+
+func vmInterfaceValidate(vmi VmInterface) error {
+	if validator, ok := vmi.(VMIValidate); ok {
+		return validator.Validate()
+	} else if vmiPlans, ok := vmi.(VMIPlans); ok {
+		_, err := vmiPlans.PlanList()
+		return err
+	} else if vmiImages, ok := vmi.(VMIImages); ok {
+		_, err := vmiImages.ImageList()
+		return err
+	}
+	return nil
+}
+
+// Returns whether the region still has resources that need its interface.
+// Unfinished jobs are matched by virtual machine, or by the region in their data, since jobs
+// such as vmDelete outlive the virtual machine row.
+func regionInUse(region string) bool {
+	var count int
+	for _, table := range []string{"vms", "volumes", "reserved_ips", "networks"} {
+		db.QueryRow("SELECT COUNT(*) FROM "+table+" WHERE region = ?", region).Scan(&count)
+		if count > 0 {
+			return true
+		}
+	}
+	db.QueryRow("SELECT COUNT(*) FROM images WHERE region = ? AND user_id != -1", region).Scan(&count)
+	if count > 0 {
+		return true
+	}
+	db.QueryRow(
+		"SELECT COUNT(*) FROM jobs LEFT JOIN vms ON vms.id = jobs.vm_id "+
+			"WHERE jobs.status IN ('pending', 'running') AND (vms.region = ? OR jobs.data LIKE ?)",
+		region, fmt.Sprintf("%%\"Region\":%q%%", region),
+	).Scan(&count)
+	return count > 0
+}
+
+// Runs the loader, treating a panic in an interface constructor as a validation failure.
+func runInterfaceLoader() (vmis map[string]VmInterface, payments map[string]PaymentInterface, err error) {
+	defer func() {
+		if re := recover(); re != nil {
+			vmis, payments = nil, nil
+			err = L.Errorf("interface_validation_failed", fmt.Sprint(re))
+		}
+	}()
+	return interfaceLoader()
+}
+
+// Builds the interfaces using the loader, validates them, and swaps them in.
+func ReloadInterfaces() (*InterfaceReloadResult, error) {
+	if interfaceLoader == nil {
+		return nil, L.Error("interface_loader_missing")
+	}
+	interfaceReloadMutex.Lock()
+	defer interfaceReloadMutex.Unlock()
+
+	log.Printf("reloading interfaces")
+	deferRoutes()
+	committed := false
+	defer func() {
+		if !committed {
+			finishRoutes(false)
+		}
+	}()
+	vmis, payments, err := runInterfaceLoader()
+	if err != nil {
+		return nil, err
+	}
+
+	var failures []string
+	for region, vmi := range vmis {
+		err := vmInterfaceValidate(vmi)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("region %s: %v", region, err))
+		}
+	}
+	for method, payInterface := range payments {
+		if validator, ok := payInterface.(PaymentValidate); ok {
+			err := validator.Validate()
+			if err != nil {
+				failures = append(failures, fmt.Sprintf("payment method %s: %v", method, err))
+			}
+		}
+	}
+	if len(failures) > 0 {
+		sort.Strings(failures)
+		return nil, L.Errorf("interface_validation_failed", strings.Join(failures, "; "))
+	}
+
+	result := &InterfaceReloadResult{}
+	draining := make(map[string]bool)
+	for region, vmi := range regionInterfaceList() {
+		if vmis[region] != nil {
+			continue
+		} else if regionInUse(region) {
+			vmis[region] = vmi
+			draining[region] = true
+			result.Draining = append(result.Draining, region)
+		} else {
+			result.Removed = append(result.Removed, region)
+		}
+	}
+	for region := range vmis {
+		if !draining[region] {
+			result.Regions = append(result.Regions, region)
+		}
+	}
+	for method := range payments {
+		result.PaymentMethods = append(result.PaymentMethods, method)
+	}
+	sort.Strings(result.Regions)
+	sort.Strings(result.PaymentMethods)
+	sort.Strings(result.Removed)
+	sort.Strings(result.Draining)
+
+	interfacesMutex.Lock()
+	regionInterfaces = vmis
+	paymentInterfaces = payments
+	drainingRegions = draining
+	interfacesMutex.Unlock()
+	committed = true
+	finishRoutes(true)
+
+	// free resources may differ on the new backends
+	capacityCacheMutex.Lock()
+	capacityCache = make(map[string]capacityCacheEntry)
+	capacityCacheMutex.Unlock()
+
+	log.Printf("reloaded interfaces: regions %v, payment methods %v, removed %v, draining %v", result.Regions, result.PaymentMethods, result.Removed, result.Draining)
+	return result, nil
+}
+
+// Reloads the interfaces on SIGHUP; errors are logged, and must not stop lobster.
+func reloadInterfacesSignal() {
+	defer errorHandler(nil, nil, true)
+	_, err := ReloadInterfaces()
+	if err != nil {
+		log.Printf("failed to reload interfaces: %s", err.Error())
+	}
+}
+
+// Drops draining regions that no longer have any resources.
+func drainCron() {
+	interfacesMutex.RLock()
+	var regions []string
+	for region := range drainingRegions {
+		regions = append(regions, region)
+	}
+	interfacesMutex.RUnlock()
+
+	for _, region := range regions {
+		if regionInUse(region) {
+			continue
+		}
+		log.Printf("region %s has drained, removing its interface", region)
+		interfacesMutex.Lock()
+		// the region may have been added back by a reload in the meantime
+		if drainingRegions[region] {
+			delete(regionInterfaces, region)
+			delete(drainingRegions, region)
+		}
+		interfacesMutex.Unlock()
+	}
+}
+
+// Route whose handler can be replaced while lobster is running.
+type reloadableRoute struct {
+	mutex   sync.RWMutex
+	handler http.HandlerFunc
+}
+
+func (this *reloadableRoute) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	this.mutex.RLock()
+	handler := this.handler
+	this.mutex.RUnlock()
+	handler(w, r)
+}
+
+type pendingRoute struct {
+	path    string
+	handler http.HandlerFunc
+	method  string
+}
+
+// guards routes, routesServing, and routesPending
+var routesMutex sync.Mutex
+var routes map[string]*reloadableRoute = make(map[string]*reloadableRoute)
+
+// set once the HTTP server starts, after which new paths can no longer be added to the router
+var routesServing bool
+
+// while interfaces are being reloaded, their routes are held here until they are validated
+var routesPending []pendingRoute
+var routesDeferred bool
+
+func postMethod(onlyPost bool) string {
+	if onlyPost {
+		return "POST"
+	} else {
+		return ""
+	}
+}
+
+// Registers the handler for the path and method (empty for any method), or replaces the existing handler.
+func registerRoute(path string, handler http.HandlerFunc, method string) {
+	routesMutex.Lock()
+	defer routesMutex.Unlock()
+	if routesDeferred {
+		routesPending = append(routesPending, pendingRoute{path, handler, method})
+		return
+	}
+	setRoute(path, handler, method)
+}
+
+// Requires routesMutex.
+func setRoute(path string, handler http.HandlerFunc, method string) {
+	key := method + " " + path
+	if route := routes[key]; route != nil {
+		route.mutex.Lock()
+		route.handler = handler
+		route.mutex.Unlock()
+		return
+	} else if routesServing {
+		// the router cannot be modified safely while it is serving requests
+		log.Printf("warning: route %s was registered while running, restart lobster to serve it", key)
+		return
+	}
+	route := &reloadableRoute{handler: handler}
+	routes[key] = route
+	result := router.Handle(path, route)
+	if method != "" {
+		result.Methods(method)
+	}
+}
+
+// Holds routes registered by the interface loader until the interfaces are validated.
+func deferRoutes() {
+	routesMutex.Lock()
+	routesDeferred = true
+	routesPending = nil
+	routesMutex.Unlock()
+}
+
+// Stops holding routes, and registers the held routes if commit is set or otherwise discards them.
+func finishRoutes(commit bool) {
+	routesMutex.Lock()
+	defer routesMutex.Unlock()
+	routesDeferred = false
+	if commit {
+		for _, route := range routesPending {
+			setRoute(route.path, route.handler, route.method)
+		}
+	}
+	routesPending = nil
+}
@@ -0,0 +1,88 @@
+package lobster
+
+import "errors"
+import "testing"
+
+type testRegistryVmi struct {
+	TestVmi
+	err error
+}
+
+func (this *testRegistryVmi) Validate() error {
+	return this.err
+}
+
+func TestReloadInterfaces(t *testing.T) {
+	TestReset()
+	oldInterfaces := regionInterfaceList()
+	oldLoader := interfaceLoader
+	defer func() {
+		interfacesMutex.Lock()
+		regionInterfaces = oldInterfaces
+		drainingRegions = make(map[string]bool)
+		interfacesMutex.Unlock()
+		interfaceLoader = oldLoader
+	}()
+
+	first := &testRegistryVmi{}
+	vmis := map[string]VmInterface{"testa": first, "testb": &testRegistryVmi{}}
+	interfaceLoader = func() (map[string]VmInterface, map[string]PaymentInterface, error) {
+		return vmis, nil, nil
+	}
+	if _, err := ReloadInterfaces(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	} else if regionInterface("testa") != first || regionInterface("testb") == nil {
+		t.Fatal("Interfaces not registered after reload")
+	}
+
+	// failed validation keeps the running interfaces
+	vmis = map[string]VmInterface{"testa": &testRegistryVmi{err: errors.New("invalid credentials")}}
+	if _, err := ReloadInterfaces(); err == nil {
+		t.Fatal("Reload passed with invalid interface")
+	} else if regionInterface("testa") != first || regionInterface("testb") == nil {
+		t.Fatal("Interfaces changed after failed reload")
+	}
+
+	// a panicking constructor fails the reload without leaving routes deferred
+	interfaceLoader = func() (map[string]VmInterface, map[string]PaymentInterface, error) {
+		panic(errors.New("authentication failed"))
+	}
+	if _, err := ReloadInterfaces(); err == nil {
+		t.Fatal("Reload passed with panicking loader")
+	} else if routesDeferred {
+		t.Fatal("Routes still deferred after failed reload")
+	} else if regionInterface("testa") != first {
+		t.Fatal("Interfaces changed after failed reload")
+	}
+	interfaceLoader = func() (map[string]VmInterface, map[string]PaymentInterface, error) {
+		return vmis, nil, nil
+	}
+
+	// testa still has a virtual machine, so it drains, while testb is removed
+	userId := TestUser()
+	vmId := TestVm(userId)
+	db.Exec("UPDATE vms SET region = 'testa' WHERE id = ?", vmId)
+	vmis = map[string]VmInterface{"testc": &testRegistryVmi{}}
+	result, err := ReloadInterfaces()
+	if err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	} else if len(result.Draining) != 1 || result.Draining[0] != "testa" {
+		t.Fatalf("Expected testa to be draining, got %v", result.Draining)
+	} else if regionInterface("testb") != nil {
+		t.Fatal("Unused region not removed")
+	} else if regionInterface("testa") != first || !regionDraining("testa") {
+		t.Fatal("Region in use not kept as draining")
+	} else if regionEnabled("testa") {
+		t.Fatal("Draining region is enabled")
+	}
+
+	drainCron()
+	if regionInterface("testa") == nil {
+		t.Fatal("Region removed while still in use")
+	}
+	db.Exec("DELETE FROM vms WHERE id = ?", vmId)
+	drainCron()
+	if regionInterface("testa") != nil || regionDraining("testa") {
+		t.Fatal("Region not removed after draining")
+	}
+}
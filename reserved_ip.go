@@ -0,0 +1,228 @@
+package lobster
+
+import "fmt"
+import "log"
+import "time"
+
+// database objects
+
+// Reserved IP addresses belong to a user in a region rather than to a virtual machine.
+// They can be moved between virtual machines, and are kept when a virtual machine is re-imaged or deleted.
+type ReservedIp struct {
+	Id             int
+	UserId         int
+	Region         string
+	Identification string
+	Ip             string
+	VmId           int // zero if not attached
+	CreatedTime    time.Time
+}
+
+func reservedIpListHelper(rows Rows) []*ReservedIp {
+	defer rows.Close()
+	reservedIps := make([]*ReservedIp, 0)
+	for rows.Next() {
+		reservedIp := ReservedIp{}
+		rows.Scan(&reservedIp.Id, &reservedIp.UserId, &reservedIp.Region, &reservedIp.Identification, &reservedIp.Ip, &reservedIp.VmId, &reservedIp.CreatedTime)
+		reservedIps = append(reservedIps, &reservedIp)
+	}
+	return reservedIps
+}
+
+const RESERVED_IP_QUERY = "SELECT id, user_id, region, identification, ip, vm_id, time_created FROM reserved_ips"
+
+func reservedIpList(userId int) []*ReservedIp {
+	return reservedIpListHelper(db.Query(RESERVED_IP_QUERY+" WHERE user_id = ? ORDER BY region, id", userId))
+}
+
+func reservedIpListVm(vmId int) []*ReservedIp {
+	return reservedIpListHelper(db.Query(RESERVED_IP_QUERY+" WHERE vm_id = ? ORDER BY id", vmId))
+}
+
+// Returns reserved IPs of the user in the region that are not attached to any virtual machine.
+func reservedIpListAvailable(userId int, region string) []*ReservedIp {
+	return reservedIpListHelper(db.Query(RESERVED_IP_QUERY+" WHERE user_id = ? AND region = ? AND vm_id = 0 ORDER BY id", userId, region))
+}
+
+func reservedIpGet(userId int, reservedIpId int) *ReservedIp {
+	reservedIps := reservedIpListHelper(db.Query(RESERVED_IP_QUERY+" WHERE id = ? AND user_id = ?", reservedIpId, userId))
+	if len(reservedIps) == 1 {
+		return reservedIps[0]
+	} else {
+		return nil
+	}
+}
+
+// Returns whether reserved IPs can be allocated in the region.
+func regionCanReservedIps(region string) bool {
+	return vmiCanFloatingIPs(regionInterface(region))
+}
+
+func vmiCanFloatingIPs(vmi VmInterface) bool {
+	if _, ok := vmi.(VMIFloatingIPs); !ok {
+		return false
+	} else if enabled, ok := vmi.(VMIFloatingIPsEnabled); ok {
+		return enabled.CanFloatingIPs()
+	} else {
+		return true
+	}
+}
+
+func reservedIpCreate(userId int, region string) (int, error) {
+	// validate credit
+	user := UserDetails(userId)
+	if user == nil {
+		return 0, L.Error("invalid_account")
+	} else if user.Credit < MINIMUM_CREDIT {
+		return 0, L.Error("insufficient_credit")
+	}
+
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM reserved_ips WHERE user_id = ?", userId).Scan(&count)
+	if count >= MAX_RESERVED_IPS {
+		return 0, L.Errorf("exceeded_reserved_ip_limit", MAX_RESERVED_IPS)
+	}
+	err := userQuotaCheck(userId, map[string]int{"ips": 1})
+	if err != nil {
+		return 0, err
+	}
+
+	// validate region
+	vmi := regionInterface(region)
+	if vmi == nil || !regionEnabled(region) {
+		return 0, L.Error("invalid_region")
+	}
+	vmiFloating, ok := vmi.(VMIFloatingIPs)
+	if !ok || !vmiCanFloatingIPs(vmi) {
+		return 0, L.Error("operation_unsupported")
+	}
+
+	log.Printf("reservedIpCreate(%d, %s)", userId, region)
+	identification, ip, err := vmiFloating.FloatingIPAllocate()
+	if err != nil {
+		return 0, err
+	}
+	result := db.Exec(
+		"INSERT INTO reserved_ips (user_id, region, identification, ip) VALUES (?, ?, ?, ?)",
+		userId, region, identification, ip,
+	)
+	return result.LastInsertId(), nil
+}
+
+func reservedIpDelete(userId int, reservedIpId int) error {
+	reservedIp := reservedIpGet(userId, reservedIpId)
+	if reservedIp == nil {
+		return L.Error("invalid_reserved_ip")
+	} else if reservedIp.VmId != 0 {
+		return L.Error("reserved_ip_in_use")
+	}
+
+	vmi, ok := vmGetInterface(reservedIp.Region).(VMIFloatingIPs)
+	if !ok {
+		return L.Error("operation_unsupported")
+	}
+
+	log.Printf("reservedIpDelete(%d, %d)", userId, reservedIpId)
+	err := vmi.FloatingIPRelease(reservedIp.Identification)
+	if err != nil {
+		return err
+	}
+	db.Exec("DELETE FROM reserved_ips WHERE id = ?", reservedIp.Id)
+	return nil
+}
+
+// Releases the reserved IP on the back-end (detaching it first if needed) and removes it from the database.
+// Errors from the back-end are reported but otherwise ignored.
+func reservedIpDeleteForce(reservedIpId int) {
+	reservedIps := reservedIpListHelper(db.Query(RESERVED_IP_QUERY+" WHERE id = ?", reservedIpId))
+	if len(reservedIps) != 1 {
+		return
+	}
+	reservedIp := reservedIps[0]
+
+	vmi, ok := vmGetInterface(reservedIp.Region).(VMIFloatingIPs)
+	if ok {
+		if reservedIp.VmId != 0 {
+			vm := vmGet(reservedIp.VmId)
+			if vm != nil {
+				err := vmi.FloatingIPDetach(vm, reservedIp.Identification)
+				if err != nil {
+					ReportError(err, "reserved IP force detach failed", fmt.Sprintf("reserved_ip_id=%d, vm_id=%d", reservedIp.Id, vm.Id))
+				}
+			}
+		}
+		err := vmi.FloatingIPRelease(reservedIp.Identification)
+		if err != nil {
+			ReportError(err, "reserved IP force release failed", fmt.Sprintf("reserved_ip_id=%d, identification=%s", reservedIp.Id, reservedIp.Identification))
+		}
+	}
+	db.Exec("DELETE FROM reserved_ips WHERE id = ?", reservedIp.Id)
+}
+
+func (vm *VirtualMachine) AttachReservedIp(reservedIpId int) error {
+	reservedIp := reservedIpGet(vm.UserId, reservedIpId)
+	if reservedIp == nil {
+		return L.Error("invalid_reserved_ip")
+	} else if reservedIp.Region != vm.Region {
+		return L.Error("reserved_ip_wrong_region")
+	} else if reservedIp.VmId != 0 {
+		return L.Error("reserved_ip_in_use")
+	}
+
+	log.Printf("vmAttachReservedIp(%d, %d)", vm.Id, reservedIpId)
+	return vm.do(func(vm *VirtualMachine) error {
+		vmi, ok := vmGetInterface(vm.Region).(VMIFloatingIPs)
+		if !ok {
+			return L.Error("operation_unsupported")
+		}
+		err := vmi.FloatingIPAttach(vm, reservedIp.Identification)
+		if err != nil {
+			return err
+		}
+		db.Exec("UPDATE reserved_ips SET vm_id = ? WHERE id = ?", vm.Id, reservedIp.Id)
+		return nil
+	})
+}
+
+func (vm *VirtualMachine) DetachReservedIp(reservedIpId int) error {
+	reservedIp := reservedIpGet(vm.UserId, reservedIpId)
+	if reservedIp == nil || reservedIp.VmId != vm.Id {
+		return L.Error("invalid_reserved_ip")
+	}
+
+	log.Printf("vmDetachReservedIp(%d, %d)", vm.Id, reservedIpId)
+	return vm.do(func(vm *VirtualMachine) error {
+		vmi, ok := vmGetInterface(vm.Region).(VMIFloatingIPs)
+		if !ok {
+			return L.Error("operation_unsupported")
+		}
+		err := vmi.FloatingIPDetach(vm, reservedIp.Identification)
+		if err != nil {
+			return err
+		}
+		db.Exec("UPDATE reserved_ips SET vm_id = 0 WHERE id = ?", reservedIp.Id)
+		return nil
+	})
+}
+
+// Attaches the virtual machine's reserved IPs again, for back-ends that drop them when re-imaging.
+// Errors are reported but otherwise ignored, since the re-image itself succeeded.
+func (vm *VirtualMachine) reattachReservedIps() {
+	vmi, ok := vmGetInterface(vm.Region).(VMIFloatingIPs)
+	if !ok {
+		return
+	}
+	for _, reservedIp := range reservedIpListVm(vm.Id) {
+		err := vmi.FloatingIPAttach(vm, reservedIp.Identification)
+		if err != nil {
+			ReportError(err, "reserved IP re-attach failed", fmt.Sprintf("vm_id=%d, reserved_ip_id=%d", vm.Id, reservedIp.Id))
+		}
+	}
+}
+
+// Returns the number of reserved IPs of the user that are not attached to a virtual machine.
+func reservedIpUserUnattached(userId int) int {
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM reserved_ips WHERE user_id = ? AND vm_id = 0", userId).Scan(&count)
+	return count
+}
@@ -0,0 +1,84 @@
+package lobster
+
+import "testing"
+
+func TestBillingReservedIps(t *testing.T) {
+	TestReset()
+	cfg.Billing.ReservedIpFee = 0.005
+	userId := TestUser()
+	db.Exec("UPDATE users SET status = 'active', time_billed = DATE_SUB(NOW(), INTERVAL 3 HOUR) WHERE id = ?", userId)
+	db.Exec("INSERT INTO reserved_ips (user_id, region, identification, ip) VALUES (?, 'test', 'a', '192.0.2.1')", userId)
+	db.Exec("INSERT INTO reserved_ips (user_id, region, identification, ip, vm_id) VALUES (?, 'test', 'b', '192.0.2.2', 1)", userId)
+
+	// only the unattached address should be charged
+	serviceBilling()
+	expectedCharge := int64(cfg.Billing.ReservedIpFee*BILLING_PRECISION) * 3
+	if !testVerifyCharge(userId, "reserved_ips", expectedCharge) {
+		t.Fatalf("Expected reserved IP charge of %d for one unattached address over three hours", expectedCharge)
+	}
+	if UserCreditSummary(userId).Hourly != int64(cfg.Billing.ReservedIpFee*BILLING_PRECISION) {
+		t.Fatal("Hourly rate in credit summary does not include unattached reserved IPs")
+	}
+}
+
+type testFloatingVmi struct {
+	TestVmi
+	enabled  bool
+	released []string
+}
+
+func (this *testFloatingVmi) CanFloatingIPs() bool {
+	return this.enabled
+}
+
+func (this *testFloatingVmi) FloatingIPAllocate() (string, string, error) {
+	return "test", "192.0.2.1", nil
+}
+
+func (this *testFloatingVmi) FloatingIPRelease(identification string) error {
+	this.released = append(this.released, identification)
+	return nil
+}
+
+func (this *testFloatingVmi) FloatingIPAttach(vm *VirtualMachine, identification string) error {
+	return nil
+}
+
+func (this *testFloatingVmi) FloatingIPDetach(vm *VirtualMachine, identification string) error {
+	return nil
+}
+
+func TestRegionCanReservedIps(t *testing.T) {
+	vmi := &testFloatingVmi{}
+	defer TestRegion("testfloating", vmi)()
+	defer TestRegion("testnofloating", &TestVmi{})()
+	if regionCanReservedIps("testnofloating") {
+		t.Fatal("Reserved IPs enabled for interface without floating IPs")
+	} else if regionCanReservedIps("testfloating") {
+		t.Fatal("Reserved IPs enabled for interface that disabled them")
+	}
+	vmi.enabled = true
+	if !regionCanReservedIps("testfloating") {
+		t.Fatal("Reserved IPs not enabled for interface that enabled them")
+	}
+}
+
+func TestBillingTerminateReservedIps(t *testing.T) {
+	TestReset()
+	cfg.Billing.ReservedIpFee = 0.005
+	cfg.BillingNotifications.LowBalanceIntervals = 1
+	vmi := &testFloatingVmi{enabled: true}
+	defer TestRegion("testfloating", vmi)()
+
+	// the user only has an unattached reserved IP, and is past the termination threshold
+	userId := TestUser()
+	db.Exec("UPDATE users SET credit = -1000, billing_low_count = 10 WHERE id = ?", userId)
+	db.Exec("INSERT INTO reserved_ips (user_id, region, identification, ip) VALUES (?, 'testfloating', 'a', '192.0.2.1')", userId)
+
+	testForceUserBilling(userId)
+	if len(reservedIpList(userId)) != 0 {
+		t.Fatal("Reserved IP not deleted on account termination")
+	} else if len(vmi.released) != 1 || vmi.released[0] != "a" {
+		t.Fatalf("Expected reserved IP to be released on the back-end, got %v", vmi.released)
+	}
+}
@@ -1,12 +1,17 @@
 package lobster
 
+import cryptossh "golang.org/x/crypto/ssh"
+
+import "strings"
+
 // database objects
 
 type SSHKey struct {
-	ID     int
-	UserID int
-	Name   string
-	Key    string
+	ID          int
+	UserID      int
+	Name        string
+	Key         string
+	Fingerprint string
 }
 
 func keyListHelper(rows Rows) []*SSHKey {
@@ -14,13 +19,19 @@ func keyListHelper(rows Rows) []*SSHKey {
 	keys := make([]*SSHKey, 0)
 	for rows.Next() {
 		key := SSHKey{}
-		rows.Scan(&key.ID, &key.UserID, &key.Name, &key.Key)
+		rows.Scan(&key.ID, &key.UserID, &key.Name, &key.Key, &key.Fingerprint)
+
+		// keys added before fingerprints were recorded
+		if key.Fingerprint == "" {
+			_, key.Fingerprint, _ = keyParse(key.Key)
+		}
+
 		keys = append(keys, &key)
 	}
 	return keys
 }
 
-const SSHKEY_QUERY = "SELECT id, user_id, name, val FROM sshkeys"
+const SSHKEY_QUERY = "SELECT id, user_id, name, val, fingerprint FROM sshkeys"
 
 func keyListAll() []*SSHKey {
 	return keyListHelper(
@@ -53,16 +64,64 @@ func keyGet(userID int, id int) *SSHKey {
 	}
 }
 
+// Returns the keys with the given IDs, or error if any of them do not exist.
+func keyGetMany(userID int, ids []int) ([]SSHKey, error) {
+	var keys []SSHKey
+	seen := make(map[int]bool)
+	for _, id := range ids {
+		if id == 0 || seen[id] {
+			continue
+		}
+		seen[id] = true
+		key := keyGet(userID, id)
+		if key == nil {
+			return nil, L.Error("sshkey_not_exist")
+		}
+		keys = append(keys, *key)
+	}
+	return keys, nil
+}
+
+// Parses a public key in authorized_keys format.
+// Returns the key in normalized form (options and extra whitespace removed) along with its SHA256 fingerprint.
+func keyParse(key string) (string, string, error) {
+	publicKey, comment, _, rest, err := cryptossh.ParseAuthorizedKey([]byte(strings.TrimSpace(key)))
+	if err != nil {
+		return "", "", L.Error("invalid_sshkey_format")
+	} else if strings.TrimSpace(string(rest)) != "" {
+		return "", "", L.Error("sshkey_multiple")
+	}
+
+	normalized := strings.TrimSpace(string(cryptossh.MarshalAuthorizedKey(publicKey)))
+	if comment != "" {
+		normalized += " " + comment
+	}
+	return normalized, cryptossh.FingerprintSHA256(publicKey), nil
+}
+
 func keyAdd(userID int, name string, key string) (int, error) {
 	if name == "" {
 		return 0, L.Error("name_empty")
+	} else if len(name) > MAX_SSHKEY_NAME_LENGTH {
+		return 0, L.Errorf("name_too_long", MAX_SSHKEY_NAME_LENGTH)
 	} else if key == "" {
 		return 0, L.Error("key_empty")
 	}
 
+	key, fingerprint, err := keyParse(key)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM sshkeys WHERE user_id = ? AND fingerprint = ?", userID, fingerprint).Scan(&count)
+	if count > 0 {
+		return 0, L.Error("sshkey_duplicate")
+	}
+
 	result := db.Exec(
-		"INSERT INTO sshkeys (user_id, name, val) VALUES (?, ?, ?)",
-		userID, name, key,
+		"INSERT INTO sshkeys (user_id, name, val, fingerprint) VALUES (?, ?, ?, ?)",
+		userID, name, key, fingerprint,
 	)
 	return result.LastInsertId(), nil
 }
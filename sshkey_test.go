@@ -0,0 +1,25 @@
+package lobster
+
+import "testing"
+
+const TEST_SSHKEY = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAID8J2sodPC5FFpdSvPNN1PZoFwW+bjL+L+PROQK/kW0S alice@example"
+const TEST_SSHKEY_FINGERPRINT = "SHA256:jh5G056pLfGPdMK9jGjYwS6SSmUWqChRZwVTTY9JrIA"
+
+func TestKeyParse(t *testing.T) {
+	key, fingerprint, err := keyParse(TEST_SSHKEY)
+	if err != nil {
+		t.Fatalf("Failed to parse valid key: %v", err)
+	} else if key != TEST_SSHKEY {
+		t.Fatalf("Normalized key %s does not match %s", key, TEST_SSHKEY)
+	} else if fingerprint != TEST_SSHKEY_FINGERPRINT {
+		t.Fatalf("Fingerprint %s does not match %s", fingerprint, TEST_SSHKEY_FINGERPRINT)
+	}
+
+	// options and surrounding whitespace should be removed
+	key, _, err = keyParse("  no-pty,no-agent-forwarding " + TEST_SSHKEY + "\n")
+	if err != nil {
+		t.Fatalf("Failed to parse valid key with options: %v", err)
+	} else if key != TEST_SSHKEY {
+		t.Fatalf("Normalized key %s does not match %s", key, TEST_SSHKEY)
+	}
+}
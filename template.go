@@ -12,6 +12,7 @@ var templates map[string]*template.Template
 func templateFuncMap() template.FuncMap {
 	return template.FuncMap{
 		"Title": strings.Title,
+		"Join":  strings.Join,
 		"FormatTime": func(t time.Time) string {
 			return t.Format(TIME_FORMAT)
 		},
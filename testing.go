@@ -2,11 +2,12 @@ package lobster
 
 import "github.com/gorilla/mux"
 
+import "github.com/LunaNode/lobster/i18n"
 import "github.com/LunaNode/lobster/utils"
 
 const TEST_BANDWIDTH = 1000
 
-var testTables []string = []string{"users", "region_bandwidth", "vms", "plans", "charges", "sessions", "form_tokens", "antiflood"}
+var testTables []string = []string{"users", "region_bandwidth", "vms", "plans", "charges", "sessions", "form_tokens", "antiflood", "jobs", "backup_schedules", "volumes", "vm_metrics", "vm_drift", "vm_tags", "reserved_ips", "networks", "network_vms", "actions", "power_schedules", "health_checks", "vm_transfers", "images", "user_quotas", "region_capacity"}
 
 func TestReset() {
 	cfg = &Config{
@@ -29,6 +30,9 @@ func TestReset() {
 	}
 	db = MakeDatabase()
 
+	// without a language file, errors are created from their identifiers
+	L = new(i18n.Section)
+
 	// clear all tables
 	for _, table := range testTables {
 		db.Exec("DELETE FROM " + table)
@@ -53,3 +57,21 @@ func TestVm(userId int) int {
 	vmId := result.LastInsertId()
 	return vmId
 }
+
+// Virtual machine interface for tests, which only implements BandwidthAccounting.
+// Tests embed it to add the methods that they need; other VmInterface methods must not be called.
+type TestVmi struct {
+	VmInterface
+}
+
+func (this *TestVmi) BandwidthAccounting(vm *VirtualMachine) int64 {
+	return 0
+}
+
+// Registers the interface for the region, and returns a function that removes it.
+func TestRegion(region string, vmi VmInterface) func() {
+	regionInterfaces[region] = vmi
+	return func() {
+		delete(regionInterfaces, region)
+	}
+}
@@ -157,6 +157,8 @@ func UserCreditSummary(userId int) *CreditSummary {
 	for _, vm := range vms {
 		summary.Hourly += vm.Plan.Price
 	}
+	summary.Hourly += int64(volumeUserSize(userId)) * int64(cfg.Billing.VolumeFee*BILLING_PRECISION)
+	summary.Hourly += int64(reservedIpUserUnattached(userId)) * int64(cfg.Billing.ReservedIpFee*BILLING_PRECISION)
 	summary.Daily = summary.Hourly * 24
 	summary.Monthly = summary.Daily * 30
 
@@ -270,8 +272,10 @@ func userBilling(userId int) {
 	rows := db.Query(
 		"SELECT credit, email, IFNULL(TIMESTAMPDIFF(HOUR, last_billing_notify, NOW()), 0), billing_low_count "+
 			"FROM users "+
-			"WHERE id = ? AND last_billing_notify < DATE_SUB(NOW(), INTERVAL ? HOUR) AND "+
-			"(SELECT COUNT(*) FROM vms WHERE vms.user_id = users.id) > 0",
+			"WHERE id = ? AND last_billing_notify < DATE_SUB(NOW(), INTERVAL ? HOUR) AND ("+
+			"(SELECT COUNT(*) FROM vms WHERE vms.user_id = users.id) > 0 OR "+
+			"(SELECT COUNT(*) FROM volumes WHERE volumes.user_id = users.id AND volumes.status = 'active') > 0 OR "+
+			"(SELECT COUNT(*) FROM reserved_ips WHERE reserved_ips.user_id = users.id AND reserved_ips.vm_id = 0) > 0)",
 		userId,
 		cfg.BillingNotifications.Frequency,
 	)
@@ -286,6 +290,10 @@ func userBilling(userId int) {
 	rows.Scan(&credit, &email, &lastBilledHoursAgo, &billingLowCount)
 	rows.Close()
 	hourly := UserCreditSummary(userId).Hourly
+	if hourly <= 0 {
+		// nothing is billed hourly, so the balance cannot run out
+		return
+	}
 
 	if credit <= hourly*int64(cfg.BillingNotifications.LowBalanceIntervals) {
 		canSuspendBalance := credit < hourly*int64(cfg.BillingTermination.SuspendBalanceIntervals)
@@ -297,9 +305,23 @@ func userBilling(userId int) {
 
 			if canTerminateBalance && canTerminateNotifications && lastBilledHoursAgo > 0 && lastBilledHoursAgo <= 48 {
 				// terminte the account
+				// volumes and reserved IPs are deleted first, since they must be detached before the virtual machines are gone
+				for _, volume := range volumeList(userId) {
+					volumeDeleteForce(volume.Id)
+				}
+				for _, reservedIp := range reservedIpList(userId) {
+					reservedIpDeleteForce(reservedIp.Id)
+				}
 				vms := vmList(userId)
 				for _, vm := range vms {
-					ReportError(vm.Delete(userId), "failed to delete VM", fmt.Sprintf("user_id: %d, vm_id: %d", userId, vm.Id))
+					if vm.Protected {
+						LogAction(userId, "", "Delete protected VM (account terminated)", fmt.Sprintf("VM ID: %d", vm.Id))
+					}
+					ReportError(vm.DeleteForce(userId), "failed to delete VM", fmt.Sprintf("user_id: %d, vm_id: %d", userId, vm.Id))
+				}
+				// networks are deleted after the virtual machines, whose interfaces must be removed first
+				for _, network := range networkList(userId) {
+					networkDeleteForce(network.Id)
 				}
 				MailWrap(userId, "userTerminate", nil, false)
 			} else {
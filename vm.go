@@ -1,10 +1,15 @@
 package lobster
 
+import "github.com/LunaNode/lobster/ipaddr"
+
 import "errors"
 import "fmt"
 import "log"
+import "net"
+import "strconv"
 import "strings"
 import "time"
+import "unicode/utf8"
 
 // database objects
 
@@ -20,11 +25,14 @@ type VirtualMachine struct {
 	PrivateIP      string
 	CreatedTime    time.Time
 	Suspended      string
+	Protected      bool // blocks delete, re-image, and resize
 	Plan           Plan
 	User           User
 
-	Info      *VmInfo
-	Addresses []*IpAddress
+	Info          *VmInfo
+	Addresses     []*IpAddress
+	FirewallRules []*FirewallRule
+	Tags          []string
 }
 
 // interface objects
@@ -50,6 +58,14 @@ type VmInfo struct {
 	CanSnapshot          bool
 	CanResize            bool
 	CanAddresses         bool
+	CanFirewall          bool
+	CanVolumes           bool
+	CanConsoleOutput     bool
+	CanMetrics           bool
+	CanReservedIps       bool
+	CanNetworks          bool
+	CanRescue            bool
+	CanPasswordReset     bool
 	OverrideCapabilities bool
 	PendingSnapshots     []*Image
 }
@@ -57,11 +73,21 @@ type VmInfo struct {
 type IpAddress struct {
 	Ip        string
 	PrivateIp string // blank means N/A
+	Family    string // "ipv4" or "ipv6", filled in by lobster from Ip if blank
 
 	CanRdns  bool
 	Hostname string // current rDNS setting, always blank if CanRdns is false
 }
 
+// inbound firewall rule
+type FirewallRule struct {
+	Id       string // identification assigned by the VM interface
+	Protocol string // "tcp", "udp", or "icmp"
+	PortMin  int    // port range, zero for icmp
+	PortMax  int
+	Cidr     string // source network
+}
+
 // describes an action that we can perform on a virtual machine
 type VmActionDescriptor struct {
 	Action      string
@@ -73,7 +99,7 @@ type VmActionDescriptor struct {
 
 const VM_QUERY = "SELECT vms.id, vms.user_id, vms.region, vms.name, vms.identification, " +
 	"vms.status, vms.task_pending, vms.external_ip, vms.private_ip, " +
-	"vms.time_created, vms.suspended, vms.plan_id, " +
+	"vms.time_created, vms.suspended, vms.protected, vms.plan_id, " +
 	"plans.name, plans.price, plans.ram, plans.cpu, plans.storage, plans.bandwidth, " +
 	"users.username, users.email " +
 	"FROM vms, plans, users " +
@@ -95,6 +121,7 @@ func vmListHelper(rows Rows) []*VirtualMachine {
 			&vm.PrivateIP,
 			&vm.CreatedTime,
 			&vm.Suspended,
+			&vm.Protected,
 			&vm.Plan.Id,
 			&vm.Plan.Name,
 			&vm.Plan.Price,
@@ -111,7 +138,9 @@ func vmListHelper(rows Rows) []*VirtualMachine {
 }
 
 func vmList(userId int) []*VirtualMachine {
-	return vmListHelper(db.Query(VM_QUERY+" AND vms.user_id = ? ORDER BY id DESC", userId))
+	vms := vmListHelper(db.Query(VM_QUERY+" AND vms.user_id = ? ORDER BY id DESC", userId))
+	vmLoadTags(vms)
+	return vms
 }
 
 func vmListRegion(userId int, region string) []*VirtualMachine {
@@ -152,8 +181,19 @@ func vmNameOk(name string) error {
 	}
 }
 
+func vmUserDataOk(userData string) error {
+	if len(userData) > MAX_USER_DATA_LENGTH {
+		return L.Errorf("user_data_too_long", MAX_USER_DATA_LENGTH)
+	} else if !utf8.ValidString(userData) || strings.ContainsRune(userData, 0) {
+		return L.Error("invalid_user_data")
+	} else {
+		return nil
+	}
+}
+
 type VmCreateOptions struct {
-	KeyID int
+	KeyIDs   []int
+	UserData string
 }
 
 func vmCreate(userId int, name string, planId int, imageId int, options VmCreateOptions) (int, error) {
@@ -202,40 +242,108 @@ func vmCreate(userId int, name string, planId int, imageId int, options VmCreate
 	}
 	plan.LoadMetadata()
 
-	// validate key
-	if options.KeyID != 0 {
-		key := keyGet(userId, options.KeyID)
-		if key == nil {
-			return 0, L.Error("sshkey_not_exist")
+	// validate resource quotas
+	err = userQuotaCheck(userId, planQuotaRequest(plan))
+	if err != nil {
+		return 0, err
+	}
+
+	// validate region capacity, so that we don't try to provision on a full backend
+	err = regionCapacityCheck(image.Region, plan, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	// validate keys
+	vmiOptions.SSHKeys, err = keyGetMany(userId, options.KeyIDs)
+	if err != nil {
+		return 0, err
+	}
+	if keyLimit := regionKeyLimit(image.Region); keyLimit > 0 && len(vmiOptions.SSHKeys) > keyLimit {
+		return 0, L.Errorf("too_many_sshkeys", keyLimit)
+	}
+
+	// validate user-data
+	if options.UserData != "" {
+		if !regionCanUserData(image.Region) {
+			return 0, L.Error("user_data_unsupported")
 		}
-		vmiOptions.SSHKey = *key
+		err := vmUserDataOk(options.UserData)
+		if err != nil {
+			return 0, err
+		}
+		if len(vmiOptions.SSHKeys) > 0 && !regionCanUserDataKeys(image.Region) {
+			return 0, L.Error("user_data_keys_unsupported")
+		}
+		vmiOptions.UserData = options.UserData
 	}
 
 	// create the virtual machine asynchronously
 	result := db.Exec("INSERT INTO vms (user_id, region, plan_id, name, status) VALUES (?, ?, ?, ?, ?)", userId, image.Region, planId, name, "provisioning")
 	vmId := result.LastInsertId()
+	jobCreate(userId, vmId, "vmCreate", vmCreateJobData{PlanId: plan.Id, Options: vmiOptions})
+	return vmId, nil
+}
 
-	go func() {
-		defer errorHandler(nil, nil, true)
-		vm := vmGet(vmId)
-		vm.Plan = *plan // use plan from planGetRegion so that we have the region-specific identification
-		vmIdentification, err := vmGetInterface(image.Region).VmCreate(vm, &vmiOptions)
-		if err != nil {
-			ReportError(
-				err,
-				"vm creation failed",
-				fmt.Sprintf("hostname=%s, plan_id=%d, image_identification=%s", name, plan.Id, image.Identification),
-			)
-			db.Query("UPDATE vms SET status = 'error' WHERE id = ?", vmId)
-			MailWrap(userId, "vmCreateError", VmCreateErrorEmail{Id: vmId, Name: name}, true)
-			return
-		}
+type vmCreateJobData struct {
+	PlanId  int
+	Options VMIVmCreateOptions
 
-		db.Exec("UPDATE vms SET status = 'active', identification = ? WHERE id = ?", vmIdentification, vmId)
-		MailWrap(userId, "vmCreate", VmCreateEmail{Id: vmId, Name: name}, true)
-	}()
+	// Set before calling VmCreate. The backend may have created the instance even if VmCreate
+	// did not return, e.g. if lobster exited, so the job is then failed instead of creating another.
+	Started bool
+}
 
-	return vmId, nil
+func vmCreateJob(job *Job) error {
+	var data vmCreateJobData
+	err := job.Decode(&data)
+	if err != nil {
+		return err
+	}
+
+	vm := vmGet(job.VmId)
+	if vm == nil || vm.Identification != "" {
+		// virtual machine was already created before we were interrupted
+		return nil
+	}
+
+	// use plan from planGetRegion so that we have the region-specific identification
+	plan := planGetRegion(vm.Region, data.PlanId)
+	if plan == nil {
+		return L.Error("no_such_plan")
+	}
+	plan.LoadMetadata()
+	vm.Plan = *plan
+
+	if data.Started {
+		return L.Errorf("vm_create_interrupted", vm.Region, vm.Name)
+	}
+	data.Started = true
+	job.Save(data)
+
+	vmIdentification, err := vmGetInterface(vm.Region).VmCreate(vm, &data.Options)
+	if err != nil {
+		return err
+	}
+
+	result := db.Exec("UPDATE vms SET status = 'active', identification = ? WHERE id = ?", vmIdentification, vm.Id)
+	if result.RowsAffected() == 0 {
+		// the virtual machine was deleted while it was being created
+		log.Printf("Virtual machine %d was deleted during creation, deleting instance %s", vm.Id, vmIdentification)
+		vm.Identification = vmIdentification
+		return vmGetInterface(vm.Region).VmDelete(vm)
+	}
+	MailWrap(vm.UserId, "vmCreate", VmCreateEmail{Id: vm.Id, Name: vm.Name}, true)
+	return nil
+}
+
+func vmCreateJobFail(job *Job, err error) {
+	vm := vmGet(job.VmId)
+	if vm == nil {
+		return
+	}
+	db.Exec("UPDATE vms SET status = 'error' WHERE id = ?", vm.Id)
+	MailWrap(vm.UserId, "vmCreateError", VmCreateErrorEmail{Id: vm.Id, Name: vm.Name}, true)
 }
 
 func (vm *VirtualMachine) LoadInfo() {
@@ -279,6 +387,17 @@ func (vm *VirtualMachine) LoadInfo() {
 		_, vm.Info.CanSnapshot = vmi.(VMISnapshot)
 		_, vm.Info.CanResize = vmi.(VMIResize)
 		_, vm.Info.CanAddresses = vmi.(VMIAddresses)
+		_, vm.Info.CanFirewall = vmi.(VMIFirewall)
+		_, vm.Info.CanVolumes = vmi.(VMIVolumes)
+		vm.Info.CanReservedIps = vmiCanFloatingIPs(vmi)
+		_, vm.Info.CanNetworks = vmi.(VMINetworks)
+		_, vm.Info.CanRescue = vmi.(VMIRescue)
+		_, vm.Info.CanPasswordReset = vmi.(VMIPasswordReset)
+		_, vm.Info.CanConsoleOutput = vmi.(VMIConsoleOutput)
+		_, vm.Info.CanMetrics = vmi.(VMIMetrics)
+	}
+	if vmInfoExtractRescueActions(vm.Info) {
+		vm.Info.CanRescue = true
 	}
 
 	vm.Info.PendingSnapshots = imageListVmPending(vm.Id)
@@ -350,7 +469,39 @@ func (vm *VirtualMachine) Vnc() (string, error) {
 	return url, err
 }
 
-func vmReimage(userId int, vmId int, imageId int) error {
+// Resets the root password of the virtual machine and returns the new password.
+// The reset is logged and the user is notified by email, but the password itself is only returned here.
+func (vm *VirtualMachine) ResetPassword(ip string) (string, error) {
+	log.Printf("vmResetPassword(%d)", vm.Id)
+	var password string
+	err := vm.do(func(vm *VirtualMachine) error {
+		vmi, ok := vmGetInterface(vm.Region).(VMIPasswordReset)
+		if !ok {
+			return L.Error("vm_password_reset_unsupported")
+		}
+
+		passwordTry, err := vmi.VmResetPassword(vm)
+		if err != nil {
+			return err
+		}
+		password = passwordTry
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	LogAction(vm.UserId, ip, "Reset VM password", fmt.Sprintf("VM ID: %d", vm.Id))
+	MailWrap(vm.UserId, "vmPasswordReset", VmPasswordResetEmail{Id: vm.Id, Name: vm.Name, Ip: ip}, false)
+	return password, nil
+}
+
+type VmReimageOptions struct {
+	KeyIDs   []int
+	UserData string
+}
+
+func vmReimage(userId int, vmId int, imageId int, options VmReimageOptions) error {
 	// validate image ID
 	image := imageGet(userId, imageId)
 	if image == nil {
@@ -362,13 +513,47 @@ func vmReimage(userId int, vmId int, imageId int) error {
 	vm := vmGetUser(userId, vmId)
 	if vm == nil {
 		return L.Error("invalid_vm")
+	} else if err := vm.checkProtected(); err != nil {
+		return err
+	}
+
+	vmiOptions := VMIReimageOptions{
+		ImageIdentification: image.Identification,
+	}
+
+	// validate keys
+	keys, err := keyGetMany(userId, options.KeyIDs)
+	if err != nil {
+		return err
+	}
+	if len(keys) > 0 {
+		if !regionCanReimageKeys(vm.Region) {
+			return L.Error("reimage_keys_unsupported")
+		}
+		vmiOptions.SSHKeys = keys
+	}
+
+	// validate user-data
+	if options.UserData != "" {
+		if !regionCanReimageUserData(vm.Region) {
+			return L.Error("user_data_unsupported")
+		}
+		err := vmUserDataOk(options.UserData)
+		if err != nil {
+			return err
+		}
+		vmiOptions.UserData = options.UserData
 	}
 
 	log.Printf("vmReimage(%d, %d, %d)", userId, vmId, imageId)
 	return vm.do(func(vm *VirtualMachine) error {
 		vmi, ok := vmGetInterface(vm.Region).(VMIReimage)
 		if ok {
-			return vmi.VmReimage(vm, image.Identification)
+			err := vmi.VmReimage(vm, &vmiOptions)
+			if err == nil {
+				vm.reattachReservedIps()
+			}
+			return err
 		} else {
 			return L.Error("vm_reimage_unsupported")
 		}
@@ -379,7 +564,16 @@ func (vm *VirtualMachine) Snapshot(name string) (int, error) {
 	if name == "" {
 		return 0, L.Error("name_empty")
 	}
+	err := userQuotaCheck(vm.UserId, map[string]int{"snapshots": 1})
+	if err != nil {
+		return 0, err
+	}
+	return vm.snapshot(name)
+}
 
+// Takes a snapshot without checking the snapshot quota.
+// Used for scheduled backups, which are limited by their retention instead, and for migration.
+func (vm *VirtualMachine) snapshot(name string) (int, error) {
 	log.Printf("vmSnapshot(%d, %s)", vm.Id, name)
 	var imageId int
 	err := vm.do(func(vm *VirtualMachine) error {
@@ -404,10 +598,25 @@ func (vm *VirtualMachine) Snapshot(name string) (int, error) {
 }
 
 func (vm *VirtualMachine) Resize(planId int) error {
+	if err := vm.checkProtected(); err != nil {
+		return err
+	}
 	plan := planGetRegion(vm.Region, planId)
 	if plan == nil {
 		return L.Error("no_such_plan")
 	}
+	err := userQuotaCheck(vm.UserId, map[string]int{
+		"cpu":     plan.Cpu - vm.Plan.Cpu,
+		"ram":     plan.Ram - vm.Plan.Ram,
+		"storage": plan.Storage - vm.Plan.Storage,
+	})
+	if err != nil {
+		return err
+	}
+	err = regionCapacityCheck(vm.Region, plan, &vm.Plan)
+	if err != nil {
+		return err
+	}
 
 	log.Printf("vmResize(%d, %d)", vm.Id, planId)
 	return vm.do(func(vm *VirtualMachine) error {
@@ -467,9 +676,19 @@ func (vm *VirtualMachine) LoadAddresses() error {
 	if !ok {
 		return L.Error("operation_unsupported")
 	}
-	var err error
-	vm.Addresses, err = vmi.VmAddresses(vm)
-	return err
+	addresses, err := vmi.VmAddresses(vm)
+	if err != nil {
+		return err
+	}
+	for _, address := range addresses {
+		if address.Family == "" {
+			address.Family = ipaddr.Family(address.Ip)
+		}
+	}
+	vm.Addresses = addresses
+	// cache the number of addresses for quota usage
+	vm.SetMetadata("address_count", strconv.Itoa(len(addresses)))
+	return nil
 }
 
 func (vm *VirtualMachine) AddAddress() error {
@@ -482,11 +701,23 @@ func (vm *VirtualMachine) AddAddress() error {
 		return L.Error("ip_manage_disabled")
 	}
 
+	err = userQuotaCheck(vm.UserId, map[string]int{"ips": 1})
+	if err != nil {
+		return err
+	}
+
 	vmi, ok := vmGetInterface(vm.Region).(VMIAddresses)
 	if !ok {
 		return L.Error("operation_unsupported")
 	}
-	return vm.do(vmi.VmAddAddress)
+	return vm.do(func(vm *VirtualMachine) error {
+		err := vmi.VmAddAddress(vm)
+		if err != nil {
+			return err
+		}
+		vm.SetMetadata("address_count", strconv.Itoa(len(vm.Addresses)+1))
+		return nil
+	})
 }
 
 func (vm *VirtualMachine) RemoveAddress(ip string, privateip string) error {
@@ -501,10 +732,130 @@ func (vm *VirtualMachine) RemoveAddress(ip string, privateip string) error {
 }
 
 func (vm *VirtualMachine) SetRdns(ip string, hostname string) error {
+	// find the address on the virtual machine, since IPv6 addresses may be written in several forms
+	err := vm.LoadAddresses()
+	if err != nil {
+		return err
+	}
+	var address *IpAddress
+	parsedIp := net.ParseIP(ip)
+	for _, candidate := range vm.Addresses {
+		if parsedIp != nil && parsedIp.Equal(net.ParseIP(candidate.Ip)) {
+			address = candidate
+			break
+		}
+	}
+	if address == nil {
+		return L.Error("invalid_ip")
+	} else if !address.CanRdns {
+		return L.Error("operation_unsupported")
+	}
+
 	return vm.do(func(vm *VirtualMachine) error {
 		vmi, ok := vmGetInterface(vm.Region).(VMIAddresses)
 		if ok {
-			return vmi.VmSetRdns(vm, ip, hostname)
+			return vmi.VmSetRdns(vm, address.Ip, hostname)
+		} else {
+			return L.Error("operation_unsupported")
+		}
+	})
+}
+
+// Returns the last kilobytes KB of the serial console output, or CONSOLE_OUTPUT_DEFAULT KB if kilobytes is zero.
+func (vm *VirtualMachine) ConsoleOutput(kilobytes int) (string, error) {
+	if kilobytes == 0 {
+		kilobytes = CONSOLE_OUTPUT_DEFAULT
+	} else if kilobytes < 0 || kilobytes > CONSOLE_OUTPUT_MAX {
+		return "", L.Errorf("invalid_console_length", CONSOLE_OUTPUT_MAX)
+	}
+	if vm.Identification == "" || vm.Status != "active" {
+		return "", L.Error("vm_not_ready")
+	}
+
+	vmi, ok := vmGetInterface(vm.Region).(VMIConsoleOutput)
+	if !ok {
+		return "", L.Error("operation_unsupported")
+	}
+	length := kilobytes * 1024
+	output, err := vmi.VmConsoleOutput(vm, length)
+	if err != nil {
+		return "", err
+	}
+	if len(output) > length {
+		output = output[len(output)-length:]
+	}
+	return output, nil
+}
+
+func (vm *VirtualMachine) LoadFirewallRules() error {
+	if vm.FirewallRules != nil {
+		return nil
+	} else if vm.Identification == "" || vm.Status != "active" {
+		return L.Error("vm_not_ready")
+	}
+
+	vmi, ok := vmGetInterface(vm.Region).(VMIFirewall)
+	if !ok {
+		return L.Error("operation_unsupported")
+	}
+	var err error
+	vm.FirewallRules, err = vmi.VmFirewallRules(vm)
+	return err
+}
+
+func (vm *VirtualMachine) AddFirewallRule(protocol string, portMin int, portMax int, cidr string) error {
+	if protocol != "tcp" && protocol != "udp" && protocol != "icmp" {
+		return L.Error("invalid_firewall_protocol")
+	}
+	if protocol == "icmp" {
+		portMin = 0
+		portMax = 0
+	} else {
+		if portMax == 0 {
+			portMax = portMin
+		}
+		if portMin < 1 || portMax > 65535 || portMin > portMax {
+			return L.Error("invalid_firewall_ports")
+		}
+	}
+	if cidr == "" {
+		cidr = "0.0.0.0/0"
+	}
+	network := ipaddr.ParseCIDROrIP(cidr)
+	if network == nil {
+		return L.Error("invalid_firewall_cidr")
+	}
+
+	err := vm.LoadFirewallRules()
+	if err != nil {
+		return err
+	} else if len(vm.FirewallRules) >= MAX_FIREWALL_RULES {
+		return L.Errorf("firewall_rule_limit", MAX_FIREWALL_RULES)
+	}
+
+	rule := &FirewallRule{
+		Protocol: protocol,
+		PortMin:  portMin,
+		PortMax:  portMax,
+		Cidr:     network.String(),
+	}
+	log.Printf("vmAddFirewallRule(%d, %s, %d, %d, %s)", vm.Id, rule.Protocol, rule.PortMin, rule.PortMax, rule.Cidr)
+	return vm.do(func(vm *VirtualMachine) error {
+		vmi, ok := vmGetInterface(vm.Region).(VMIFirewall)
+		if ok {
+			return vmi.VmFirewallAdd(vm, rule)
+		} else {
+			return L.Error("operation_unsupported")
+		}
+	})
+}
+
+func (vm *VirtualMachine) RemoveFirewallRule(ruleId string) error {
+	log.Printf("vmRemoveFirewallRule(%d, %s)", vm.Id, ruleId)
+	return vm.do(func(vm *VirtualMachine) error {
+		vmi, ok := vmGetInterface(vm.Region).(VMIFirewall)
+		if ok {
+			return vmi.VmFirewallRemove(vm, ruleId)
 		} else {
 			return L.Error("operation_unsupported")
 		}
@@ -516,53 +867,124 @@ func (vm *VirtualMachine) Delete(userId int) error {
 		return L.Error("invalid_vm")
 	} else if vm.Status == "provisioning" {
 		return L.Error("vm_not_ready")
+	} else if err := vm.checkProtected(); err != nil {
+		return err
+	}
+	return vm.DeleteForce(userId)
+}
+
+// Deletes the virtual machine even if it is protected or still provisioning.
+// This should only be used when the account is terminated or a job is rolled back; callers should log the override.
+// If the virtual machine is provisioning, vmCreateJob deletes the instance once the back-end creates it.
+func (vm *VirtualMachine) DeleteForce(userId int) error {
+	if vm.UserId != userId {
+		return L.Error("invalid_vm")
 	}
 
 	log.Printf("vmDelete(%d, %d)", userId, vm.Id)
 
+	// the row is removed immediately, so the job carries everything needed to delete on the back-end
+	// attached volumes stay attached until the job has deleted the instance, which detaches them
 	if vm.Identification != "" {
-		go func() {
-			ReportError(
-				vmGetInterface(vm.Region).VmDelete(vm),
-				"failed to delete VM",
-				fmt.Sprintf("vm_id=%d, vm_identification=%s", vm.Id, vm.Identification),
-			)
-		}()
+		jobCreate(vm.UserId, vm.Id, "vmDelete", vmDeleteJobData{Region: vm.Region, Name: vm.Name, Identification: vm.Identification})
+	} else {
+		db.Exec("UPDATE volumes SET vm_id = 0 WHERE vm_id = ?", vm.Id)
 	}
 
 	vmBilling(vm.Id, true)
 	vmUpdateAdditionalBandwidth(vm)
+	db.Exec("DELETE FROM backup_schedules WHERE vm_id = ?", vm.Id)
+	db.Exec("DELETE FROM power_schedules WHERE vm_id = ?", vm.Id)
+	db.Exec("DELETE FROM health_checks WHERE vm_id = ?", vm.Id)
+	db.Exec("DELETE FROM vm_transfers WHERE vm_id = ?", vm.Id)
+	// reserved IPs are disassociated by the back-end when the virtual machine is deleted,
+	// and are kept and billed until released
+	db.Exec("UPDATE reserved_ips SET vm_id = 0 WHERE vm_id = ?", vm.Id)
+	db.Exec("DELETE FROM network_vms WHERE vm_id = ?", vm.Id)
+	db.Exec("DELETE FROM vm_metrics WHERE vm_id = ?", vm.Id)
+	db.Exec("DELETE FROM vm_drift WHERE vm_id = ?", vm.Id)
 	db.Exec("DELETE FROM vms WHERE id = ?", vm.Id)
 	MailWrap(userId, "vmDeleted", VmDeletedEmail{Id: vm.Id, Name: vm.Name}, true)
 	return nil
 }
 
+type vmDeleteJobData struct {
+	Region         string
+	Name           string
+	Identification string
+}
+
+func vmDeleteJob(job *Job) error {
+	var data vmDeleteJobData
+	err := job.Decode(&data)
+	if err != nil {
+		return err
+	}
+	vm := &VirtualMachine{
+		Id:             job.VmId,
+		UserId:         job.UserId,
+		Region:         data.Region,
+		Name:           data.Name,
+		Identification: data.Identification,
+	}
+	err = vmGetInterface(vm.Region).VmDelete(vm)
+	if err != nil {
+		return err
+	}
+	// the back-end detaches volumes along with the deleted instance
+	db.Exec("UPDATE volumes SET vm_id = 0 WHERE vm_id = ?", vm.Id)
+	return nil
+}
+
 func (vm *VirtualMachine) Suspend(auto bool) {
 	if auto {
 		db.Exec("UPDATE vms SET suspended = 'auto' WHERE id = ? AND suspended = 'no'", vm.Id)
 	} else {
 		db.Exec("UPDATE vms SET suspended = 'manual' WHERE id = ?", vm.Id)
 	}
+	jobCreate(vm.UserId, vm.Id, "vmSuspend", nil)
+}
 
-	// Try to stop the VM, and throw an error if it's not stopped after one minute
-	// We ignonre error from Stop function since it might throw error if VM already stopped
-	go func() {
-		defer errorHandler(nil, nil, true)
-		vm.Stop()
-		time.Sleep(time.Minute)
-		info, err := vmGetInterface(vm.Region).VmInfo(vm)
-		if err != nil {
-			ReportError(err, "failed to suspend VM", fmt.Sprintf("user_id: %d, vm_id: %d", vm.UserId, vm.Id))
-		} else if info.Status != "Offline" {
-			ReportError(errors.New("status not offline after one minute"), "failed to suspend VM", fmt.Sprintf("user_id: %d, vm_id: %d", vm.UserId, vm.Id))
-		}
-	}()
+// Try to stop the VM, and throw an error if it's not stopped after one minute.
+func vmSuspendJob(job *Job) error {
+	vm := vmGet(job.VmId)
+	if vm == nil || vm.Suspended == "no" || vm.Identification == "" {
+		// deleted, unsuspended, or never provisioned, so nothing to stop
+		return nil
+	}
+
+	// we call the interface directly since vm.Stop would fail due to this task being pending
+	// we ignore error from VmStop since it might throw error if VM already stopped
+	vmi := vmGetInterface(vm.Region)
+	vmi.VmStop(vm)
+	time.Sleep(time.Minute)
+	info, err := vmi.VmInfo(vm)
+	if err != nil {
+		return err
+	} else if info.Status != "Offline" {
+		return errors.New("status not offline after one minute")
+	}
+	return nil
 }
 
 func (vm *VirtualMachine) Unsuspend() error {
 	db.Exec("UPDATE vms SET suspended = 'no' WHERE id = ?", vm.Id)
 	vm.Suspended = "no"
-	return vm.Start()
+	if vm.Identification == "" || vm.Status != "active" {
+		return L.Error("vm_not_ready")
+	}
+
+	// start through the job queue so that we are ordered after any pending suspension
+	jobCreate(vm.UserId, vm.Id, "vmUnsuspend", nil)
+	return nil
+}
+
+func vmUnsuspendJob(job *Job) error {
+	vm := vmGet(job.VmId)
+	if vm == nil || vm.Suspended != "no" || vm.Identification == "" {
+		return nil
+	}
+	return vmGetInterface(vm.Region).VmStart(vm)
 }
 
 func (vm *VirtualMachine) SetMetadata(k string, v string) {
@@ -734,6 +1156,22 @@ func serviceBilling() {
 			UserApplyCharge(userId, "Image storage space", fmt.Sprintf("%d MB", storageBytes/1000/1000), "storage", totalCharge)
 		}
 
+		// bill volumes, which are sized in GB
+		volumeGB := volumeUserSize(userId)
+		if volumeGB > 0 {
+			totalCharge := int64(volumeGB) * int64(cfg.Billing.VolumeFee*BILLING_PRECISION) * int64(hours)
+			log.Printf("Charging user %d for %d GB of volumes (amount=%.5f)", userId, volumeGB, float64(totalCharge)/BILLING_PRECISION)
+			UserApplyCharge(userId, "Volume storage space", fmt.Sprintf("%d GB", volumeGB), "volumes", totalCharge)
+		}
+
+		// bill reserved IPs, which are only charged while not attached to a virtual machine
+		unattachedIps := reservedIpUserUnattached(userId)
+		if unattachedIps > 0 {
+			totalCharge := int64(unattachedIps) * int64(cfg.Billing.ReservedIpFee*BILLING_PRECISION) * int64(hours)
+			log.Printf("Charging user %d for %d unattached reserved IPs (amount=%.5f)", userId, unattachedIps, float64(totalCharge)/BILLING_PRECISION)
+			UserApplyCharge(userId, "Reserved IP addresses", fmt.Sprintf("%d unattached", unattachedIps), "reserved_ips", totalCharge)
+		}
+
 		db.Exec("UPDATE users SET time_billed = DATE_ADD(time_billed, INTERVAL ? HOUR) WHERE id = ?", hours, userId)
 	}
 }
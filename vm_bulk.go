@@ -0,0 +1,107 @@
+package lobster
+
+import "log"
+import "strconv"
+
+// Bulk operations apply one action to several of a user's virtual machines.
+// Each virtual machine goes through the same methods as the single-VM handlers, so failures are per-VM.
+
+type VmBulkResult struct {
+	VmId  int
+	Name  string // empty if the virtual machine was not found
+	Error error
+}
+
+func vmBulkActionOk(action string) bool {
+	return action == "start" || action == "stop" || action == "reboot" || action == "delete" || action == "tag" || action == "resize"
+}
+
+// Adds the tags to the virtual machine's existing tags.
+func (vm *VirtualMachine) addTags(tags []string) error {
+	vm.LoadTags()
+	merged := append([]string{}, vm.Tags...)
+	seen := make(map[string]bool)
+	for _, tag := range vm.Tags {
+		seen[tag] = true
+	}
+	for _, tag := range tags {
+		if !seen[tag] {
+			seen[tag] = true
+			merged = append(merged, tag)
+		}
+	}
+	return vm.SetTags(merged)
+}
+
+// Performs the action on each of the virtual machines, checking ownership of each one.
+// For tag, value is a comma-separated list of tags to add; for resize, value is the plan ID.
+// An error is returned only if the request itself is invalid; otherwise there is one result per distinct ID.
+func vmBulkAction(userId int, vmIds []int, action string, value string) ([]*VmBulkResult, error) {
+	if !vmBulkActionOk(action) {
+		return nil, L.Error("invalid_bulk_action")
+	} else if len(vmIds) == 0 {
+		return nil, L.Error("no_vms_selected")
+	} else if len(vmIds) > MAX_BULK_VMS {
+		return nil, L.Errorf("too_many_bulk_vms", MAX_BULK_VMS)
+	}
+
+	var tags []string
+	var planId int
+	if action == "tag" {
+		tags = tagsParse(value)
+		if len(tags) == 0 {
+			return nil, L.Errorf("invalid_tag_length", MAX_VM_TAG_LENGTH)
+		}
+		for _, tag := range tags {
+			if err := tagOk(tag); err != nil {
+				return nil, err
+			}
+		}
+	} else if action == "resize" {
+		var err error
+		planId, err = strconv.Atoi(value)
+		if err != nil {
+			return nil, L.Error("no_such_plan")
+		}
+	}
+
+	log.Printf("vmBulkAction(%d, %v, %s, %s)", userId, vmIds, action, value)
+	results := make([]*VmBulkResult, 0)
+	seen := make(map[int]bool)
+	for _, vmId := range vmIds {
+		if seen[vmId] {
+			continue
+		}
+		seen[vmId] = true
+		result := &VmBulkResult{VmId: vmId}
+		results = append(results, result)
+
+		vm := vmGetUser(userId, vmId)
+		if vm == nil {
+			result.Error = L.Error("vm_not_found")
+			continue
+		}
+		result.Name = vm.Name
+
+		// there is no name to type in bulk, so virtual machines that require it must be deleted or resized individually
+		if (action == "delete" || action == "resize") && vm.ConfirmName() {
+			result.Error = L.Error("vm_bulk_confirm_name")
+			continue
+		}
+
+		if action == "start" {
+			result.Error = vm.Start()
+		} else if action == "stop" {
+			result.Error = vm.Stop()
+		} else if action == "reboot" {
+			result.Error = vm.Reboot()
+		} else if action == "delete" {
+			result.Error = vm.Delete(userId)
+		} else if action == "tag" {
+			result.Error = vm.addTags(tags)
+		} else if action == "resize" {
+			result.Error = vm.Resize(planId)
+		}
+	}
+	return results, nil
+}
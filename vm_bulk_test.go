@@ -0,0 +1,66 @@
+package lobster
+
+import "testing"
+
+type testBulkVmi struct {
+	TestVmi
+	reboots int
+}
+
+func (this *testBulkVmi) VmReboot(vm *VirtualMachine) error {
+	this.reboots++
+	return nil
+}
+
+func TestVmBulkAction(t *testing.T) {
+	TestReset()
+	vmi := &testBulkVmi{}
+	defer TestRegion("testbulk", vmi)()
+	userId := TestUser()
+	otherUserId := TestUser()
+	vmId := TestVm(userId)
+	otherVmId := TestVm(otherUserId)
+	db.Exec("UPDATE vms SET region = 'testbulk', identification = 'test' WHERE id IN (?, ?)", vmId, otherVmId)
+
+	if _, err := vmBulkAction(userId, []int{vmId}, "rename", ""); err == nil {
+		t.Fatal("Bulk action accepted invalid action")
+	}
+	if _, err := vmBulkAction(userId, nil, "reboot", ""); err == nil {
+		t.Fatal("Bulk action accepted empty selection")
+	}
+
+	// virtual machines of other users fail without affecting the rest, and duplicates are ignored
+	results, err := vmBulkAction(userId, []int{vmId, otherVmId, vmId}, "reboot", "")
+	if err != nil {
+		t.Fatalf("Bulk reboot failed: %v", err)
+	} else if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	} else if results[0].Error != nil || results[1].Error == nil {
+		t.Fatalf("Expected only VM of the user to succeed, got %v, %v", results[0].Error, results[1].Error)
+	} else if vmi.reboots != 1 {
+		t.Fatalf("Expected 1 reboot, got %d", vmi.reboots)
+	}
+
+	// tags are added to existing tags
+	vmGet(vmId).SetTags([]string{"a"})
+	results, err = vmBulkAction(userId, []int{vmId}, "tag", "b, a")
+	if err != nil || results[0].Error != nil {
+		t.Fatalf("Bulk tag failed: %v, %v", err, results[0].Error)
+	}
+	vm := vmGet(vmId)
+	vm.LoadTags()
+	if len(vm.Tags) != 2 {
+		t.Fatalf("Expected 2 tags after bulk tag, got %v", vm.Tags)
+	}
+
+	// virtual machines that require typing the name are not deleted in bulk
+	vm.SetConfirmName(true)
+	results, err = vmBulkAction(userId, []int{vmId}, "delete", "")
+	if err != nil {
+		t.Fatalf("Bulk delete failed: %v", err)
+	} else if results[0].Error == nil {
+		t.Fatal("Bulk delete succeeded on VM requiring name confirmation")
+	} else if vmGet(vmId) == nil {
+		t.Fatal("VM requiring name confirmation deleted in bulk")
+	}
+}
@@ -0,0 +1,176 @@
+package lobster
+
+import "fmt"
+import "log"
+import "time"
+
+// Cloning is a vmClone job on the source virtual machine, which proceeds through these steps:
+//  snapshot: snapshot the source virtual machine
+//  create: once the snapshot is active, create the new virtual machine from it
+//  cleanup: if the snapshot should be deleted, wait for the new virtual machine to be provisioned and delete it
+// Unlike migration, the snapshot is kept by default so that it can be used to create further copies.
+
+type vmCloneJobData struct {
+	Name        string
+	PlanId      int
+	DeleteImage bool
+
+	Step     string
+	StepTime time.Time // when the current step started, to time out waits
+	ImageId  int
+	NewVmId  int
+}
+
+// Starts cloning the virtual machine into a new virtual machine in the same region.
+// If planId is zero, the clone uses the same plan as the source.
+// If deleteImage is set, the intermediate snapshot is deleted once the clone is provisioned.
+// Returns the ID of the clone job.
+func (vm *VirtualMachine) Clone(name string, planId int, deleteImage bool) (int, error) {
+	if planId == 0 {
+		planId = vm.Plan.Id
+	}
+
+	err := vmNameOk(name)
+	if err != nil {
+		return 0, err
+	} else if _, ok := vmGetInterface(vm.Region).(VMISnapshot); !ok {
+		return 0, L.Error("vm_snapshot_unsupported")
+	} else if !regionEnabled(vm.Region) {
+		return 0, L.Error("region_disabled")
+	}
+	plan := planGetRegion(vm.Region, planId)
+	if plan == nil {
+		return 0, L.Error("no_such_plan")
+	}
+
+	user := UserDetails(vm.UserId)
+	var vmCount int
+	db.QueryRow("SELECT COUNT(*) FROM vms WHERE user_id = ?", vm.UserId).Scan(&vmCount)
+	if user == nil {
+		return 0, L.Error("invalid_account")
+	} else if user.Credit < MINIMUM_CREDIT {
+		return 0, L.Error("insufficient_credit")
+	} else if vmCount >= user.VmLimit {
+		return 0, L.Error("exceeded_vm_limit")
+	}
+	err = userQuotaCheck(vm.UserId, planQuotaRequest(plan))
+	if err != nil {
+		return 0, err
+	}
+	err = regionCapacityCheck(vm.Region, plan, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	log.Printf("vmClone(%d, %s, %d, %t)", vm.Id, name, planId, deleteImage)
+	var jobId int
+	err = vm.do(func(vm *VirtualMachine) error {
+		jobId = jobCreate(vm.UserId, vm.Id, "vmClone", vmCloneJobData{
+			Name:        name,
+			PlanId:      planId,
+			DeleteImage: deleteImage,
+			Step:        "snapshot",
+		})
+		return nil
+	})
+	return jobId, err
+}
+
+func vmCloneJob(job *Job) error {
+	var data vmCloneJobData
+	err := job.Decode(&data)
+	if err != nil {
+		return err
+	}
+
+	for data.Step != "done" {
+		job.SetProgress(data.Step)
+		step := data.Step
+		err := vmCloneStep(job, &data)
+		if err != nil {
+			return err
+		}
+		if data.Step != step {
+			data.StepTime = time.Now()
+		}
+		job.Save(data)
+	}
+	return nil
+}
+
+// Returns errJobWait, or a timeout error if the step started more than CLONE_WAIT_TIMEOUT ago.
+func vmCloneWait(data *vmCloneJobData) error {
+	if time.Since(data.StepTime) > CLONE_WAIT_TIMEOUT*time.Hour {
+		return L.Errorf("clone_timeout", data.Step)
+	}
+	return errJobWait
+}
+
+// Performs the current step of the clone, and advances data.Step if it completes.
+func vmCloneStep(job *Job, data *vmCloneJobData) error {
+	if data.Step == "snapshot" {
+		vm := vmGet(job.VmId)
+		if vm == nil {
+			return L.Error("invalid_vm")
+		}
+		// the pending task is this clone
+		vm.TaskPending = false
+		imageId, err := vm.Snapshot(data.Name)
+		if err != nil {
+			return err
+		}
+		data.ImageId = imageId
+		data.Step = "create"
+	} else if data.Step == "create" {
+		// pending images are polled by cached(), which updates their status
+		image := imageGetForce(data.ImageId)
+		if image == nil || image.Status == "error" {
+			return L.Error("clone_snapshot_failed")
+		} else if image.Status != "active" {
+			return vmCloneWait(data)
+		}
+		vmId, err := vmCreate(job.UserId, data.Name, data.PlanId, data.ImageId, VmCreateOptions{})
+		if err != nil {
+			return err
+		}
+		data.NewVmId = vmId
+		if data.DeleteImage {
+			data.Step = "cleanup"
+		} else {
+			data.Step = "done"
+		}
+	} else if data.Step == "cleanup" {
+		// the image is needed until the new virtual machine has been provisioned from it
+		newVm := vmGet(data.NewVmId)
+		if newVm != nil && newVm.Status != "active" && newVm.Status != "error" {
+			return vmCloneWait(data)
+		}
+		if imageGetForce(data.ImageId) != nil {
+			imageDeleteForce(data.ImageId)
+		}
+		data.Step = "done"
+	} else {
+		return fmt.Errorf("unknown clone step %s", data.Step)
+	}
+	return nil
+}
+
+// Removes the intermediate snapshot of a failed clone, unless the user wanted to keep it and it is usable.
+// A virtual machine that was already created is left to the vmCreate job, which handles its own failure.
+func vmCloneJobFail(job *Job, err error) {
+	var data vmCloneJobData
+	if job.Decode(&data) != nil {
+		return
+	}
+	if data.ImageId != 0 {
+		image := imageGetForce(data.ImageId)
+		if image != nil && (data.DeleteImage || image.Status != "active") {
+			imageDeleteForce(data.ImageId)
+		}
+	}
+
+	vm := vmGet(job.VmId)
+	if vm != nil {
+		MailWrap(vm.UserId, "vmCloneError", VmCloneErrorEmail{Id: vm.Id, Name: vm.Name, CloneName: data.Name}, false)
+	}
+}
@@ -0,0 +1,238 @@
+package lobster
+
+import "fmt"
+import "log"
+import "net"
+import "net/http"
+import "net/url"
+import "strconv"
+import "strings"
+import "sync"
+import "time"
+
+// database objects
+
+// Probes a port on the virtual machine's external IP address every minute.
+// The check goes down after Threshold consecutive failures, and up again after a single success.
+type HealthCheck struct {
+	Id          int
+	VmId        int
+	Protocol    string // "tcp" or "http"
+	Port        int
+	Path        string // request path for HTTP checks
+	Threshold   int
+	AutoReboot  bool
+	Status      string // "unknown", "up", or "down"
+	Failures    int    // consecutive failed probes
+	Message     string // error from the last failed probe
+	CheckedTime time.Time
+}
+
+func healthCheckListHelper(rows Rows) []*HealthCheck {
+	defer rows.Close()
+	checks := make([]*HealthCheck, 0)
+	for rows.Next() {
+		check := HealthCheck{}
+		rows.Scan(&check.Id, &check.VmId, &check.Protocol, &check.Port, &check.Path, &check.Threshold, &check.AutoReboot, &check.Status, &check.Failures, &check.Message, &check.CheckedTime)
+		checks = append(checks, &check)
+	}
+	return checks
+}
+
+const HEALTH_CHECK_QUERY = "SELECT id, vm_id, protocol, port, path, threshold, auto_reboot, status, failures, message, time_checked FROM health_checks"
+
+func healthCheckList(vmId int) []*HealthCheck {
+	return healthCheckListHelper(db.Query(HEALTH_CHECK_QUERY+" WHERE vm_id = ? ORDER BY id", vmId))
+}
+
+func healthCheckListAll() []*HealthCheck {
+	return healthCheckListHelper(db.Query(HEALTH_CHECK_QUERY + " ORDER BY vm_id, id"))
+}
+
+// Returns a description of the probe target, e.g. "tcp:22" or "http:80/status".
+func (check *HealthCheck) Target() string {
+	return fmt.Sprintf("%s:%d%s", check.Protocol, check.Port, check.Path)
+}
+
+// Updates the check with the result of a probe, and returns whether the status changed.
+func (check *HealthCheck) record(err error) bool {
+	previous := check.Status
+	if err == nil {
+		check.Status = "up"
+		check.Failures = 0
+		check.Message = ""
+	} else {
+		check.Failures++
+		check.Message = err.Error()
+		if len(check.Message) > 255 {
+			check.Message = check.Message[:255]
+		}
+		if check.Failures >= check.Threshold {
+			check.Status = "down"
+		}
+	}
+	return check.Status != previous
+}
+
+// Connects to the port on the IP address, and for HTTP checks requests the path.
+// HTTP checks fail on status codes of 400 and above; redirects are not followed.
+func healthProbe(protocol string, ip string, port int, path string) error {
+	address := net.JoinHostPort(ip, strconv.Itoa(port))
+	if protocol == "tcp" {
+		conn, err := net.DialTimeout("tcp", address, HEALTH_CHECK_TIMEOUT*time.Second)
+		if err != nil {
+			return err
+		}
+		conn.Close()
+		return nil
+	} else if protocol == "http" {
+		client := &http.Client{
+			Timeout: HEALTH_CHECK_TIMEOUT * time.Second,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+		response, err := client.Get("http://" + address + path)
+		if err != nil {
+			return err
+		}
+		response.Body.Close()
+		if response.StatusCode >= 400 {
+			return fmt.Errorf("HTTP status %d", response.StatusCode)
+		}
+		return nil
+	} else {
+		return fmt.Errorf("unknown protocol %s", protocol)
+	}
+}
+
+func healthPathOk(path string) bool {
+	if !strings.HasPrefix(path, "/") || len(path) > MAX_HEALTH_CHECK_PATH {
+		return false
+	}
+	for _, c := range path {
+		if c <= ' ' || c == 0x7f {
+			return false
+		}
+	}
+	_, err := url.ParseRequestURI(path)
+	return err == nil
+}
+
+func (vm *VirtualMachine) AddHealthCheck(protocol string, port int, path string, threshold int, autoReboot bool) (int, error) {
+	if protocol != "tcp" && protocol != "http" {
+		return 0, L.Error("invalid_health_protocol")
+	} else if port < 1 || port > 65535 {
+		return 0, L.Error("invalid_health_port")
+	} else if threshold < 1 || threshold > MAX_HEALTH_CHECK_THRESHOLD {
+		return 0, L.Errorf("invalid_health_threshold", MAX_HEALTH_CHECK_THRESHOLD)
+	}
+
+	if protocol == "tcp" {
+		path = ""
+	} else if path == "" {
+		path = "/"
+	} else if !healthPathOk(path) {
+		return 0, L.Error("invalid_health_path")
+	}
+
+	if len(healthCheckList(vm.Id)) >= MAX_HEALTH_CHECKS {
+		return 0, L.Errorf("health_check_limit", MAX_HEALTH_CHECKS)
+	}
+
+	log.Printf("vmAddHealthCheck(%d, %s, %d, %s, %d, %v)", vm.Id, protocol, port, path, threshold, autoReboot)
+	result := db.Exec(
+		"INSERT INTO health_checks (vm_id, protocol, port, path, threshold, auto_reboot) VALUES (?, ?, ?, ?, ?, ?)",
+		vm.Id, protocol, port, path, threshold, autoReboot,
+	)
+	return result.LastInsertId(), nil
+}
+
+func (vm *VirtualMachine) RemoveHealthCheck(checkId int) error {
+	result := db.Exec("DELETE FROM health_checks WHERE id = ? AND vm_id = ?", checkId, vm.Id)
+	if result.RowsAffected() != 1 {
+		return L.Error("invalid_health_check")
+	}
+	return nil
+}
+
+// Returns whether the virtual machine should be probed by the health checks.
+// The external IP is "unknown" until the back-end reports an address.
+func healthCheckProbeable(vm *VirtualMachine) bool {
+	if vm == nil || vm.Status != "active" || vm.Suspended != "no" || vm.TaskPending {
+		return false
+	}
+	return net.ParseIP(vm.ExternalIP) != nil
+}
+
+// Probes all health checks concurrently, then records the results.
+// Notifications are sent when a check goes down, or comes back up after being down.
+// Virtual machines that are suspended, have no known external IP, or have a pending task are skipped.
+func healthCheckCron() {
+	checks := healthCheckListAll()
+	vms := make(map[int]*VirtualMachine)
+	probed := make([]bool, len(checks))
+	results := make([]error, len(checks))
+	var wg sync.WaitGroup
+
+	for i, check := range checks {
+		vm, ok := vms[check.VmId]
+		if !ok {
+			vm = vmGet(check.VmId)
+			vms[check.VmId] = vm
+		}
+		if !healthCheckProbeable(vm) {
+			continue
+		}
+
+		probed[i] = true
+		wg.Add(1)
+		go func(i int, check *HealthCheck, ip string) {
+			defer wg.Done()
+			results[i] = healthProbe(check.Protocol, ip, check.Port, check.Path)
+		}(i, check, vm.ExternalIP)
+	}
+	wg.Wait()
+
+	rebooted := make(map[int]bool)
+	for i, check := range checks {
+		if !probed[i] {
+			continue
+		}
+		vm := vms[check.VmId]
+		previous := check.Status
+		changed := check.record(results[i])
+		db.Exec(
+			"UPDATE health_checks SET status = ?, failures = ?, message = ?, time_checked = NOW() WHERE id = ?",
+			check.Status, check.Failures, check.Message, check.Id,
+		)
+		if !changed {
+			continue
+		}
+
+		log.Printf("health check %d on vm %d is now %s", check.Id, vm.Id, check.Status)
+		emailParams := VmHealthEmail{
+			Id:      vm.Id,
+			Name:    vm.Name,
+			Target:  check.Target(),
+			Message: check.Message,
+		}
+		if check.Status == "down" {
+			// reboot at most once per run, even if several checks on the virtual machine went down
+			if check.AutoReboot && !rebooted[vm.Id] {
+				rebooted[vm.Id] = true
+				err := vm.Reboot()
+				result := "success"
+				if err != nil {
+					result = err.Error()
+				} else {
+					emailParams.Rebooted = true
+				}
+				LogAction(vm.UserId, "", "Reboot VM (health check)", fmt.Sprintf("VM ID: %d; Check: %d; Result: %s", vm.Id, check.Id, result))
+			}
+			MailWrap(vm.UserId, "vmHealthDown", emailParams, false)
+		} else if previous == "down" {
+			MailWrap(vm.UserId, "vmHealthUp", emailParams, false)
+		}
+	}
+}
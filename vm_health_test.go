@@ -0,0 +1,98 @@
+package lobster
+
+import "errors"
+import "net"
+import "net/http"
+import "testing"
+
+func TestHealthProbe(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/error", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "error", 500)
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/error", 302)
+	})
+	go http.Serve(ln, mux)
+
+	if err := healthProbe("tcp", "127.0.0.1", port, ""); err != nil {
+		t.Fatalf("TCP probe failed: %v", err)
+	}
+	if err := healthProbe("http", "127.0.0.1", port, "/ok"); err != nil {
+		t.Fatalf("HTTP probe failed: %v", err)
+	}
+	if err := healthProbe("http", "127.0.0.1", port, "/redirect"); err != nil {
+		t.Fatalf("HTTP probe followed redirect: %v", err)
+	}
+	if healthProbe("http", "127.0.0.1", port, "/error") == nil {
+		t.Fatal("HTTP probe succeeded on error status")
+	}
+	if healthProbe("http", "127.0.0.1", port, "/missing") == nil {
+		t.Fatal("HTTP probe succeeded on missing path")
+	}
+
+	ln.Close()
+	if healthProbe("tcp", "127.0.0.1", port, "") == nil {
+		t.Fatal("TCP probe succeeded after listener closed")
+	}
+}
+
+func TestHealthCheckRecord(t *testing.T) {
+	check := &HealthCheck{Threshold: 2, Status: "unknown"}
+	if !check.record(nil) || check.Status != "up" {
+		t.Fatalf("Expected check to be up, got %s", check.Status)
+	}
+	if check.record(errors.New("refused")) || check.Status != "up" {
+		t.Fatal("Check went down before reaching threshold")
+	}
+	if !check.record(errors.New("refused")) || check.Status != "down" || check.Message != "refused" {
+		t.Fatalf("Expected check to be down, got %s", check.Status)
+	}
+	if check.record(errors.New("refused")) {
+		t.Fatal("Status changed on repeated failure")
+	}
+	if !check.record(nil) || check.Status != "up" || check.Failures != 0 || check.Message != "" {
+		t.Fatal("Check did not recover after success")
+	}
+}
+
+func TestHealthPathOk(t *testing.T) {
+	for _, path := range []string{"/", "/status", "/health?full=1"} {
+		if !healthPathOk(path) {
+			t.Fatalf("Expected path %s to be accepted", path)
+		}
+	}
+	for _, path := range []string{"", "status", "/a b", "/a\r\nHost: x"} {
+		if healthPathOk(path) {
+			t.Fatalf("Expected path %q to be rejected", path)
+		}
+	}
+}
+
+func TestHealthCheckProbeable(t *testing.T) {
+	if !healthCheckProbeable(&VirtualMachine{Status: "active", Suspended: "no", ExternalIP: "192.0.2.1"}) {
+		t.Fatal("Expected active virtual machine to be probed")
+	}
+	skipped := []*VirtualMachine{
+		nil,
+		{Status: "provisioning", Suspended: "no", ExternalIP: "192.0.2.1"},
+		{Status: "active", Suspended: "auto", ExternalIP: "192.0.2.1"},
+		{Status: "active", Suspended: "manual", ExternalIP: "192.0.2.1"},
+		{Status: "active", Suspended: "no", ExternalIP: "192.0.2.1", TaskPending: true},
+		{Status: "active", Suspended: "no", ExternalIP: ""},
+		{Status: "active", Suspended: "no", ExternalIP: "unknown"},
+	}
+	for i, vm := range skipped {
+		if healthCheckProbeable(vm) {
+			t.Fatalf("Expected virtual machine %d to be skipped", i)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package lobster
 
+import "time"
+
 type VmInterface interface {
 	// Creates a virtual machine with the given name and plan (specified in vm object), and image.
 	// Returns vmIdentification string and optional error.
@@ -25,7 +27,31 @@ type VmInterface interface {
 
 type VMIVmCreateOptions struct {
 	ImageIdentification string
-	SSHKey              SSHKey
+
+	// Public keys to authorize for login, may be empty.
+	// If the interface implements VMIKeyLimit, there are at most KeyLimit keys.
+	SSHKeys []SSHKey
+
+	// Cloud-init user-data, or empty if none was provided.
+	// This is only set if the interface implements VMIUserData and CanUserData returns true.
+	UserData string
+}
+
+// Indicates that VmCreate passes VMIVmCreateOptions.UserData on to the virtual machine.
+// CanUserData may return false if support depends on the backend configuration.
+type VMIUserData interface {
+	CanUserData() bool
+}
+
+// Indicates that VmCreate still authorizes VMIVmCreateOptions.SSHKeys when user-data is provided.
+// Back-ends that generate their own cloud-config and replace it with the user-data should not implement this.
+type VMIUserDataKeys interface {
+	CanUserDataKeys() bool
+}
+
+// Indicates that VmCreate can only authorize up to KeyLimit public keys.
+type VMIKeyLimit interface {
+	KeyLimit() int
 }
 
 type VMIVnc interface {
@@ -37,8 +63,30 @@ type VMIRename interface {
 	VmRename(vm *VirtualMachine, name string) error
 }
 
+type VMIReimageOptions struct {
+	ImageIdentification string
+
+	// Public keys to authorize for login, may be empty.
+	// This is only set if the interface implements VMIReimageKeys and CanReimageKeys returns true.
+	SSHKeys []SSHKey
+
+	// Cloud-init user-data, or empty if none was provided.
+	// This is only set if the interface implements VMIReimageUserData and CanReimageUserData returns true.
+	UserData string
+}
+
 type VMIReimage interface {
-	VmReimage(vm *VirtualMachine, imageIdentification string) error
+	VmReimage(vm *VirtualMachine, options *VMIReimageOptions) error
+}
+
+// Like VMIUserData, but indicates that VmReimage passes VMIReimageOptions.UserData on to the virtual machine.
+type VMIReimageUserData interface {
+	CanReimageUserData() bool
+}
+
+// Indicates that VmReimage authorizes VMIReimageOptions.SSHKeys on the re-imaged virtual machine.
+type VMIReimageKeys interface {
+	CanReimageKeys() bool
 }
 
 type VMISnapshot interface {
@@ -58,6 +106,108 @@ type VMIAddresses interface {
 	VmSetRdns(vm *VirtualMachine, ip string, hostname string) error
 }
 
+// Manages inbound firewall rules of individual virtual machines.
+// Rules allow traffic to the virtual machine; how unmatched traffic is treated depends on the backend.
+type VMIFirewall interface {
+	// Rules returned should have Id set, which is later passed to VmFirewallRemove.
+	VmFirewallRules(vm *VirtualMachine) ([]*FirewallRule, error)
+
+	// The rule has been validated by lobster; Id is not set.
+	VmFirewallAdd(vm *VirtualMachine, rule *FirewallRule) error
+	VmFirewallRemove(vm *VirtualMachine, ruleId string) error
+}
+
+// Manages block storage volumes that can be attached to virtual machines.
+type VMIVolumes interface {
+	// Creates a volume with the name and size (in GB) specified in the volume object.
+	// Returns volume identification; the volume should be reported as pending by VolumeInfo until it can be attached.
+	VolumeCreate(volume *Volume) (string, error)
+
+	VolumeDelete(volume *Volume) error
+	VolumeInfo(volume *Volume) (*VolumeInfo, error)
+
+	// Grows a detached volume to the given size in GB.
+	VolumeResize(volume *Volume, size int) error
+
+	VolumeAttach(vm *VirtualMachine, volume *Volume) error
+	VolumeDetach(vm *VirtualMachine, volume *Volume) error
+}
+
+// Manages floating IP addresses, which lobster exposes to users as reserved IPs.
+type VMIFloatingIPs interface {
+	// Allocates a floating IP in the region, returning its identification and address.
+	FloatingIPAllocate() (string, string, error)
+	FloatingIPRelease(identification string) error
+
+	// Associates the floating IP with the virtual machine.
+	// This is also called after re-imaging for addresses that are already attached, and should succeed
+	//  if the address is still associated.
+	FloatingIPAttach(vm *VirtualMachine, identification string) error
+	FloatingIPDetach(vm *VirtualMachine, identification string) error
+}
+
+// Optionally implemented by VMIFloatingIPs interfaces whose support depends on the backend configuration.
+type VMIFloatingIPsEnabled interface {
+	CanFloatingIPs() bool
+}
+
+// Manages private networks between a user's virtual machines.
+type VMINetworks interface {
+	// Creates an isolated network with the name and IPv4 subnet specified in the network object.
+	// Returns network identification.
+	NetworkCreate(network *Network) (string, error)
+
+	// Deletes a network; lobster only deletes networks with no attached virtual machines.
+	NetworkDelete(network *Network) error
+
+	// Adds an interface on the network to the virtual machine, with an address assigned from the subnet.
+	NetworkAttach(vm *VirtualMachine, network *Network) error
+	NetworkDetach(vm *VirtualMachine, network *Network) error
+}
+
+// Boots virtual machines into a rescue system or from an ISO image, so that users can repair them.
+// Back-ends that instead list rescue operations in VmInfo.Actions are adapted by lobster (see vm_rescue.go).
+type VMIRescue interface {
+	// Returns the password of the rescue system, or an empty string if the back-end does not set one.
+	VmRescue(vm *VirtualMachine) (string, error)
+	VmUnrescue(vm *VirtualMachine) error
+
+	// Boots the virtual machine from the image, which is usually an ISO added through ImageFetch.
+	VmMountISO(vm *VirtualMachine, imageIdentification string) error
+	VmUnmountISO(vm *VirtualMachine) error
+}
+
+type VMIPasswordReset interface {
+	// Sets a new random root password on the virtual machine and returns it.
+	// The password is shown to the user once and is not stored by lobster.
+	VmResetPassword(vm *VirtualMachine) (string, error)
+}
+
+type VMIConsoleOutput interface {
+	// Returns the most recent serial console output of the virtual machine.
+	// The output should be at most length bytes; lobster truncates it otherwise.
+	VmConsoleOutput(vm *VirtualMachine, length int) (string, error)
+}
+
+type VMIMetrics interface {
+	// Returns resource usage samples of the virtual machine since the specified time.
+	// Samples may be at any interval; lobster averages them into METRICS_RESOLUTION_FINE buckets.
+	VmMetrics(vm *VirtualMachine, since time.Time) ([]*MetricSample, error)
+}
+
+// Required in the source region of migrations; see vm_migrate.go.
+type VMIImageExport interface {
+	// Returns a URL from which the image can be downloaded by other regions through ImageFetch.
+	// The URL should remain valid for at least a few hours.
+	ImageExport(imageIdentification string) (string, error)
+}
+
+type VMIList interface {
+	// Returns all instances on the backend, including those that lobster did not create.
+	// Only Identification, Name, and ExternalIP need to be set on the returned objects.
+	VmList() ([]*VirtualMachine, error)
+}
+
 type VMIImages interface {
 	// Download an image from an external URL.
 	// Format is currently either 'template' or 'iso' in the form, although user may provide arbitrary format string.
@@ -75,6 +225,20 @@ type VMIPlans interface {
 	PlanList() ([]*Plan, error)
 }
 
+// Free resources in a region, as reported by VMICapacity.
+// Fields that the backend cannot report should be set to -1.
+type VMICapacityInfo struct {
+	Ram     int // in MB
+	Cpu     int
+	Storage int // in GB
+}
+
+// Reports free resources on the backend, so that lobster stops offering plans that no longer fit.
+// The result is cached for CAPACITY_CACHE_TIME seconds; if it fails, plans are assumed to fit.
+type VMICapacity interface {
+	Capacity() (*VMICapacityInfo, error)
+}
+
 // Note: before calling the VmInterface, we will make sure that the user actually owns the virtual machine
 // with the given identification, and same for images. However, VmInterface is responsible for checking any
 // other input, e.g. action strings, action parameters, image formats, image URLs.
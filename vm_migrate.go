@@ -0,0 +1,267 @@
+package lobster
+
+import "fmt"
+import "log"
+import "time"
+
+// Cross-region migration is a vmMigrate job on the source virtual machine, which proceeds through these steps:
+//  snapshot: snapshot the source virtual machine
+//  export: once the snapshot is active, fetch it into the destination region from the export URL
+//  create: once the fetched image is active, create the new virtual machine from it
+//  wait: wait for the new virtual machine to be provisioned
+//  cleanup: delete the intermediate images, and the source virtual machine if requested
+// The step is saved in the job data after it completes, so that retries resume from the failed step.
+// If the job fails, vmMigrateJobFail rolls back by deleting whatever was created; the source is left untouched.
+//
+// The source region must implement VMIImageExport. Only the fake interface does so far, so migration is
+// not offered on the other back-ends until they can export images to a URL that other regions can fetch.
+
+type vmMigrateJobData struct {
+	Region       string // destination region
+	PlanId       int
+	Name         string
+	DeleteSource bool
+
+	Step          string
+	StepTime      time.Time // when the current step started, to time out waits
+	SourceImageId int
+	DestImageId   int
+	NewVmId       int
+}
+
+// Destination region of a migration, with the plans available there.
+type MigrateRegion struct {
+	Region string
+	Plans  []*Plan
+}
+
+// Returns whether virtual machines can be migrated out of the region.
+func regionCanMigrate(region string) bool {
+	vmi := regionInterface(region)
+	_, canSnapshot := vmi.(VMISnapshot)
+	_, canExport := vmi.(VMIImageExport)
+	_, canImages := vmi.(VMIImages)
+	return canSnapshot && canExport && canImages
+}
+
+// Returns the enabled regions that virtual machines in the source region can be migrated to.
+func migrateRegionList(source string) []*MigrateRegion {
+	regions := make([]*MigrateRegion, 0)
+	if !regionCanMigrate(source) {
+		return regions
+	}
+	for _, region := range regionList() {
+		if region == source {
+			continue
+		} else if _, ok := regionInterface(region).(VMIImages); !ok {
+			continue
+		}
+		regions = append(regions, &MigrateRegion{
+			Region: region,
+			Plans:  planListRegion(region),
+		})
+	}
+	return regions
+}
+
+// Starts migrating the virtual machine to another region with the plan, or the same plan if planId is zero.
+// Returns the ID of the migration job.
+func (vm *VirtualMachine) Migrate(region string, planId int, deleteSource bool) (int, error) {
+	if planId == 0 {
+		planId = vm.Plan.Id
+	}
+
+	if region == vm.Region {
+		return 0, L.Error("migrate_same_region")
+	} else if !regionCanMigrate(vm.Region) {
+		return 0, L.Error("migrate_unsupported")
+	} else if regionInterface(region) == nil || !regionEnabled(region) {
+		return 0, L.Error("invalid_region")
+	} else if _, ok := regionInterface(region).(VMIImages); !ok {
+		return 0, L.Error("migrate_unsupported")
+	} else if planGetRegion(region, planId) == nil {
+		return 0, L.Error("no_such_plan")
+	}
+	if deleteSource {
+		// the source would be deleted once the migration completes
+		if err := vm.checkProtected(); err != nil {
+			return 0, err
+		}
+	}
+
+	// the new virtual machine exists alongside the source until the migration completes
+	user := UserDetails(vm.UserId)
+	var vmCount int
+	db.QueryRow("SELECT COUNT(*) FROM vms WHERE user_id = ?", vm.UserId).Scan(&vmCount)
+	if user == nil {
+		return 0, L.Error("invalid_account")
+	} else if user.Credit < MINIMUM_CREDIT {
+		return 0, L.Error("insufficient_credit")
+	} else if vmCount >= user.VmLimit {
+		return 0, L.Error("exceeded_vm_limit")
+	} else if err := userQuotaCheck(vm.UserId, planQuotaRequest(planGetRegion(region, planId))); err != nil {
+		return 0, err
+	} else if err := regionCapacityCheck(region, planGetRegion(region, planId), nil); err != nil {
+		return 0, err
+	}
+
+	log.Printf("vmMigrate(%d, %s, %d, %t)", vm.Id, region, planId, deleteSource)
+	var jobId int
+	err := vm.do(func(vm *VirtualMachine) error {
+		jobId = jobCreate(vm.UserId, vm.Id, "vmMigrate", vmMigrateJobData{
+			Region:       region,
+			PlanId:       planId,
+			Name:         vm.Name,
+			DeleteSource: deleteSource,
+			Step:         "snapshot",
+		})
+		return nil
+	})
+	return jobId, err
+}
+
+func vmMigrateJob(job *Job) error {
+	var data vmMigrateJobData
+	err := job.Decode(&data)
+	if err != nil {
+		return err
+	}
+
+	for data.Step != "done" {
+		job.SetProgress(data.Step)
+		step := data.Step
+		err := vmMigrateStep(job, &data)
+		if err != nil {
+			return err
+		}
+		if data.Step != step {
+			data.StepTime = time.Now()
+		}
+		job.Save(data)
+	}
+	return nil
+}
+
+// Returns errJobWait if the step started more than MIGRATE_WAIT_TIMEOUT ago, or a timeout error otherwise.
+func vmMigrateWait(data *vmMigrateJobData) error {
+	if time.Since(data.StepTime) > MIGRATE_WAIT_TIMEOUT*time.Hour {
+		return L.Errorf("migrate_timeout", data.Step)
+	}
+	return errJobWait
+}
+
+// Performs the current step of the migration, and advances data.Step if it completes.
+func vmMigrateStep(job *Job, data *vmMigrateJobData) error {
+	vm := vmGet(job.VmId)
+
+	if data.Step == "snapshot" {
+		if vm == nil {
+			return L.Error("invalid_vm")
+		}
+		// the pending task is this migration
+		vm.TaskPending = false
+		imageId, err := vm.snapshot(fmt.Sprintf("migrate-%d", vm.Id))
+		if err != nil {
+			return err
+		}
+		data.SourceImageId = imageId
+		data.Step = "export"
+	} else if data.Step == "export" {
+		image := imageGetForce(data.SourceImageId)
+		if image == nil || image.Status == "error" {
+			return L.Error("migrate_snapshot_failed")
+		} else if image.Status != "active" {
+			return vmMigrateWait(data)
+		}
+		vmi, ok := vmGetInterface(image.Region).(VMIImageExport)
+		if !ok {
+			return L.Error("migrate_unsupported")
+		}
+		url, err := vmi.ImageExport(image.Identification)
+		if err != nil {
+			return err
+		}
+		imageId, err := imageFetchForce(job.UserId, data.Region, fmt.Sprintf("migrate-%d", job.VmId), url, "template")
+		if err != nil {
+			return err
+		}
+		data.DestImageId = imageId
+		data.Step = "create"
+	} else if data.Step == "create" {
+		image := imageGetForce(data.DestImageId)
+		if image == nil || image.Status == "error" {
+			return L.Error("migrate_transfer_failed")
+		} else if image.Status != "active" {
+			return vmMigrateWait(data)
+		}
+		vmId, err := vmCreate(job.UserId, data.Name, data.PlanId, data.DestImageId, VmCreateOptions{})
+		if err != nil {
+			return err
+		}
+		data.NewVmId = vmId
+		data.Step = "wait"
+	} else if data.Step == "wait" {
+		newVm := vmGet(data.NewVmId)
+		if newVm == nil || newVm.Status == "error" {
+			return L.Error("migrate_create_failed")
+		} else if newVm.Status != "active" {
+			return vmMigrateWait(data)
+		}
+		data.Step = "cleanup"
+	} else if data.Step == "cleanup" {
+		newVm := vmGet(data.NewVmId)
+		if vm != nil && newVm != nil {
+			vm.LoadTags()
+			newVm.SetTags(vm.Tags)
+			newVm.SetNotes(vm.Notes())
+		}
+		for _, imageId := range []int{data.SourceImageId, data.DestImageId} {
+			if imageGetForce(imageId) != nil {
+				imageDeleteForce(imageId)
+			}
+		}
+		// errors are only reported from here on, since the new virtual machine must not be rolled back
+		if data.DeleteSource && vm != nil {
+			err := vm.Delete(vm.UserId)
+			if err != nil {
+				ReportError(err, "failed to delete migration source", fmt.Sprintf("job_id=%d, vm_id=%d", job.Id, vm.Id))
+			}
+		}
+		data.Step = "done"
+	} else {
+		return fmt.Errorf("unknown migration step %s", data.Step)
+	}
+	return nil
+}
+
+// Rolls back a failed migration, deleting the new virtual machine and intermediate images.
+func vmMigrateJobFail(job *Job, err error) {
+	var data vmMigrateJobData
+	if job.Decode(&data) != nil || data.Step == "cleanup" || data.Step == "done" {
+		return
+	}
+	log.Printf("Rolling back migration of VM %d at step %s", job.VmId, data.Step)
+	job.SetProgress("rollback")
+
+	if data.NewVmId != 0 {
+		newVm := vmGet(data.NewVmId)
+		if newVm != nil {
+			// the new virtual machine may still be provisioning
+			deleteErr := newVm.DeleteForce(newVm.UserId)
+			if deleteErr != nil {
+				ReportError(deleteErr, "migration rollback failed to delete new VM", fmt.Sprintf("job_id=%d, vm_id=%d", job.Id, newVm.Id))
+			}
+		}
+	}
+	for _, imageId := range []int{data.SourceImageId, data.DestImageId} {
+		if imageId != 0 && imageGetForce(imageId) != nil {
+			imageDeleteForce(imageId)
+		}
+	}
+	job.SetProgress("rolled_back")
+
+	vm := vmGet(job.VmId)
+	if vm != nil {
+		MailWrap(vm.UserId, "vmMigrateError", VmMigrateErrorEmail{Id: vm.Id, Name: vm.Name, Region: data.Region}, false)
+	}
+}
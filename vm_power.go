@@ -0,0 +1,150 @@
+package lobster
+
+import "github.com/LunaNode/lobster/cronexpr"
+
+import "fmt"
+import "log"
+import "strings"
+import "time"
+
+// database objects
+
+// Runs a power action on a virtual machine at the times matching a cron expression.
+type PowerSchedule struct {
+	Id         int
+	VmId       int
+	Action     string // "start", "stop", or "reboot"
+	Expression string // five-field cron expression, evaluated in Timezone
+	Timezone   string // IANA time zone name, e.g. "America/Toronto"
+	LastTime   time.Time
+}
+
+func powerScheduleListHelper(rows Rows) []*PowerSchedule {
+	defer rows.Close()
+	schedules := make([]*PowerSchedule, 0)
+	for rows.Next() {
+		schedule := PowerSchedule{}
+		rows.Scan(&schedule.Id, &schedule.VmId, &schedule.Action, &schedule.Expression, &schedule.Timezone, &schedule.LastTime)
+		schedules = append(schedules, &schedule)
+	}
+	return schedules
+}
+
+const POWER_SCHEDULE_QUERY = "SELECT id, vm_id, action, expression, timezone, time_last FROM power_schedules"
+
+func powerScheduleList(vmId int) []*PowerSchedule {
+	return powerScheduleListHelper(db.Query(POWER_SCHEDULE_QUERY+" WHERE vm_id = ? ORDER BY id", vmId))
+}
+
+func powerScheduleListAll() []*PowerSchedule {
+	return powerScheduleListHelper(db.Query(POWER_SCHEDULE_QUERY + " ORDER BY id"))
+}
+
+// Returns the first scheduled time after t, or the zero time if the schedule is invalid or never runs.
+func (schedule *PowerSchedule) nextAfter(t time.Time) time.Time {
+	expr, err := cronexpr.Parse(schedule.Expression)
+	if err != nil {
+		return time.Time{}
+	}
+	location, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		return time.Time{}
+	}
+	return expr.Next(t.In(location))
+}
+
+// Returns the next time that the schedule will run, for display.
+func (schedule *PowerSchedule) NextTime() time.Time {
+	return schedule.nextAfter(time.Now())
+}
+
+// Returns whether the schedule has a scheduled time since it last ran that is at or before now.
+// Scheduled times more than POWER_SCHEDULE_GRACE minutes ago are skipped, so that actions missed
+// while lobster was not running are not performed long after they were intended.
+func (schedule *PowerSchedule) Due(now time.Time) bool {
+	from := schedule.LastTime
+	if graceStart := now.Add(-POWER_SCHEDULE_GRACE * time.Minute); from.Before(graceStart) {
+		from = graceStart
+	}
+	next := schedule.nextAfter(from)
+	return !next.IsZero() && !next.After(now)
+}
+
+func powerActionOk(action string) bool {
+	return action == "start" || action == "stop" || action == "reboot"
+}
+
+func (vm *VirtualMachine) AddPowerSchedule(action string, expression string, timezone string) (int, error) {
+	expression = strings.Join(strings.Fields(expression), " ")
+	if timezone == "" {
+		timezone = "UTC"
+	}
+
+	if !powerActionOk(action) {
+		return 0, L.Error("invalid_power_action")
+	} else if len(expression) > MAX_POWER_SCHEDULE_LENGTH {
+		return 0, L.Errorf("power_expression_too_long", MAX_POWER_SCHEDULE_LENGTH)
+	} else if _, err := cronexpr.Parse(expression); err != nil {
+		return 0, L.Errorf("invalid_power_expression", err.Error())
+	} else if _, err := time.LoadLocation(timezone); err != nil || timezone == "Local" {
+		// Local is accepted by LoadLocation, but depends on the server configuration
+		return 0, L.Error("invalid_timezone")
+	} else if len(powerScheduleList(vm.Id)) >= MAX_POWER_SCHEDULES {
+		return 0, L.Errorf("power_schedule_limit", MAX_POWER_SCHEDULES)
+	}
+
+	// set time_last so that the first action is performed at the next scheduled time
+	// times are written from Go in UTC since Due compares them with time.Now
+	log.Printf("vmAddPowerSchedule(%d, %s, %s, %s)", vm.Id, action, expression, timezone)
+	result := db.Exec(
+		"INSERT INTO power_schedules (vm_id, action, expression, timezone, time_last) VALUES (?, ?, ?, ?, ?)",
+		vm.Id, action, expression, timezone, time.Now().UTC(),
+	)
+	return result.LastInsertId(), nil
+}
+
+func (vm *VirtualMachine) RemovePowerSchedule(scheduleId int) error {
+	result := db.Exec("DELETE FROM power_schedules WHERE id = ? AND vm_id = ?", scheduleId, vm.Id)
+	if result.RowsAffected() != 1 {
+		return L.Error("invalid_power_schedule")
+	}
+	return nil
+}
+
+// Performs power actions for schedules that are due, and records the results in the actions log.
+// Actions go through the usual VirtualMachine methods, so they fail on suspended virtual machines.
+func powerScheduleCron() {
+	now := time.Now()
+	for _, schedule := range powerScheduleListAll() {
+		if !schedule.Due(now) {
+			continue
+		}
+
+		vm := vmGet(schedule.VmId)
+		if vm == nil {
+			db.Exec("DELETE FROM power_schedules WHERE id = ?", schedule.Id)
+			continue
+		} else if vm.TaskPending {
+			// try again on the next run, until the grace period expires
+			continue
+		}
+
+		db.Exec("UPDATE power_schedules SET time_last = ? WHERE id = ?", now.UTC(), schedule.Id)
+		var err error
+		if schedule.Action == "start" {
+			err = vm.Start()
+		} else if schedule.Action == "stop" {
+			err = vm.Stop()
+		} else if schedule.Action == "reboot" {
+			err = vm.Reboot()
+		} else {
+			err = fmt.Errorf("unknown power action %s", schedule.Action)
+		}
+
+		result := "success"
+		if err != nil {
+			result = err.Error()
+		}
+		LogAction(vm.UserId, "", fmt.Sprintf("%s VM (scheduled)", strings.Title(schedule.Action)), fmt.Sprintf("VM ID: %d; Schedule: %d; Result: %s", vm.Id, schedule.Id, result))
+	}
+}
@@ -0,0 +1,37 @@
+package lobster
+
+import "testing"
+import "time"
+
+func TestPowerScheduleDue(t *testing.T) {
+	// Wednesday
+	now := time.Date(2016, time.March, 2, 8, 10, 0, 0, time.UTC)
+
+	schedule := &PowerSchedule{Action: "start", Expression: "0 8 * * 1-5", Timezone: "UTC", LastTime: time.Date(2016, time.March, 1, 8, 0, 5, 0, time.UTC)}
+	if !schedule.Due(now) {
+		t.Fatal("Schedule not due after scheduled time")
+	}
+	schedule.LastTime = time.Date(2016, time.March, 2, 8, 0, 5, 0, time.UTC)
+	if schedule.Due(now) {
+		t.Fatal("Schedule due after it already ran")
+	}
+
+	// scheduled times outside the grace period are skipped
+	schedule.LastTime = time.Date(2016, time.March, 1, 8, 0, 5, 0, time.UTC)
+	if schedule.Due(now.Add(3 * time.Hour)) {
+		t.Fatal("Schedule due long after scheduled time")
+	}
+
+	// expressions are evaluated in the schedule's time zone
+	schedule.Timezone = "America/Toronto"
+	if schedule.Due(now) {
+		t.Fatal("Schedule due before scheduled time in its time zone")
+	} else if !schedule.Due(now.Add(5 * time.Hour)) {
+		t.Fatal("Schedule not due after scheduled time in its time zone")
+	}
+
+	schedule.Expression = "0 0 30 2 *"
+	if schedule.Due(now) {
+		t.Fatal("Schedule that never runs is due")
+	}
+}
@@ -0,0 +1,46 @@
+package lobster
+
+import "log"
+
+// Protected virtual machines cannot be deleted, re-imaged, or resized until protection is turned off.
+// Separately, the panel can require the virtual machine's name to be typed to confirm these actions.
+
+// Returned when an operation is refused because the virtual machine is protected.
+// The API reports it with a distinct status code so that clients can tell it apart from other errors.
+type VmProtectedError struct {
+	error
+}
+
+func (vm *VirtualMachine) checkProtected() error {
+	if vm.Protected {
+		return VmProtectedError{L.Error("vm_protected")}
+	}
+	return nil
+}
+
+func (vm *VirtualMachine) SetProtected(protected bool) {
+	log.Printf("vmSetProtected(%d, %t)", vm.Id, protected)
+	db.Exec("UPDATE vms SET protected = ? WHERE id = ?", protected, vm.Id)
+	vm.Protected = protected
+}
+
+// Returns whether the panel requires the name to be typed to confirm dangerous actions.
+func (vm *VirtualMachine) ConfirmName() bool {
+	return vm.Metadata("confirm_name", "") == "yes"
+}
+
+func (vm *VirtualMachine) SetConfirmName(confirm bool) {
+	if confirm {
+		vm.SetMetadata("confirm_name", "yes")
+	} else {
+		vm.SetMetadata("confirm_name", "no")
+	}
+}
+
+// Checks the name typed to confirm a dangerous action, if the virtual machine requires it.
+func (vm *VirtualMachine) checkConfirmName(name string) error {
+	if vm.ConfirmName() && name != vm.Name {
+		return L.Error("vm_confirm_name_mismatch")
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package lobster
+
+import "testing"
+
+func TestVmProtected(t *testing.T) {
+	TestReset()
+	cfg.Billing.BillingInterval = 60
+	defer TestRegion("testprotect", &TestVmi{})()
+	userId := TestUser()
+	vmId := TestVm(userId)
+	db.Exec("UPDATE vms SET region = 'testprotect' WHERE id = ?", vmId)
+
+	vm := vmGet(vmId)
+	vm.SetProtected(true)
+	vm = vmGet(vmId)
+	if !vm.Protected {
+		t.Fatal("Virtual machine not protected after SetProtected")
+	}
+	if _, ok := vm.Delete(userId).(VmProtectedError); !ok {
+		t.Fatal("Expected VmProtectedError when deleting protected virtual machine")
+	}
+	if _, ok := vm.Resize(1).(VmProtectedError); !ok {
+		t.Fatal("Expected VmProtectedError when resizing protected virtual machine")
+	}
+	if vmGet(vmId) == nil {
+		t.Fatal("Protected virtual machine was deleted")
+	}
+
+	// name confirmation is only required once enabled
+	if err := vm.checkConfirmName(""); err != nil {
+		t.Fatalf("Name confirmation required before it was enabled: %v", err)
+	}
+	vm.SetConfirmName(true)
+	if err := vm.checkConfirmName("wrong"); err == nil {
+		t.Fatal("Name confirmation accepted the wrong name")
+	} else if err := vm.checkConfirmName(vm.Name); err != nil {
+		t.Fatalf("Name confirmation rejected the correct name: %v", err)
+	}
+
+	// termination by the administrator bypasses protection
+	if err := vm.DeleteForce(userId); err != nil {
+		t.Fatalf("Failed to force delete protected virtual machine: %v", err)
+	}
+	if vmGet(vmId) != nil {
+		t.Fatal("Virtual machine still exists after DeleteForce")
+	}
+}
@@ -6,8 +6,8 @@ import "sort"
 var regionInterfaces map[string]VmInterface = make(map[string]VmInterface)
 
 func vmGetInterface(region string) VmInterface {
-	vmi, ok := regionInterfaces[region]
-	if !ok {
+	vmi := regionInterface(region)
+	if vmi == nil {
 		panic(errors.New("no interface registered for " + region))
 	}
 	return vmi
@@ -18,14 +18,15 @@ func vmGetInterface(region string) VmInterface {
 //  * enabled
 
 type Region struct {
-	Region  string
-	Enabled bool
+	Region   string
+	Enabled  bool
+	Draining bool // removed from the configuration, but still has resources
 }
 
 func regionList() []string {
 	var regions []string
 	for _, region := range regionListAll() {
-		if region.Enabled {
+		if region.Enabled && !region.Draining {
 			regions = append(regions, region.Region)
 		}
 	}
@@ -40,15 +41,17 @@ func regionListAll() []Region {
 	for rows.Next() {
 		var region Region
 		rows.Scan(&region.Region, &region.Enabled)
+		region.Draining = regionDraining(region.Region)
 		regions = append(regions, region)
 		seenRegions[region.Region] = true
 	}
 
-	for region := range regionInterfaces {
+	for region := range regionInterfaceList() {
 		if !seenRegions[region] {
 			regions = append(regions, Region{
-				Region:  region,
-				Enabled: true,
+				Region:   region,
+				Enabled:  true,
+				Draining: regionDraining(region),
 			})
 		}
 	}
@@ -56,7 +59,44 @@ func regionListAll() []Region {
 	return regions
 }
 
+// Returns whether virtual machines created in the region can be provided with user-data.
+func regionCanUserData(region string) bool {
+	vmi, ok := regionInterface(region).(VMIUserData)
+	return ok && vmi.CanUserData()
+}
+
+// Returns whether SSH keys are still authorized on new virtual machines in the region when user-data is provided.
+func regionCanUserDataKeys(region string) bool {
+	vmi, ok := regionInterface(region).(VMIUserDataKeys)
+	return ok && vmi.CanUserDataKeys()
+}
+
+// Returns whether virtual machines in the region can be provided with user-data when re-imaging.
+func regionCanReimageUserData(region string) bool {
+	vmi, ok := regionInterface(region).(VMIReimageUserData)
+	return ok && vmi.CanReimageUserData()
+}
+
+// Returns whether public keys can be authorized on virtual machines in the region when re-imaging.
+func regionCanReimageKeys(region string) bool {
+	vmi, ok := regionInterface(region).(VMIReimageKeys)
+	return ok && vmi.CanReimageKeys()
+}
+
+// Returns the maximum number of public keys for new virtual machines in the region, or zero if unlimited.
+func regionKeyLimit(region string) int {
+	vmi, ok := regionInterface(region).(VMIKeyLimit)
+	if ok {
+		return vmi.KeyLimit()
+	} else {
+		return 0
+	}
+}
+
 func regionEnabled(region string) bool {
+	if regionDraining(region) {
+		return false
+	}
 	var count int
 	db.QueryRow("SELECT COUNT(*) FROM regions WHERE region = ? AND enabled = 0", region).Scan(&count)
 	return count == 0
@@ -0,0 +1,151 @@
+package lobster
+
+import "log"
+
+// Back-ends that do not implement VMIRescue can list actions with these names in VmInfo.Actions.
+// Lobster removes them from the generic actions and calls VmAction from the rescue buttons instead.
+// The value for VM_ACTION_MOUNT_ISO is the image identification.
+const VM_ACTION_RESCUE = "rescue"
+const VM_ACTION_UNRESCUE = "unrescue"
+const VM_ACTION_MOUNT_ISO = "mount_iso"
+const VM_ACTION_UNMOUNT_ISO = "unmount_iso"
+
+// Adapts VmAction-based rescue operations into VMIRescue.
+type vmiActionRescue struct {
+	vmi VmInterface
+}
+
+func (this vmiActionRescue) VmRescue(vm *VirtualMachine) (string, error) {
+	return "", this.vmi.VmAction(vm, VM_ACTION_RESCUE, "")
+}
+
+func (this vmiActionRescue) VmUnrescue(vm *VirtualMachine) error {
+	return this.vmi.VmAction(vm, VM_ACTION_UNRESCUE, "")
+}
+
+func (this vmiActionRescue) VmMountISO(vm *VirtualMachine, imageIdentification string) error {
+	return this.vmi.VmAction(vm, VM_ACTION_MOUNT_ISO, imageIdentification)
+}
+
+func (this vmiActionRescue) VmUnmountISO(vm *VirtualMachine) error {
+	return this.vmi.VmAction(vm, VM_ACTION_UNMOUNT_ISO, "")
+}
+
+func vmActionIsRescue(action string) bool {
+	return action == VM_ACTION_RESCUE || action == VM_ACTION_UNRESCUE || action == VM_ACTION_MOUNT_ISO || action == VM_ACTION_UNMOUNT_ISO
+}
+
+// Removes rescue actions from the virtual machine information, and returns whether there were any.
+func vmInfoExtractRescueActions(info *VmInfo) bool {
+	var actions []*VmActionDescriptor
+	found := false
+	for _, action := range info.Actions {
+		if vmActionIsRescue(action.Action) {
+			found = true
+		} else {
+			actions = append(actions, action)
+		}
+	}
+	info.Actions = actions
+	return found
+}
+
+// Values of the "rescue" metadata, which records whether the virtual machine was booted into rescue mode or from an ISO.
+// The metadata is empty when the virtual machine boots normally.
+const VM_RESCUE_RESCUE = "rescue"
+const VM_RESCUE_ISO = "iso"
+
+func (vm *VirtualMachine) RescueState() string {
+	return vm.Metadata("rescue", "")
+}
+
+// Returns the rescue interface for the virtual machine, or nil if rescue is not supported.
+func (vm *VirtualMachine) rescueInterface() VMIRescue {
+	vmi := vmGetInterface(vm.Region)
+	if vmiRescue, ok := vmi.(VMIRescue); ok {
+		return vmiRescue
+	}
+	vm.LoadInfo()
+	if vm.Info.CanRescue {
+		return vmiActionRescue{vmi}
+	}
+	return nil
+}
+
+// Boots the virtual machine into rescue mode, and returns the password of the rescue system.
+// The password is empty if the back-end does not set one.
+func (vm *VirtualMachine) Rescue() (string, error) {
+	log.Printf("vmRescue(%d)", vm.Id)
+	var password string
+	err := vm.do(func(vm *VirtualMachine) error {
+		vmi := vm.rescueInterface()
+		if vmi == nil {
+			return L.Error("vm_rescue_unsupported")
+		}
+		passwordTry, err := vmi.VmRescue(vm)
+		if err != nil {
+			return err
+		}
+		password = passwordTry
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	vm.SetMetadata("rescue", VM_RESCUE_RESCUE)
+	return password, nil
+}
+
+func (vm *VirtualMachine) Unrescue() error {
+	log.Printf("vmUnrescue(%d)", vm.Id)
+	err := vm.do(func(vm *VirtualMachine) error {
+		vmi := vm.rescueInterface()
+		if vmi == nil {
+			return L.Error("vm_rescue_unsupported")
+		}
+		return vmi.VmUnrescue(vm)
+	})
+	if err == nil {
+		vm.SetMetadata("rescue", "")
+	}
+	return err
+}
+
+func (vm *VirtualMachine) MountISO(imageId int) error {
+	image := imageGet(vm.UserId, imageId)
+	if image == nil {
+		return L.Error("image_not_exist")
+	} else if image.Status != "active" {
+		return L.Error("image_not_ready")
+	} else if image.Region != vm.Region {
+		return L.Error("image_wrong_region")
+	}
+
+	log.Printf("vmMountISO(%d, %d)", vm.Id, imageId)
+	err := vm.do(func(vm *VirtualMachine) error {
+		vmi := vm.rescueInterface()
+		if vmi == nil {
+			return L.Error("vm_rescue_unsupported")
+		}
+		return vmi.VmMountISO(vm, image.Identification)
+	})
+	if err == nil {
+		vm.SetMetadata("rescue", VM_RESCUE_ISO)
+	}
+	return err
+}
+
+func (vm *VirtualMachine) UnmountISO() error {
+	log.Printf("vmUnmountISO(%d)", vm.Id)
+	err := vm.do(func(vm *VirtualMachine) error {
+		vmi := vm.rescueInterface()
+		if vmi == nil {
+			return L.Error("vm_rescue_unsupported")
+		}
+		return vmi.VmUnmountISO(vm)
+	})
+	if err == nil {
+		vm.SetMetadata("rescue", "")
+	}
+	return err
+}
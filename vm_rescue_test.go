@@ -0,0 +1,56 @@
+package lobster
+
+import "testing"
+
+type testActionVmi struct {
+	TestVmi
+	actions []string
+}
+
+func (this *testActionVmi) VmAction(vm *VirtualMachine, action string, value string) error {
+	this.actions = append(this.actions, action+":"+value)
+	return nil
+}
+
+func TestVmRescueActions(t *testing.T) {
+	TestReset()
+	vmi := &testActionVmi{}
+	defer TestRegion("testrescue", vmi)()
+	userId := TestUser()
+	vmId := TestVm(userId)
+	db.Exec("UPDATE vms SET region = 'testrescue', identification = 'test' WHERE id = ?", vmId)
+	vm := vmGetUser(userId, vmId)
+	vm.Info = &VmInfo{Actions: []*VmActionDescriptor{
+		{Action: VM_ACTION_RESCUE, Name: "Rescue"},
+		{Action: "tuntap", Name: "TUN/TAP"},
+		{Action: VM_ACTION_UNMOUNT_ISO, Name: "Unmount ISO"},
+	}}
+
+	// rescue actions should be removed from the generic actions
+	vm.Info.CanRescue = vmInfoExtractRescueActions(vm.Info)
+	if !vm.Info.CanRescue {
+		t.Fatal("Expected rescue actions to be detected")
+	} else if len(vm.Info.Actions) != 1 || vm.Info.Actions[0].Action != "tuntap" {
+		t.Fatalf("Expected only the tuntap action to remain, got %d actions", len(vm.Info.Actions))
+	}
+
+	if password, err := vm.Rescue(); err != nil {
+		t.Fatalf("Failed to rescue: %v", err)
+	} else if password != "" {
+		t.Fatalf("Expected no rescue password from actions, got %s", password)
+	} else if vm.RescueState() != VM_RESCUE_RESCUE {
+		t.Fatalf("Expected rescue state after rescue, got %s", vm.RescueState())
+	}
+	if err := vm.UnmountISO(); err != nil {
+		t.Fatalf("Failed to unmount ISO: %v", err)
+	} else if vm.RescueState() != "" {
+		t.Fatalf("Expected no rescue state after unmount, got %s", vm.RescueState())
+	}
+	if len(vmi.actions) != 2 || vmi.actions[0] != "rescue:" || vmi.actions[1] != "unmount_iso:" {
+		t.Fatalf("Unexpected actions %v", vmi.actions)
+	}
+
+	if vmInfoExtractRescueActions(&VmInfo{Actions: []*VmActionDescriptor{{Action: "tuntap"}}}) {
+		t.Fatal("Expected no rescue actions to be detected")
+	}
+}
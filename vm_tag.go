@@ -0,0 +1,161 @@
+package lobster
+
+import "log"
+import "strings"
+import "time"
+import "unicode/utf8"
+
+// Tags are free-form labels on virtual machines, used for grouping and for cost breakdowns.
+// A tag like "env=prod" can be used as a key/value label; lobster only matches tags exactly.
+// Tags are stored per owner, and kept after the virtual machine is deleted or transferred,
+// so that its past charges remain attributed to the tags of the user who paid them.
+
+type TagCost struct {
+	Tag    string // empty for charges of virtual machines without tags
+	Amount int64
+}
+
+// Splits a comma-separated list of tags, removing whitespace, empty entries, and duplicates.
+func tagsParse(s string) []string {
+	tags := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, tag := range strings.Split(s, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func tagOk(tag string) error {
+	if len(tag) == 0 || len(tag) > MAX_VM_TAG_LENGTH {
+		return L.Errorf("invalid_tag_length", MAX_VM_TAG_LENGTH)
+	} else if !isPrintable(tag) || strings.Contains(tag, ",") {
+		return L.Error("invalid_tag_format")
+	} else {
+		return nil
+	}
+}
+
+// Sets tags on the virtual machines in vms with a single query.
+func vmLoadTags(vms []*VirtualMachine) {
+	if len(vms) == 0 {
+		return
+	}
+	vmMap := make(map[int]*VirtualMachine)
+	var placeholders []string
+	var args []interface{}
+	for _, vm := range vms {
+		vm.Tags = make([]string, 0)
+		vmMap[vm.Id] = vm
+		placeholders = append(placeholders, "?")
+		args = append(args, vm.Id)
+	}
+
+	rows := db.Query(
+		"SELECT vm_tags.vm_id, vm_tags.tag FROM vm_tags, vms "+
+			"WHERE vm_tags.vm_id = vms.id AND vm_tags.user_id = vms.user_id AND vm_tags.vm_id IN ("+strings.Join(placeholders, ", ")+") "+
+			"ORDER BY vm_tags.tag",
+		args...,
+	)
+	defer rows.Close()
+	for rows.Next() {
+		var vmId int
+		var tag string
+		rows.Scan(&vmId, &tag)
+		vmMap[vmId].Tags = append(vmMap[vmId].Tags, tag)
+	}
+}
+
+func (vm *VirtualMachine) LoadTags() {
+	vmLoadTags([]*VirtualMachine{vm})
+}
+
+// Returns the virtual machines of the user that have the tag.
+func vmListTag(userId int, tag string) []*VirtualMachine {
+	vms := vmListHelper(db.Query(VM_QUERY+" AND vms.user_id = ? AND vms.id IN (SELECT vm_id FROM vm_tags WHERE tag = ? AND user_id = ?) ORDER BY id DESC", userId, tag, userId))
+	vmLoadTags(vms)
+	return vms
+}
+
+// Returns the distinct tags on the user's virtual machines.
+func tagList(userId int) []string {
+	rows := db.Query("SELECT DISTINCT vm_tags.tag FROM vm_tags, vms WHERE vm_tags.vm_id = vms.id AND vm_tags.user_id = vms.user_id AND vms.user_id = ? ORDER BY vm_tags.tag", userId)
+	defer rows.Close()
+	tags := make([]string, 0)
+	for rows.Next() {
+		var tag string
+		rows.Scan(&tag)
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// Replaces the tags on the virtual machine.
+func (vm *VirtualMachine) SetTags(tags []string) error {
+	if len(tags) > MAX_VM_TAGS {
+		return L.Errorf("too_many_tags", MAX_VM_TAGS)
+	}
+	for _, tag := range tags {
+		err := tagOk(tag)
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Printf("vmSetTags(%d, %v)", vm.Id, tags)
+	db.Exec("DELETE FROM vm_tags WHERE vm_id = ? AND user_id = ?", vm.Id, vm.UserId)
+	for _, tag := range tags {
+		db.Exec("INSERT IGNORE INTO vm_tags (vm_id, user_id, tag) VALUES (?, ?, ?)", vm.Id, vm.UserId, tag)
+	}
+	vm.Tags = tags
+	return nil
+}
+
+func (vm *VirtualMachine) Notes() string {
+	return vm.Metadata("notes", "")
+}
+
+func (vm *VirtualMachine) SetNotes(notes string) error {
+	if utf8.RuneCountInString(notes) > MAX_VM_NOTES_LENGTH {
+		return L.Errorf("notes_too_long", MAX_VM_NOTES_LENGTH)
+	} else if !utf8.ValidString(notes) || strings.ContainsRune(notes, 0) {
+		return L.Error("invalid_notes")
+	}
+	vm.SetMetadata("notes", notes)
+	return nil
+}
+
+// Returns the charges for the user's virtual machines in the month, totalled per tag.
+// A virtual machine with several tags counts towards each of them.
+func tagCostBreakdown(userId int, year int, month time.Month) []*TagCost {
+	timeStart := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	timeEnd := timeStart.AddDate(0, 1, 0)
+	costs := make([]*TagCost, 0)
+
+	rows := db.Query(
+		"SELECT vm_tags.tag, SUM(charges.amount) FROM charges, vm_tags "+
+			"WHERE charges.k = CONCAT('vm-', vm_tags.vm_id) AND vm_tags.user_id = charges.user_id AND charges.user_id = ? AND charges.time >= ? AND charges.time < ? "+
+			"GROUP BY vm_tags.tag ORDER BY vm_tags.tag",
+		userId, timeStart.Format(MYSQL_TIME_FORMAT), timeEnd.Format(MYSQL_TIME_FORMAT),
+	)
+	defer rows.Close()
+	for rows.Next() {
+		cost := TagCost{}
+		rows.Scan(&cost.Tag, &cost.Amount)
+		costs = append(costs, &cost)
+	}
+
+	untagged := TagCost{}
+	db.QueryRow(
+		"SELECT IFNULL(SUM(amount), 0) FROM charges WHERE user_id = ? AND time >= ? AND time < ? AND k LIKE 'vm-%' "+
+			"AND k NOT IN (SELECT CONCAT('vm-', vm_id) FROM vm_tags WHERE user_id = ?)",
+		userId, timeStart.Format(MYSQL_TIME_FORMAT), timeEnd.Format(MYSQL_TIME_FORMAT), userId,
+	).Scan(&untagged.Amount)
+	if untagged.Amount != 0 {
+		costs = append(costs, &untagged)
+	}
+	return costs
+}
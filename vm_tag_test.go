@@ -0,0 +1,15 @@
+package lobster
+
+import "reflect"
+import "testing"
+
+func TestTagsParse(t *testing.T) {
+	tags := tagsParse(" web, env=prod,,web ,db ")
+	expected := []string{"web", "env=prod", "db"}
+	if !reflect.DeepEqual(tags, expected) {
+		t.Fatalf("Parsed tags %v do not match %v", tags, expected)
+	}
+	if len(tagsParse("")) != 0 {
+		t.Fatal("Expected no tags from empty string")
+	}
+}
@@ -0,0 +1,127 @@
+package lobster
+
+import "strings"
+import "testing"
+
+type testConsoleVmi struct {
+	TestVmi
+	output string
+}
+
+func (this *testConsoleVmi) VmConsoleOutput(vm *VirtualMachine, length int) (string, error) {
+	return this.output, nil
+}
+
+func TestVmConsoleOutput(t *testing.T) {
+	defer TestRegion("testconsole", &testConsoleVmi{output: strings.Repeat("a", 2048) + "login: "})()
+	vm := &VirtualMachine{Region: "testconsole", Identification: "test", Status: "active"}
+
+	// output exceeding the requested length should be truncated from the start
+	output, err := vm.ConsoleOutput(1)
+	if err != nil {
+		t.Fatalf("Failed to get console output: %v", err)
+	} else if len(output) != 1024 || !strings.HasSuffix(output, "login: ") {
+		t.Fatalf("Expected last 1024 bytes of console output, got %d bytes", len(output))
+	}
+
+	output, err = vm.ConsoleOutput(0)
+	if err != nil {
+		t.Fatalf("Failed to get console output: %v", err)
+	} else if len(output) != 2048+len("login: ") {
+		t.Fatalf("Expected full console output with default length, got %d bytes", len(output))
+	}
+}
+
+type testPasswordVmi struct {
+	TestVmi
+}
+
+func (this *testPasswordVmi) VmResetPassword(vm *VirtualMachine) (string, error) {
+	return "secret", nil
+}
+
+func TestVmResetPassword(t *testing.T) {
+	TestReset()
+	defer TestRegion("testpassword", &testPasswordVmi{})()
+	userId := TestUser()
+	vmId := TestVm(userId)
+	db.Exec("UPDATE vms SET region = 'testpassword', identification = 'test' WHERE id = ?", vmId)
+
+	password, err := vmGet(vmId).ResetPassword("127.0.0.1")
+	if err != nil {
+		t.Fatalf("Failed to reset password: %v", err)
+	} else if password != "secret" {
+		t.Fatalf("Expected password from interface, got %s", password)
+	}
+
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM actions WHERE user_id = ? AND name = 'Reset VM password'", userId).Scan(&count)
+	if count != 1 {
+		t.Fatalf("Expected password reset to be logged once, got %d", count)
+	}
+}
+
+// does not implement VMIReimageKeys
+type testReimageVmi struct {
+	TestVmi
+	options *VMIReimageOptions
+}
+
+func (this *testReimageVmi) VmReimage(vm *VirtualMachine, options *VMIReimageOptions) error {
+	this.options = options
+	return nil
+}
+
+func TestVmReimageKeys(t *testing.T) {
+	TestReset()
+	vmi := &testReimageVmi{}
+	defer TestRegion("testreimage", vmi)()
+	userId := TestUser()
+	vmId := TestVm(userId)
+	db.Exec("UPDATE vms SET region = 'testreimage', identification = 'test' WHERE id = ?", vmId)
+	imageId := db.Exec("INSERT INTO images (user_id, region, name, identification) VALUES (?, 'testreimage', 'image', 'test')", userId).LastInsertId()
+	keyId := db.Exec("INSERT INTO sshkeys (user_id, name, val) VALUES (?, 'key', 'ssh-ed25519 AAAA')", userId).LastInsertId()
+	defer db.Exec("DELETE FROM sshkeys WHERE id = ?", keyId)
+
+	// keys would be dropped by the interface, so they must be refused
+	if err := vmReimage(userId, vmId, imageId, VmReimageOptions{KeyIDs: []int{keyId}}); err == nil {
+		t.Fatal("Re-image with keys passed on interface without key support")
+	} else if vmi.options != nil {
+		t.Fatal("Interface called after refusing keys")
+	}
+
+	if err := vmReimage(userId, vmId, imageId, VmReimageOptions{}); err != nil {
+		t.Fatalf("Re-image failed: %v", err)
+	} else if vmi.options == nil || vmi.options.ImageIdentification != "test" {
+		t.Fatal("Interface not called with image identification")
+	}
+}
+
+// supports user-data, but does not implement VMIUserDataKeys
+type testUserDataVmi struct {
+	TestVmi
+}
+
+func (this *testUserDataVmi) CanUserData() bool {
+	return true
+}
+
+func TestVmCreateUserDataKeys(t *testing.T) {
+	TestReset()
+	defer TestRegion("testuserdata", &testUserDataVmi{})()
+	userId := TestUser()
+	planId := db.Exec("INSERT INTO plans (name, price, ram, cpu, storage, bandwidth) VALUES ('', 6000, 512, 1, 15, 1000)").LastInsertId()
+	imageId := db.Exec("INSERT INTO images (user_id, region, name, identification) VALUES (?, 'testuserdata', 'image', 'test')", userId).LastInsertId()
+	keyId := db.Exec("INSERT INTO sshkeys (user_id, name, val) VALUES (?, 'key', 'ssh-ed25519 AAAA')", userId).LastInsertId()
+	defer db.Exec("DELETE FROM sshkeys WHERE id = ?", keyId)
+
+	// the user-data would replace the keys, so they must be refused
+	if _, err := vmCreate(userId, "test", planId, imageId, VmCreateOptions{KeyIDs: []int{keyId}, UserData: "#cloud-config\n"}); err == nil {
+		t.Fatal("Create with keys and user-data passed on interface without key support")
+	}
+	if _, err := vmCreate(userId, "test", planId, imageId, VmCreateOptions{KeyIDs: []int{keyId}}); err != nil {
+		t.Fatalf("Create with keys failed: %v", err)
+	} else if _, err := vmCreate(userId, "test", planId, imageId, VmCreateOptions{UserData: "#cloud-config\n"}); err != nil {
+		t.Fatalf("Create with user-data failed: %v", err)
+	}
+}
@@ -0,0 +1,200 @@
+package lobster
+
+import "fmt"
+import "log"
+import "time"
+
+// database objects
+
+// Pending transfer of a virtual machine to another user, who must accept it.
+type VmTransfer struct {
+	Id          int
+	VmId        int
+	FromUserId  int
+	ToUserId    int
+	CreatedTime time.Time
+
+	// set by vmTransferListIncoming and vmTransferGetVm
+	VmName       string
+	FromUsername string
+	ToUsername   string
+}
+
+func vmTransferListHelper(rows Rows) []*VmTransfer {
+	defer rows.Close()
+	transfers := make([]*VmTransfer, 0)
+	for rows.Next() {
+		transfer := VmTransfer{}
+		rows.Scan(&transfer.Id, &transfer.VmId, &transfer.FromUserId, &transfer.ToUserId, &transfer.CreatedTime, &transfer.VmName, &transfer.FromUsername, &transfer.ToUsername)
+		transfers = append(transfers, &transfer)
+	}
+	return transfers
+}
+
+const VM_TRANSFER_QUERY = "SELECT vm_transfers.id, vm_transfers.vm_id, vm_transfers.from_user_id, vm_transfers.to_user_id, vm_transfers.time_created, " +
+	"vms.name, from_users.username, to_users.username " +
+	"FROM vm_transfers " +
+	"JOIN vms ON vms.id = vm_transfers.vm_id " +
+	"JOIN users AS from_users ON from_users.id = vm_transfers.from_user_id " +
+	"JOIN users AS to_users ON to_users.id = vm_transfers.to_user_id"
+
+// Returns the transfers that the user can accept.
+func vmTransferListIncoming(userId int) []*VmTransfer {
+	return vmTransferListHelper(db.Query(VM_TRANSFER_QUERY+" WHERE vm_transfers.to_user_id = ? ORDER BY vm_transfers.id", userId))
+}
+
+// Returns the pending transfer of the virtual machine, or nil if there is none.
+func vmTransferGetVm(vmId int) *VmTransfer {
+	transfers := vmTransferListHelper(db.Query(VM_TRANSFER_QUERY+" WHERE vm_transfers.vm_id = ?", vmId))
+	if len(transfers) == 1 {
+		return transfers[0]
+	} else {
+		return nil
+	}
+}
+
+func vmTransferGetIncoming(userId int, transferId int) *VmTransfer {
+	transfers := vmTransferListHelper(db.Query(VM_TRANSFER_QUERY+" WHERE vm_transfers.id = ? AND vm_transfers.to_user_id = ?", transferId, userId))
+	if len(transfers) == 1 {
+		return transfers[0]
+	} else {
+		return nil
+	}
+}
+
+// Finds an account that can receive virtual machines by username, or otherwise by email address.
+func vmTransferFindUser(recipient string) *User {
+	var userId int
+	rows := db.Query("SELECT id FROM users WHERE username = ? AND status != 'disabled'", recipient)
+	if rows.Next() {
+		rows.Scan(&userId)
+	}
+	rows.Close()
+	if userId == 0 && recipient != "" {
+		rows = db.Query("SELECT id FROM users WHERE email = ? AND status != 'disabled'", recipient)
+		if rows.Next() {
+			rows.Scan(&userId)
+		}
+		rows.Close()
+	}
+	if userId == 0 {
+		return nil
+	}
+	return UserDetails(userId)
+}
+
+// Checks that the virtual machine has no resources that belong to its owner rather than to itself.
+// Volumes, reserved IPs, and private networks stay with the owner, so they must be detached first.
+func (vm *VirtualMachine) transferOk() error {
+	if len(volumeListVm(vm.Id)) > 0 || len(reservedIpListVm(vm.Id)) > 0 || len(networkListVm(vm.Id)) > 0 {
+		return L.Error("vm_transfer_attached")
+	}
+	return nil
+}
+
+// Offers the virtual machine to the user with the given username or email address.
+func (vm *VirtualMachine) TransferStart(recipient string) (*User, error) {
+	toUser := vmTransferFindUser(recipient)
+	if toUser == nil {
+		return nil, L.Error("vm_transfer_no_user")
+	} else if toUser.Id == vm.UserId {
+		return nil, L.Error("vm_transfer_self")
+	} else if vmTransferGetVm(vm.Id) != nil {
+		return nil, L.Error("vm_transfer_pending")
+	}
+
+	err := vm.do(func(vm *VirtualMachine) error {
+		return vm.transferOk()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("vmTransferStart(%d, %d, %d)", vm.Id, vm.UserId, toUser.Id)
+	db.Exec("INSERT INTO vm_transfers (vm_id, from_user_id, to_user_id) VALUES (?, ?, ?)", vm.Id, vm.UserId, toUser.Id)
+	MailWrap(toUser.Id, "vmTransferRequest", VmTransferEmail{Id: vm.Id, Name: vm.Name, Username: UserDetails(vm.UserId).Username}, false)
+	return toUser, nil
+}
+
+func (vm *VirtualMachine) TransferCancel() error {
+	result := db.Exec("DELETE FROM vm_transfers WHERE vm_id = ? AND from_user_id = ?", vm.Id, vm.UserId)
+	if result.RowsAffected() != 1 {
+		return L.Error("vm_transfer_not_found")
+	}
+	return nil
+}
+
+func vmTransferDecline(userId int, transferId int) error {
+	result := db.Exec("DELETE FROM vm_transfers WHERE id = ? AND to_user_id = ?", transferId, userId)
+	if result.RowsAffected() != 1 {
+		return L.Error("vm_transfer_not_found")
+	}
+	return nil
+}
+
+// Moves the virtual machine of the transfer to the accepting user.
+// Billing is finalized for the previous owner as if the virtual machine were deleted, and the accepting
+// user is billed from then on, as if they created it; snapshots and backups of the virtual machine move
+// with it, and the transfer is recorded in the actions log of both users.
+func vmTransferAccept(userId int, transferId int, ip string) (int, error) {
+	transfer := vmTransferGetIncoming(userId, transferId)
+	if transfer == nil {
+		return 0, L.Error("vm_transfer_not_found")
+	}
+	vm := vmGetUser(transfer.FromUserId, transfer.VmId)
+	if vm == nil {
+		db.Exec("DELETE FROM vm_transfers WHERE id = ?", transfer.Id)
+		return 0, L.Error("vm_transfer_not_found")
+	}
+
+	// validate credit and limit of the accepting user
+	user := UserDetails(userId)
+	if user == nil {
+		return 0, L.Error("invalid_account")
+	} else if user.Credit < MINIMUM_CREDIT {
+		return 0, L.Error("insufficient_credit")
+	}
+	var vmCount int
+	db.QueryRow("SELECT COUNT(*) FROM vms WHERE user_id = ?", userId).Scan(&vmCount)
+	if vmCount >= user.VmLimit {
+		return 0, L.Error("exceeded_vm_limit")
+	}
+	err := userQuotaCheck(userId, planQuotaRequest(&vm.Plan))
+	if err != nil {
+		return 0, err
+	}
+
+	log.Printf("vmTransferAccept(%d, %d, %d, %d)", transfer.Id, vm.Id, transfer.FromUserId, userId)
+	err = vm.do(func(vm *VirtualMachine) error {
+		// attachments may have been added since the transfer was started
+		err := vm.transferOk()
+		if err != nil {
+			return err
+		}
+
+		// the previous owner keeps the plan bandwidth for the time they had the virtual machine,
+		// and bandwidth used so far is accounted to them by vmBilling
+		vmBilling(vm.Id, true)
+		vmUpdateAdditionalBandwidth(vm)
+
+		db.Exec("UPDATE vms SET user_id = ?, time_created = NOW() WHERE id = ? AND user_id = ?", userId, vm.Id, transfer.FromUserId)
+		db.Exec("UPDATE images SET user_id = ? WHERE source_vm = ? AND user_id = ?", userId, vm.Id, transfer.FromUserId)
+
+		// notes are private to the previous owner, and their schedules and checks would otherwise
+		// keep acting on (and charging for) the virtual machine; tags stay with the previous owner
+		// for their past charges, since they are stored per user (see vm_tag.go)
+		db.Exec("DELETE FROM vm_metadata WHERE vm_id = ? AND k = 'notes'", vm.Id)
+		db.Exec("DELETE FROM backup_schedules WHERE vm_id = ?", vm.Id)
+		db.Exec("DELETE FROM power_schedules WHERE vm_id = ?", vm.Id)
+		db.Exec("DELETE FROM health_checks WHERE vm_id = ?", vm.Id)
+		db.Exec("DELETE FROM vm_transfers WHERE id = ?", transfer.Id)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	LogAction(transfer.FromUserId, ip, "Transfer VM out", fmt.Sprintf("VM ID: %d; Name: %s; To: %s", vm.Id, vm.Name, user.Username))
+	LogAction(userId, ip, "Transfer VM in", fmt.Sprintf("VM ID: %d; Name: %s; From: %s", vm.Id, vm.Name, transfer.FromUsername))
+	return vm.Id, nil
+}
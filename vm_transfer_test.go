@@ -0,0 +1,80 @@
+package lobster
+
+import "fmt"
+import "testing"
+import "time"
+
+func TestVmTransfer(t *testing.T) {
+	TestReset()
+	cfg.Billing.BillingInterval = 60
+	defer TestRegion("testtransfer", &TestVmi{})()
+	fromUserId := TestUser()
+	toUserId := TestUser()
+	db.Exec("UPDATE users SET email = 'to@example.com' WHERE id = ?", toUserId)
+	vmId := TestVm(fromUserId)
+	db.Exec("UPDATE vms SET region = 'testtransfer', identification = 'test' WHERE id = ?", vmId)
+	db.Exec("INSERT INTO images (user_id, region, name, source_vm) VALUES (?, 'testtransfer', 'snapshot', ?)", fromUserId, vmId)
+
+	db.Exec("INSERT INTO vm_tags (vm_id, user_id, tag) VALUES (?, ?, 'private')", vmId, fromUserId)
+	db.Exec("INSERT INTO vm_metadata (vm_id, k, v) VALUES (?, 'notes', 'private notes')", vmId)
+	db.Exec("INSERT INTO backup_schedules (vm_id, frequency, hour, retention) VALUES (?, 'daily', 3, 7)", vmId)
+	db.Exec("INSERT INTO health_checks (vm_id, protocol, port) VALUES (?, 'tcp', 22)", vmId)
+
+	vm := vmGet(vmId)
+	if _, err := vm.TransferStart("nobody@example.com"); err == nil {
+		t.Fatal("Transfer started to non-existent user")
+	}
+	if _, err := vm.TransferStart("to@example.com"); err != nil {
+		t.Fatalf("Failed to start transfer: %v", err)
+	}
+	transfers := vmTransferListIncoming(toUserId)
+	if len(transfers) != 1 || transfers[0].VmId != vmId {
+		t.Fatalf("Expected one incoming transfer, got %d", len(transfers))
+	}
+
+	// only the recipient can accept
+	if _, err := vmTransferAccept(fromUserId, transfers[0].Id, "127.0.0.1"); err == nil {
+		t.Fatal("Transfer accepted by sender")
+	}
+	if _, err := vmTransferAccept(toUserId, transfers[0].Id, "127.0.0.1"); err != nil {
+		t.Fatalf("Failed to accept transfer: %v", err)
+	}
+	if vmGetUser(toUserId, vmId) == nil {
+		t.Fatal("Virtual machine not owned by recipient after transfer")
+	}
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM images WHERE source_vm = ? AND user_id = ?", vmId, toUserId).Scan(&count)
+	if count != 1 {
+		t.Fatal("Snapshot not moved with virtual machine")
+	}
+	for _, table := range []string{"vm_metadata", "backup_schedules", "power_schedules", "health_checks"} {
+		db.QueryRow("SELECT COUNT(*) FROM "+table+" WHERE vm_id = ?", vmId).Scan(&count)
+		if count != 0 {
+			t.Fatalf("Expected %s of sender to be removed, got %d", table, count)
+		}
+	}
+	db.QueryRow("SELECT COUNT(*) FROM charges WHERE user_id = ? AND k = ?", fromUserId, fmt.Sprintf("vm-%d", vmId)).Scan(&count)
+	if count != 1 {
+		t.Fatalf("Expected final charge for sender, got %d", count)
+	}
+
+	// tags stay with the sender for their past charges, and the recipient's tags are not shown to them
+	vm = vmGet(vmId)
+	vm.LoadTags()
+	if len(vm.Tags) != 0 {
+		t.Fatalf("Sender tags visible to recipient: %v", vm.Tags)
+	}
+	vm.SetTags([]string{"recipient"})
+	now := time.Now()
+	costs := tagCostBreakdown(fromUserId, now.Year(), now.Month())
+	if len(costs) != 1 || costs[0].Tag != "private" {
+		t.Fatalf("Expected sender charges under their own tag, got %v", costs)
+	}
+	if tags := tagList(fromUserId); len(tags) != 0 {
+		t.Fatalf("Expected no tags for sender without virtual machines, got %v", tags)
+	}
+	db.QueryRow("SELECT COUNT(*) FROM actions WHERE user_id IN (?, ?) AND name LIKE 'Transfer VM%'", fromUserId, toUserId).Scan(&count)
+	if count != 2 {
+		t.Fatalf("Expected transfer to be logged for both users, got %d", count)
+	}
+}
@@ -22,11 +22,15 @@ type Cloug struct {
 	imageService   compute.ImageService
 	addressService compute.AddressService
 	flavorService  compute.FlavorService
+	volumeService  compute.VolumeService
 }
 
 type Config struct {
 	Region    string `json:"region"`
 	NetworkID string `json:"network_id"`
+
+	// whether the provider accepts user-data, which is passed in the instance details
+	UserData bool `json:"user_data"`
 }
 
 func MakeCloug(jsonData []byte, region string) (*Cloug, error) {
@@ -50,6 +54,7 @@ func MakeCloug(jsonData []byte, region string) (*Cloug, error) {
 	cloug.imageService, _ = cloug.service.(compute.ImageService)
 	cloug.addressService, _ = cloug.service.(compute.AddressService)
 	cloug.flavorService, _ = cloug.service.(compute.FlavorService)
+	cloug.volumeService, _ = cloug.service.(compute.VolumeService)
 
 	return cloug, nil
 }
@@ -69,9 +74,10 @@ func (cloug *Cloug) VmCreate(vm *lobster.VirtualMachine, options *lobster.VMIVmC
 		},
 	}
 
-	if options.SSHKey.Key != "" {
+	// cloug instances only take a single public key, see KeyLimit
+	if len(options.SSHKeys) > 0 {
 		tmpl.PublicKey = compute.PublicKey{
-			Key: []byte(options.SSHKey.Key),
+			Key: []byte(options.SSHKeys[0].Key),
 		}
 	}
 
@@ -79,6 +85,9 @@ func (cloug *Cloug) VmCreate(vm *lobster.VirtualMachine, options *lobster.VMIVmC
 	for k, v := range vm.Plan.Metadata {
 		tmpl.Details[k] = v
 	}
+	if options.UserData != "" {
+		tmpl.Details["user_data"] = options.UserData
+	}
 
 	instance, err := cloug.service.CreateInstance(&tmpl)
 
@@ -96,6 +105,10 @@ func (cloug *Cloug) VmCreate(vm *lobster.VirtualMachine, options *lobster.VMIVmC
 	return instance.ID, nil
 }
 
+func (cloug *Cloug) CanUserData() bool {
+	return cloug.config.UserData
+}
+
 func (cloug *Cloug) VmDelete(vm *lobster.VirtualMachine) error {
 	return cloug.service.DeleteInstance(vm.Identification)
 }
@@ -129,6 +142,7 @@ func (cloug *Cloug) VmInfo(vm *lobster.VirtualMachine) (*lobster.VmInfo, error)
 		CanResize:            cloug.resizeService != nil,
 		CanSnapshot:          cloug.imageService != nil,
 		CanAddresses:         cloug.addressService != nil,
+		CanVolumes:           cloug.volumeService != nil,
 	}
 
 	for _, action := range instance.Actions {
@@ -143,6 +157,22 @@ func (cloug *Cloug) VmInfo(vm *lobster.VirtualMachine) (*lobster.VmInfo, error)
 	return info, nil
 }
 
+func (cloug *Cloug) VmList() ([]*lobster.VirtualMachine, error) {
+	instances, err := cloug.service.ListInstances()
+	if err != nil {
+		return nil, err
+	}
+	var vms []*lobster.VirtualMachine
+	for _, instance := range instances {
+		vms = append(vms, &lobster.VirtualMachine{
+			Name:           instance.Name,
+			Identification: instance.ID,
+			ExternalIP:     instance.IP,
+		})
+	}
+	return vms, nil
+}
+
 func (cloug *Cloug) VmStart(vm *lobster.VirtualMachine) error {
 	return cloug.service.StartInstance(vm.Identification)
 }
@@ -213,11 +243,20 @@ func (cloug *Cloug) CanRename() bool {
 	return cloug.renameService != nil
 }
 
-func (cloug *Cloug) VmReimage(vm *lobster.VirtualMachine, imageIdentification string) error {
+// The public key is set separately from the user_data detail.
+func (cloug *Cloug) CanUserDataKeys() bool {
+	return true
+}
+
+func (cloug *Cloug) KeyLimit() int {
+	return 1
+}
+
+func (cloug *Cloug) VmReimage(vm *lobster.VirtualMachine, options *lobster.VMIReimageOptions) error {
 	if cloug.reimageService == nil {
 		return fmt.Errorf("operation not supported")
 	}
-	return cloug.reimageService.ReimageInstance(vm.Identification, &compute.Image{ID: imageIdentification})
+	return cloug.reimageService.ReimageInstance(vm.Identification, &compute.Image{ID: options.ImageIdentification})
 }
 
 func (cloug *Cloug) VmResize(vm *lobster.VirtualMachine, plan *lobster.Plan) error {
@@ -314,6 +353,70 @@ func (cloug *Cloug) VmSetRdns(vm *lobster.VirtualMachine, ip string, hostname st
 	return fmt.Errorf("specified IP addresses not found on instance")
 }
 
+func (cloug *Cloug) VolumeCreate(volume *lobster.Volume) (string, error) {
+	if cloug.volumeService == nil {
+		return "", fmt.Errorf("operation not supported")
+	}
+	apiVolume, err := cloug.volumeService.CreateVolume(&compute.Volume{
+		Name:   volume.Name,
+		Region: cloug.config.Region,
+		Size:   volume.Size,
+	})
+	if err != nil {
+		return "", err
+	} else {
+		return apiVolume.ID, nil
+	}
+}
+
+func (cloug *Cloug) VolumeDelete(volume *lobster.Volume) error {
+	if cloug.volumeService == nil {
+		return fmt.Errorf("operation not supported")
+	}
+	return cloug.volumeService.DeleteVolume(volume.Identification)
+}
+
+func (cloug *Cloug) VolumeInfo(volume *lobster.Volume) (*lobster.VolumeInfo, error) {
+	if cloug.volumeService == nil {
+		return nil, fmt.Errorf("operation not supported")
+	}
+	apiVolume, err := cloug.volumeService.GetVolume(volume.Identification)
+	if err != nil {
+		return nil, err
+	}
+
+	info := new(lobster.VolumeInfo)
+	if apiVolume.Status == compute.VolumeAvailable || apiVolume.Status == compute.VolumeInUse {
+		info.Status = lobster.VolumeActive
+	} else if apiVolume.Status == compute.VolumeError {
+		info.Status = lobster.VolumeError
+	} else {
+		info.Status = lobster.VolumePending
+	}
+	return info, nil
+}
+
+func (cloug *Cloug) VolumeResize(volume *lobster.Volume, size int) error {
+	if cloug.volumeService == nil {
+		return fmt.Errorf("operation not supported")
+	}
+	return cloug.volumeService.ResizeVolume(volume.Identification, size)
+}
+
+func (cloug *Cloug) VolumeAttach(vm *lobster.VirtualMachine, volume *lobster.Volume) error {
+	if cloug.volumeService == nil {
+		return fmt.Errorf("operation not supported")
+	}
+	return cloug.volumeService.AddVolume(vm.Identification, volume.Identification)
+}
+
+func (cloug *Cloug) VolumeDetach(vm *lobster.VirtualMachine, volume *lobster.Volume) error {
+	if cloug.volumeService == nil {
+		return fmt.Errorf("operation not supported")
+	}
+	return cloug.volumeService.RemoveVolume(vm.Identification, volume.Identification)
+}
+
 func (cloug *Cloug) BandwidthAccounting(vm *lobster.VirtualMachine) int64 {
 	info, err := cloug.VmInfo(vm)
 	if err == nil {
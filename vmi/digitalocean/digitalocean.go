@@ -90,6 +90,18 @@ func (this *DigitalOcean) VmCreate(vm *lobster.VirtualMachine, options *lobster.
 		PrivateNetworking: true,
 		UserData:          fmt.Sprintf("#cloud-config\nchpasswd:\n list: |\n  root:%s\n expire: False\n", password),
 	}
+	if len(options.SSHKeys) > 0 {
+		createRequest.UserData += "ssh_authorized_keys:\n"
+		for _, key := range options.SSHKeys {
+			createRequest.UserData += " - " + key.Key + "\n"
+		}
+	}
+	if options.UserData != "" {
+		// user-provided user-data replaces our cloud-config, so the password and keys will not be set;
+		// lobster refuses keys with user-data since this does not implement VMIUserDataKeys
+		createRequest.UserData = options.UserData
+		password = "unknown"
+	}
 	droplet, _, err := this.client.Droplets.Create(createRequest)
 	if err != nil {
 		return "", err
@@ -99,6 +111,10 @@ func (this *DigitalOcean) VmCreate(vm *lobster.VirtualMachine, options *lobster.
 	}
 }
 
+func (this *DigitalOcean) CanUserData() bool {
+	return true
+}
+
 func (this *DigitalOcean) VmDelete(vm *lobster.VirtualMachine) error {
 	vmIdentification, _ := strconv.Atoi(vm.Identification)
 	_, err := this.client.Droplets.Delete(vmIdentification)
@@ -197,9 +213,9 @@ func (this *DigitalOcean) VmRename(vm *lobster.VirtualMachine, name string) erro
 	}
 }
 
-func (this *DigitalOcean) VmReimage(vm *lobster.VirtualMachine, imageIdentification string) error {
+func (this *DigitalOcean) VmReimage(vm *lobster.VirtualMachine, options *lobster.VMIReimageOptions) error {
 	vmIdentification, _ := strconv.Atoi(vm.Identification)
-	action, _, err := this.client.DropletActions.RebuildByImageSlug(vmIdentification, imageIdentification)
+	action, _, err := this.client.DropletActions.RebuildByImageSlug(vmIdentification, options.ImageIdentification)
 	if err != nil {
 		return err
 	} else {
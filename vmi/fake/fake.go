@@ -4,8 +4,11 @@ import "github.com/LunaNode/lobster"
 
 import "errors"
 import "fmt"
+import "math"
 import "math/rand"
+import "strconv"
 import "strings"
+import "time"
 
 type Fake struct {
 	Bandwidth int64 // returned on BandwidthAccounting
@@ -25,6 +28,10 @@ func (this *Fake) VmCreate(vm *lobster.VirtualMachine, options *lobster.VMIVmCre
 	return "fake", nil
 }
 
+func (this *Fake) CanUserData() bool {
+	return true
+}
+
 func (this *Fake) VmDelete(vm *lobster.VirtualMachine) error {
 	this.CountDelete++
 	return nil
@@ -37,6 +44,10 @@ func (this *Fake) VmInfo(vm *lobster.VirtualMachine) (*lobster.VmInfo, error) {
 		LoginDetails: "fingerprint login supported",
 	}
 
+	if vm.Metadata("rescue", "") != "" {
+		info.Status = "Rescue"
+	}
+
 	addresses, _ := this.VmAddresses(vm)
 	if len(addresses) > 0 {
 		info.Ip = addresses[0].Ip
@@ -69,18 +80,81 @@ func (this *Fake) CanVnc() bool {
 	return true
 }
 
+// Returns the console output stored in the console metadata, or a short boot log if it is not set.
+func (this *Fake) VmConsoleOutput(vm *lobster.VirtualMachine, length int) (string, error) {
+	output := vm.Metadata("console", fmt.Sprintf("Booting fake virtual machine %s\nfake login: ", vm.Name))
+	if len(output) > length {
+		output = output[len(output)-length:]
+	}
+	return output, nil
+}
+
+// Generates one sample per minute of synthetic usage that follows a daily cycle, offset by the VM ID.
+func (this *Fake) VmMetrics(vm *lobster.VirtualMachine, since time.Time) ([]*lobster.MetricSample, error) {
+	now := time.Now()
+	if since.Before(now.Add(-48 * time.Hour)) {
+		since = now.Add(-48 * time.Hour)
+	}
+	var samples []*lobster.MetricSample
+	for t := since.Truncate(time.Minute); t.Before(now); t = t.Add(time.Minute) {
+		phase := float64(t.Unix()%86400)/86400*2*math.Pi + float64(vm.Id)
+		load := (math.Sin(phase) + 1) / 2
+		samples = append(samples, &lobster.MetricSample{
+			Time:       t,
+			Cpu:        5 + 60*load + 10*rand.Float64(),
+			DiskRead:   (200 + 800*load) * 1024,
+			DiskWrite:  (100 + 400*load*rand.Float64()) * 1024,
+			NetworkIn:  (50 + 500*load) * 1024,
+			NetworkOut: (100 + 1500*load*rand.Float64()) * 1024,
+		})
+	}
+	return samples, nil
+}
+
 func (this *Fake) VmAction(vm *lobster.VirtualMachine, action string, value string) error {
 	return errors.New("operation not supported")
 }
 
+func (this *Fake) VmRescue(vm *lobster.VirtualMachine) (string, error) {
+	return fmt.Sprintf("rescue%d", rand.Int31()), nil
+}
+
+func (this *Fake) VmUnrescue(vm *lobster.VirtualMachine) error {
+	return nil
+}
+
+func (this *Fake) VmMountISO(vm *lobster.VirtualMachine, imageIdentification string) error {
+	return nil
+}
+
+func (this *Fake) VmUnmountISO(vm *lobster.VirtualMachine) error {
+	return nil
+}
+
+func (this *Fake) VmResetPassword(vm *lobster.VirtualMachine) (string, error) {
+	return fmt.Sprintf("fake%d", rand.Int31()), nil
+}
+
 func (this *Fake) VmRename(vm *lobster.VirtualMachine, name string) error {
 	return nil
 }
 
-func (this *Fake) VmReimage(vm *lobster.VirtualMachine, imageIdentification string) error {
+func (this *Fake) VmReimage(vm *lobster.VirtualMachine, options *lobster.VMIReimageOptions) error {
 	return nil
 }
 
+func (this *Fake) CanUserDataKeys() bool {
+	return true
+}
+
+func (this *Fake) CanReimageUserData() bool {
+	return true
+}
+
+func (this *Fake) CanReimageKeys() bool {
+	return true
+}
+
 func (this *Fake) VmSnapshot(vm *lobster.VirtualMachine) (string, error) {
 	return "fake", nil
 }
@@ -94,14 +168,15 @@ func (this *Fake) VmAddresses(vm *lobster.VirtualMachine) ([]*lobster.IpAddress,
 	for _, addrString := range strings.Split(vm.Metadata("addresses", ""), ",") {
 		addrString = strings.TrimSpace(addrString)
 		if addrString != "" {
-			parts := strings.Split(addrString, ":")
+			// hostname follows the last colon, since IPv6 addresses contain colons
 			ipAddr := &lobster.IpAddress{
-				Ip:        parts[0],
+				Ip:        addrString,
 				PrivateIp: "255.255.255.255",
 				CanRdns:   true,
 			}
-			if len(parts) > 1 {
-				ipAddr.Hostname = parts[1]
+			if idx := strings.LastIndex(addrString, ":"); idx != -1 {
+				ipAddr.Ip = addrString[:idx]
+				ipAddr.Hostname = addrString[idx+1:]
 			}
 			addresses = append(addresses, ipAddr)
 		}
@@ -122,7 +197,12 @@ func (this *Fake) saveAddresses(vm *lobster.VirtualMachine, addresses []*lobster
 
 func (this *Fake) VmAddAddress(vm *lobster.VirtualMachine) error {
 	addresses, _ := this.VmAddresses(vm)
-	addresses = append(addresses, &lobster.IpAddress{Ip: "127.0.0." + fmt.Sprintf("%d", rand.Int31n(255)+1)})
+	// alternate between IPv4 and IPv6 addresses
+	if len(addresses)%2 == 0 {
+		addresses = append(addresses, &lobster.IpAddress{Ip: "127.0.0." + fmt.Sprintf("%d", rand.Int31n(255)+1)})
+	} else {
+		addresses = append(addresses, &lobster.IpAddress{Ip: fmt.Sprintf("2001:db8::%x", rand.Int31n(65535)+1)})
+	}
 	this.saveAddresses(vm, addresses)
 	return nil
 }
@@ -150,6 +230,129 @@ func (this *Fake) VmSetRdns(vm *lobster.VirtualMachine, ip string, hostname stri
 	return nil
 }
 
+func (this *Fake) VmFirewallRules(vm *lobster.VirtualMachine) ([]*lobster.FirewallRule, error) {
+	rules := make([]*lobster.FirewallRule, 0)
+	for _, ruleString := range strings.Split(vm.Metadata("firewall", ""), ",") {
+		parts := strings.Split(strings.TrimSpace(ruleString), " ")
+		if len(parts) != 5 {
+			continue
+		}
+		rule := &lobster.FirewallRule{
+			Id:       parts[0],
+			Protocol: parts[1],
+			Cidr:     parts[4],
+		}
+		rule.PortMin, _ = strconv.Atoi(parts[2])
+		rule.PortMax, _ = strconv.Atoi(parts[3])
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func (this *Fake) saveFirewallRules(vm *lobster.VirtualMachine, rules []*lobster.FirewallRule) {
+	str := ""
+	for _, rule := range rules {
+		if str != "" {
+			str += ","
+		}
+		str += fmt.Sprintf("%s %s %d %d %s", rule.Id, rule.Protocol, rule.PortMin, rule.PortMax, rule.Cidr)
+	}
+	vm.SetMetadata("firewall", str)
+}
+
+func (this *Fake) VmFirewallAdd(vm *lobster.VirtualMachine, rule *lobster.FirewallRule) error {
+	rules, err := this.VmFirewallRules(vm)
+	if err != nil {
+		return err
+	}
+	nextId := 1
+	for _, existing := range rules {
+		id, _ := strconv.Atoi(existing.Id)
+		if id >= nextId {
+			nextId = id + 1
+		}
+	}
+	rule.Id = strconv.Itoa(nextId)
+	this.saveFirewallRules(vm, append(rules, rule))
+	return nil
+}
+
+func (this *Fake) VmFirewallRemove(vm *lobster.VirtualMachine, ruleId string) error {
+	rules, err := this.VmFirewallRules(vm)
+	if err != nil {
+		return err
+	}
+	var newRules []*lobster.FirewallRule
+	for _, rule := range rules {
+		if rule.Id != ruleId {
+			newRules = append(newRules, rule)
+		}
+	}
+	if len(newRules) == len(rules) {
+		return errors.New("rule not found")
+	}
+	this.saveFirewallRules(vm, newRules)
+	return nil
+}
+
+func (this *Fake) VolumeCreate(volume *lobster.Volume) (string, error) {
+	return "fake", nil
+}
+
+func (this *Fake) VolumeDelete(volume *lobster.Volume) error {
+	return nil
+}
+
+func (this *Fake) VolumeInfo(volume *lobster.Volume) (*lobster.VolumeInfo, error) {
+	return &lobster.VolumeInfo{
+		Status: lobster.VolumeActive,
+	}, nil
+}
+
+func (this *Fake) VolumeResize(volume *lobster.Volume, size int) error {
+	return nil
+}
+
+func (this *Fake) VolumeAttach(vm *lobster.VirtualMachine, volume *lobster.Volume) error {
+	return nil
+}
+
+func (this *Fake) VolumeDetach(vm *lobster.VirtualMachine, volume *lobster.Volume) error {
+	return nil
+}
+
+func (this *Fake) FloatingIPAllocate() (string, string, error) {
+	return "fake", "127.0.0.2", nil
+}
+
+func (this *Fake) FloatingIPRelease(identification string) error {
+	return nil
+}
+
+func (this *Fake) FloatingIPAttach(vm *lobster.VirtualMachine, identification string) error {
+	return nil
+}
+
+func (this *Fake) FloatingIPDetach(vm *lobster.VirtualMachine, identification string) error {
+	return nil
+}
+
+func (this *Fake) NetworkCreate(network *lobster.Network) (string, error) {
+	return "fake", nil
+}
+
+func (this *Fake) NetworkDelete(network *lobster.Network) error {
+	return nil
+}
+
+func (this *Fake) NetworkAttach(vm *lobster.VirtualMachine, network *lobster.Network) error {
+	return nil
+}
+
+func (this *Fake) NetworkDetach(vm *lobster.VirtualMachine, network *lobster.Network) error {
+	return nil
+}
+
 func (this *Fake) BandwidthAccounting(vm *lobster.VirtualMachine) int64 {
 	return this.Bandwidth
 }
@@ -165,6 +368,10 @@ func (this *Fake) ImageInfo(imageIdentification string) (*lobster.ImageInfo, err
 	}, nil
 }
 
+func (this *Fake) ImageExport(imageIdentification string) (string, error) {
+	return "https://example.com/fake/" + imageIdentification, nil
+}
+
 func (this *Fake) ImageDelete(imageIdentification string) error {
 	return nil
 }
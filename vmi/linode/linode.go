@@ -52,6 +52,11 @@ func (this *Linode) findKernel() (int, error) {
 	return 0, errors.New("no kernel found")
 }
 
+// The Linode v3 API that we use does not support passing user-data to new instances.
+func (this *Linode) CanUserData() bool {
+	return false
+}
+
 func (this *Linode) VmCreate(vm *lobster.VirtualMachine, options *lobster.VMIVmCreateOptions) (string, error) {
 	var planID int
 	if vm.Plan.Identification != "" {
@@ -68,6 +73,10 @@ func (this *Linode) VmCreate(vm *lobster.VirtualMachine, options *lobster.VMIVmC
 		return "", err
 	}
 	password := utils.Uid(16)
+	var rootSSHKeys []string
+	for _, key := range options.SSHKeys {
+		rootSSHKeys = append(rootSSHKeys, key.Key)
+	}
 
 	// create linode
 	linodeID, err := this.client.CreateLinode(this.datacenterID, planID)
@@ -87,14 +96,14 @@ func (this *Linode) VmCreate(vm *lobster.VirtualMachine, options *lobster.VMIVmC
 	}
 	if imageParts[0] == "distribution" {
 		distributionID, _ := strconv.Atoi(imageParts[1])
-		diskID, _, err = this.client.CreateDiskFromDistribution(linodeID, "lobster", distributionID, diskSize, password, "")
+		diskID, _, err = this.client.CreateDiskFromDistribution(linodeID, "lobster", distributionID, diskSize, password, strings.Join(rootSSHKeys, "\n"))
 		if err != nil {
 			this.client.DeleteLinode(linodeID, false)
 			return "", err
 		}
 	} else if imageParts[0] == "image" {
 		imageID, _ := strconv.Atoi(imageParts[1])
-		diskID, _, err = this.client.CreateDiskFromImage(linodeID, "lobster", imageID, diskSize, password, "")
+		diskID, _, err = this.client.CreateDiskFromImage(linodeID, "lobster", imageID, diskSize, password, strings.Join(rootSSHKeys, "\n"))
 		if err != nil {
 			this.client.DeleteLinode(linodeID, false)
 			return "", err
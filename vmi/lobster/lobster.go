@@ -42,6 +42,26 @@ func (this *Lobster) findMatchingPlan(ram int, storage int, cpu int) (*api.Plan,
 	return nil, nil
 }
 
+// Adds the keys to the remote account so that they can be referenced when creating or re-imaging.
+// The returned IDs should be passed to removeTemporaryKeys, even if there is an error.
+func (this *Lobster) addTemporaryKeys(keys []lobster.SSHKey) ([]int, error) {
+	var keyIds []int
+	for _, key := range keys {
+		keyId, err := this.client.KeyAdd(fmt.Sprintf("lobstertmp-%d", key.ID), key.Key)
+		if err != nil {
+			return keyIds, fmt.Errorf("failed to add public key: %v", err)
+		}
+		keyIds = append(keyIds, keyId)
+	}
+	return keyIds, nil
+}
+
+func (this *Lobster) removeTemporaryKeys(keyIds []int) {
+	for _, keyId := range keyIds {
+		this.client.KeyRemove(keyId)
+	}
+}
+
 func (this *Lobster) VmCreate(vm *lobster.VirtualMachine, options *lobster.VMIVmCreateOptions) (string, error) {
 	var plan int
 	if vm.Plan.Identification != "" {
@@ -58,20 +78,24 @@ func (this *Lobster) VmCreate(vm *lobster.VirtualMachine, options *lobster.VMIVm
 
 	imageId, _ := strconv.Atoi(options.ImageIdentification)
 
-	var clientOptions api.VmCreateOptions
-	if options.SSHKey.Key != "" {
-		keyId, err := this.client.KeyAdd("lobstertmp", options.SSHKey.Key)
-		if err != nil {
-			return "", fmt.Errorf("failed to add public key: %v", err)
-		}
-		defer this.client.KeyRemove(keyId)
-		clientOptions.KeyId = keyId
+	clientOptions := api.VmCreateOptions{
+		UserData: options.UserData,
 	}
+	keyIds, err := this.addTemporaryKeys(options.SSHKeys)
+	defer this.removeTemporaryKeys(keyIds)
+	if err != nil {
+		return "", err
+	}
+	clientOptions.KeyIds = keyIds
 
 	vmId, err := this.client.VmCreate(vm.Name, plan, imageId, &clientOptions)
 	return fmt.Sprintf("%d", vmId), err
 }
 
+func (this *Lobster) CanUserData() bool {
+	return true
+}
+
 func (this *Lobster) VmDelete(vm *lobster.VirtualMachine) error {
 	vmIdentification, _ := strconv.Atoi(vm.Identification)
 	return this.client.VmDelete(vmIdentification)
@@ -113,6 +137,24 @@ func (this *Lobster) VmInfo(vm *lobster.VirtualMachine) (*lobster.VmInfo, error)
 	return &info, nil
 }
 
+func (this *Lobster) VmList() ([]*lobster.VirtualMachine, error) {
+	apiVms, err := this.client.VmList()
+	if err != nil {
+		return nil, err
+	}
+	var vms []*lobster.VirtualMachine
+	for _, apiVm := range apiVms {
+		if apiVm.Region == this.region {
+			vms = append(vms, &lobster.VirtualMachine{
+				Name:           apiVm.Name,
+				Identification: fmt.Sprintf("%d", apiVm.Id),
+				ExternalIP:     apiVm.ExternalIP,
+			})
+		}
+	}
+	return vms, nil
+}
+
 func (this *Lobster) VmStart(vm *lobster.VirtualMachine) error {
 	vmIdentification, _ := strconv.Atoi(vm.Identification)
 	return this.client.VmAction(vmIdentification, "start", "")
@@ -138,6 +180,32 @@ func (this *Lobster) VmAction(vm *lobster.VirtualMachine, action string, value s
 	return this.client.VmAction(vmIdentification, action, value)
 }
 
+func (this *Lobster) VmRescue(vm *lobster.VirtualMachine) (string, error) {
+	vmIdentification, _ := strconv.Atoi(vm.Identification)
+	return this.client.VmRescue(vmIdentification)
+}
+
+func (this *Lobster) VmUnrescue(vm *lobster.VirtualMachine) error {
+	vmIdentification, _ := strconv.Atoi(vm.Identification)
+	return this.client.VmUnrescue(vmIdentification)
+}
+
+func (this *Lobster) VmMountISO(vm *lobster.VirtualMachine, imageIdentification string) error {
+	vmIdentification, _ := strconv.Atoi(vm.Identification)
+	imageIdentificationInt, _ := strconv.Atoi(imageIdentification)
+	return this.client.VmMountISO(vmIdentification, imageIdentificationInt)
+}
+
+func (this *Lobster) VmUnmountISO(vm *lobster.VirtualMachine) error {
+	vmIdentification, _ := strconv.Atoi(vm.Identification)
+	return this.client.VmUnmountISO(vmIdentification)
+}
+
+func (this *Lobster) VmResetPassword(vm *lobster.VirtualMachine) (string, error) {
+	vmIdentification, _ := strconv.Atoi(vm.Identification)
+	return this.client.VmPasswordReset(vmIdentification)
+}
+
 func (this *Lobster) VmRename(vm *lobster.VirtualMachine, name string) error {
 	vmIdentification, _ := strconv.Atoi(vm.Identification)
 	return this.client.VmAction(vmIdentification, "rename", name)
@@ -147,10 +215,32 @@ func (this *Lobster) CanRename() bool {
 	return true
 }
 
-func (this *Lobster) VmReimage(vm *lobster.VirtualMachine, imageIdentification string) error {
+func (this *Lobster) VmReimage(vm *lobster.VirtualMachine, options *lobster.VMIReimageOptions) error {
 	vmIdentification, _ := strconv.Atoi(vm.Identification)
-	imageIdentificationInt, _ := strconv.Atoi(imageIdentification)
-	return this.client.VmReimage(vmIdentification, imageIdentificationInt)
+	imageIdentificationInt, _ := strconv.Atoi(options.ImageIdentification)
+
+	keyIds, err := this.addTemporaryKeys(options.SSHKeys)
+	defer this.removeTemporaryKeys(keyIds)
+	if err != nil {
+		return err
+	}
+
+	return this.client.VmReimage(vmIdentification, imageIdentificationInt, &api.VmReimageOptions{
+		KeyIds:   keyIds,
+		UserData: options.UserData,
+	})
+}
+
+func (this *Lobster) CanUserDataKeys() bool {
+	return true
+}
+
+func (this *Lobster) CanReimageUserData() bool {
+	return true
+}
+
+func (this *Lobster) CanReimageKeys() bool {
+	return true
 }
 
 func (this *Lobster) VmResize(vm *lobster.VirtualMachine, plan *lobster.Plan) error {
@@ -182,6 +272,7 @@ func (this *Lobster) VmAddresses(vm *lobster.VirtualMachine) ([]*lobster.IpAddre
 		dstAddress := new(lobster.IpAddress)
 		dstAddress.Ip = srcAddress.Ip
 		dstAddress.PrivateIp = srcAddress.PrivateIp
+		dstAddress.Family = srcAddress.Family
 		dstAddress.CanRdns = srcAddress.CanRdns
 		dstAddress.Hostname = srcAddress.Hostname
 		addresses = append(addresses, dstAddress)
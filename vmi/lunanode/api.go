@@ -136,12 +136,17 @@ func (this *API) VmCreateVolume(region string, hostname string, planIdentificati
 		}
 	}
 }
-func (this *API) VmCreateImage(region string, hostname string, planIdentification int, imageIdentification int) (int, error) {
+
+// scriptIdentification is an optional startup script to provide as user-data, or zero for none.
+func (this *API) VmCreateImage(region string, hostname string, planIdentification int, imageIdentification int, scriptIdentification int) (int, error) {
 	params := make(map[string]string)
 	params["hostname"] = hostname
 	params["region"] = region
 	params["plan_id"] = fmt.Sprintf("%d", planIdentification)
 	params["image_id"] = fmt.Sprintf("%d", imageIdentification)
+	if scriptIdentification != 0 {
+		params["scripts"] = fmt.Sprintf("%d", scriptIdentification)
+	}
 	var response APIVmCreateResponse
 	err := this.request("vm", "create", params, &response)
 	if err != nil {
@@ -293,6 +298,28 @@ func (this *API) ImageList(region string) ([]*APIImage, error) {
 	}
 }
 
+// startup scripts
+
+func (this *API) ScriptCreate(name string, content string) (int, error) {
+	params := make(map[string]string)
+	params["name"] = name
+	params["content"] = content
+	var response APIScriptCreateResponse
+	err := this.request("script", "create", params, &response)
+	if err != nil {
+		return 0, err
+	} else {
+		return strconv.Atoi(response.Id)
+	}
+}
+
+func (this *API) ScriptDelete(scriptIdentification int) error {
+	params := make(map[string]string)
+	params["script_id"] = fmt.Sprintf("%d", scriptIdentification)
+	var response APIGenericResponse
+	return this.request("script", "delete", params, &response)
+}
+
 // volumes
 
 // Create a volume with the given size in gigabytes and image identification.
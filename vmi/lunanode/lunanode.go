@@ -16,16 +16,15 @@ type LunaNode struct {
 	vmBandwidth map[string]int64 // for bandwidth accounting
 }
 
-func MakeLunaNode(region string, apiId string, apiKey string) *LunaNode {
+func MakeLunaNode(region string, apiId string, apiKey string) (*LunaNode, error) {
 	this := new(LunaNode)
 	this.region = region
 	api, err := MakeAPI(apiId, apiKey)
 	if err != nil {
-		panic(err)
-	} else {
-		this.api = api
+		return nil, err
 	}
-	return this
+	this.api = api
+	return this, nil
 }
 
 func (this *LunaNode) VmCreate(vm *lobster.VirtualMachine, options *lobster.VMIVmCreateOptions) (string, error) {
@@ -56,11 +55,26 @@ func (this *LunaNode) VmCreate(vm *lobster.VirtualMachine, options *lobster.VMIV
 		planIdentification, _ = strconv.Atoi(matchPlan.Id)
 	}
 
+	// user-data is provided through a startup script, which is only needed until the VM is created
+	var scriptIdentification int
+	if options.UserData != "" {
+		var err error
+		scriptIdentification, err = this.api.ScriptCreate("lobster-"+vm.Name, options.UserData)
+		if err != nil {
+			return "", fmt.Errorf("failed to create startup script: %v", err)
+		}
+		defer this.api.ScriptDelete(scriptIdentification)
+	}
+
 	imageIdentificationInt, _ := strconv.Atoi(options.ImageIdentification)
-	vmId, err := this.api.VmCreateImage(this.region, vm.Name, planIdentification, imageIdentificationInt)
+	vmId, err := this.api.VmCreateImage(this.region, vm.Name, planIdentification, imageIdentificationInt, scriptIdentification)
 	return fmt.Sprintf("%d", vmId), err
 }
 
+func (this *LunaNode) CanUserData() bool {
+	return true
+}
+
 func (this *LunaNode) VmDelete(vm *lobster.VirtualMachine) error {
 	vmIdentificationInt, _ := strconv.Atoi(vm.Identification)
 	return this.api.VmDelete(vmIdentificationInt)
@@ -109,9 +123,9 @@ func (this *LunaNode) VmAction(vm *lobster.VirtualMachine, action string, value
 	return errors.New("operation not supported")
 }
 
-func (this *LunaNode) VmReimage(vm *lobster.VirtualMachine, imageIdentification string) error {
+func (this *LunaNode) VmReimage(vm *lobster.VirtualMachine, options *lobster.VMIReimageOptions) error {
 	vmIdentificationInt, _ := strconv.Atoi(vm.Identification)
-	imageIdentificationInt, _ := strconv.Atoi(imageIdentification)
+	imageIdentificationInt, _ := strconv.Atoi(options.ImageIdentification)
 	return this.api.VmReimage(vmIdentificationInt, imageIdentificationInt)
 }
 
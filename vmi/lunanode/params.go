@@ -71,6 +71,12 @@ type APIVolumeInfoResponse struct {
 	Volume *APIVolume `json:"volume"`
 }
 
+// startup scripts
+
+type APIScriptCreateResponse struct {
+	Id string `json:"script_id"`
+}
+
 // plans
 
 type APIPlan struct {
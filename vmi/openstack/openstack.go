@@ -14,6 +14,11 @@ import "github.com/LunaNode/gophercloud/openstack/compute/v2/flavors"
 import "github.com/LunaNode/gophercloud/openstack/compute/v2/servers"
 import "github.com/LunaNode/gophercloud/openstack/compute/v2/extensions/startstop"
 import "github.com/LunaNode/gophercloud/openstack/compute/v2/extensions/floatingip"
+import "github.com/LunaNode/gophercloud/openstack/compute/v2/extensions/secgroups"
+import "github.com/LunaNode/gophercloud/openstack/compute/v2/extensions/volumeattach"
+import "github.com/LunaNode/gophercloud/openstack/blockstorage/v1/volumes"
+import "github.com/LunaNode/gophercloud/openstack/networking/v2/networks"
+import "github.com/LunaNode/gophercloud/openstack/networking/v2/subnets"
 import "github.com/LunaNode/gophercloud/openstack/image/v1/image"
 
 import "errors"
@@ -25,10 +30,16 @@ import "time"
 type OpenStack struct {
 	ComputeClient *gophercloud.ServiceClient
 	ImageClient   *gophercloud.ServiceClient
+	VolumeClient  *gophercloud.ServiceClient // nil if block storage is not available
+	NetworkClient *gophercloud.ServiceClient // nil if networking is not available
 	networkId     string
+
+	// Floating IP pool that reserved IPs are allocated from.
+	// Addresses in this pool are never assigned to new virtual machines; reserved IPs are disabled if empty.
+	ReservedIpPool string
 }
 
-func MakeOpenStack(identityEndpoint string, username string, password string, tenantName string, networkId string) *OpenStack {
+func MakeOpenStack(identityEndpoint string, username string, password string, tenantName string, networkId string) (*OpenStack, error) {
 	this := new(OpenStack)
 	this.networkId = networkId
 	opts := gophercloud.AuthOptions{
@@ -39,17 +50,27 @@ func MakeOpenStack(identityEndpoint string, username string, password string, te
 	}
 	provider, err := openstack.AuthenticatedClient(opts)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	this.ComputeClient, err = openstack.NewComputeV2(provider, gophercloud.EndpointOpts{})
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	this.ImageClient, err = openstack.NewImageV1(provider, gophercloud.EndpointOpts{})
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+	this.VolumeClient, err = openstack.NewBlockStorageV1(provider, gophercloud.EndpointOpts{})
+	if err != nil {
+		log.Printf("OpenStack: block storage not available, volumes are disabled: %s", err.Error())
+		this.VolumeClient = nil
+	}
+	this.NetworkClient, err = openstack.NewNetworkV2(provider, gophercloud.EndpointOpts{})
+	if err != nil {
+		log.Printf("OpenStack: networking not available, private networks are disabled: %s", err.Error())
+		this.NetworkClient = nil
 	}
-	return this
+	return this, nil
 }
 
 func (this *OpenStack) VmCreate(vm *lobster.VirtualMachine, options *lobster.VMIVmCreateOptions) (string, error) {
@@ -84,13 +105,26 @@ func (this *OpenStack) VmCreate(vm *lobster.VirtualMachine, options *lobster.VMI
 	}
 
 	password := utils.Uid(16)
+	cloudConfig := "#cloud-config\npassword: " + password + "\nchpasswd: { expire: False }\nssh_pwauth: True\n"
+	if len(options.SSHKeys) > 0 {
+		cloudConfig += "ssh_authorized_keys:\n"
+		for _, key := range options.SSHKeys {
+			cloudConfig += "  - " + key.Key + "\n"
+		}
+	}
 	opts := servers.CreateOpts{
 		Name:      vm.Name,
 		ImageRef:  options.ImageIdentification,
 		FlavorRef: flavorID,
 		Networks:  []servers.Network{{UUID: this.networkId}},
 		AdminPass: password,
-		UserData:  []byte("#cloud-config\npassword: " + password + "\nchpasswd: { expire: False }\nssh_pwauth: True\n"),
+		UserData:  []byte(cloudConfig),
+	}
+	if options.UserData != "" {
+		// user-provided user-data replaces our cloud-config, so the password and keys may not be set;
+		// lobster refuses keys with user-data since this does not implement VMIUserDataKeys
+		opts.UserData = []byte(options.UserData)
+		password = "unknown"
 	}
 	createResult := servers.Create(this.ComputeClient, opts)
 	server, err := createResult.Extract()
@@ -113,7 +147,7 @@ func (this *OpenStack) VmCreate(vm *lobster.VirtualMachine, options *lobster.VMI
 				}
 
 				for _, floatingIp := range floatingIps {
-					if floatingIp.InstanceID == "" {
+					if floatingIp.InstanceID == "" && (this.ReservedIpPool == "" || floatingIp.Pool != this.ReservedIpPool) {
 						freeFloatingIp = &floatingIp
 						return false, nil
 					}
@@ -142,8 +176,36 @@ func (this *OpenStack) VmCreate(vm *lobster.VirtualMachine, options *lobster.VMI
 	return server.ID, nil
 }
 
+func (this *OpenStack) CanUserData() bool {
+	return true
+}
+
 func (this *OpenStack) VmDelete(vm *lobster.VirtualMachine) error {
-	return servers.Delete(this.ComputeClient, vm.Identification).ExtractErr()
+	group, err := this.firewallGroup(vm)
+	if err != nil {
+		log.Printf("OpenStack: error while looking up security group: %s", err.Error())
+	}
+
+	err = servers.Delete(this.ComputeClient, vm.Identification).ExtractErr()
+	if err != nil {
+		return err
+	}
+
+	// the security group cannot be deleted until the server is gone
+	if group != nil {
+		go func() {
+			for try := 0; try < 6; try++ {
+				time.Sleep(10 * time.Second)
+				err := secgroups.Delete(this.ComputeClient, group.ID).ExtractErr()
+				if err == nil {
+					break
+				} else {
+					log.Printf("OpenStack: error while deleting security group: %s", err.Error())
+				}
+			}
+		}()
+	}
+	return nil
 }
 
 func (this *OpenStack) VmInfo(vm *lobster.VirtualMachine) (*lobster.VmInfo, error) {
@@ -173,11 +235,7 @@ func (this *OpenStack) VmInfo(vm *lobster.VirtualMachine) (*lobster.VmInfo, erro
 
 		for _, networkAddresses := range addresses {
 			for _, addr := range networkAddresses {
-				if ipaddr.IsPrivate(addr.Address) {
-					info.PrivateIp = addr.Address
-				} else {
-					info.Ip = addr.Address
-				}
+				selectAddress(addr.Address, &info.Ip, &info.PrivateIp)
 			}
 		}
 		return true, nil
@@ -186,6 +244,63 @@ func (this *OpenStack) VmInfo(vm *lobster.VirtualMachine) (*lobster.VmInfo, erro
 	return &info, nil
 }
 
+// Sets the address as the external or private IP, preferring IPv4 addresses since the server may have both.
+func selectAddress(address string, ip *string, privateIp *string) {
+	if ipaddr.IsPrivate(address) {
+		if *privateIp == "" || ipaddr.Family(address) == "ipv4" {
+			*privateIp = address
+		}
+	} else if *ip == "" || ipaddr.Family(address) == "ipv4" {
+		*ip = address
+	}
+}
+
+// Returns the external IP from the addresses in the server details, which are keyed by network name.
+// AccessIPv4 is usually empty with Neutron, so the addresses are used instead.
+func serverExternalIP(server *servers.Server) string {
+	var ip, privateIp string
+	for _, networkAddresses := range server.Addresses {
+		addrList, ok := networkAddresses.([]interface{})
+		if !ok {
+			continue
+		}
+		for _, addrEntry := range addrList {
+			addrMap, ok := addrEntry.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if address, ok := addrMap["addr"].(string); ok {
+				selectAddress(address, &ip, &privateIp)
+			}
+		}
+	}
+	return ip
+}
+
+func (this *OpenStack) VmList() ([]*lobster.VirtualMachine, error) {
+	var vms []*lobster.VirtualMachine
+	err := servers.List(this.ComputeClient, servers.ListOpts{}).EachPage(func(page pagination.Page) (bool, error) {
+		serverList, err := servers.ExtractServers(page)
+		if err != nil {
+			return false, err
+		}
+
+		for i := range serverList {
+			server := &serverList[i]
+			vms = append(vms, &lobster.VirtualMachine{
+				Name:           server.Name,
+				Identification: server.ID,
+				ExternalIP:     serverExternalIP(server),
+			})
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return vms, nil
+}
+
 func (this *OpenStack) VmStart(vm *lobster.VirtualMachine) error {
 	return startstop.Start(this.ComputeClient, vm.Identification).ExtractErr()
 }
@@ -202,10 +317,98 @@ func (this *OpenStack) VmVnc(vm *lobster.VirtualMachine) (string, error) {
 	return servers.Vnc(this.ComputeClient, vm.Identification, servers.NoVnc).Extract()
 }
 
+func (this *OpenStack) VmConsoleOutput(vm *lobster.VirtualMachine, length int) (string, error) {
+	// os-getConsoleOutput limits output by lines rather than bytes, so request enough lines to fill length
+	request := map[string]interface{}{
+		"os-getConsoleOutput": map[string]interface{}{
+			"length": length / 16,
+		},
+	}
+	var response interface{}
+	_, err := this.ComputeClient.Request("POST", this.ComputeClient.ServiceURL("servers", vm.Identification, "action"), gophercloud.RequestOpts{
+		JSONBody:     request,
+		JSONResponse: &response,
+		OkCodes:      []int{200},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	responseMap, ok := response.(map[string]interface{})
+	if !ok {
+		return "", errors.New("unexpected console output response")
+	}
+	output, _ := responseMap["output"].(string)
+	if len(output) > length {
+		output = output[len(output)-length:]
+	}
+	return output, nil
+}
+
 func (this *OpenStack) VmAction(vm *lobster.VirtualMachine, action string, value string) error {
 	return errors.New("operation not supported")
 }
 
+func (this *OpenStack) serverAction(vm *lobster.VirtualMachine, request map[string]interface{}, okCode int) error {
+	_, err := this.ComputeClient.Request("POST", this.ComputeClient.ServiceURL("servers", vm.Identification, "action"), gophercloud.RequestOpts{
+		JSONBody: request,
+		OkCodes:  []int{okCode},
+	})
+	return err
+}
+
+// Nova generates the password of the rescue system and returns it as adminPass.
+func (this *OpenStack) VmRescue(vm *lobster.VirtualMachine) (string, error) {
+	var response struct {
+		AdminPass string `json:"adminPass"`
+	}
+	_, err := this.ComputeClient.Request("POST", this.ComputeClient.ServiceURL("servers", vm.Identification, "action"), gophercloud.RequestOpts{
+		JSONBody:     map[string]interface{}{"rescue": map[string]interface{}{}},
+		JSONResponse: &response,
+		OkCodes:      []int{200},
+	})
+	if err != nil {
+		return "", err
+	}
+	return response.AdminPass, nil
+}
+
+func (this *OpenStack) VmUnrescue(vm *lobster.VirtualMachine) error {
+	return this.serverAction(vm, map[string]interface{}{"unrescue": nil}, 202)
+}
+
+// ISO images are booted through rescue mode with the image as the rescue image.
+// This keeps the server's disk attached so that it can be repaired or reinstalled.
+func (this *OpenStack) VmMountISO(vm *lobster.VirtualMachine, imageIdentification string) error {
+	request := map[string]interface{}{
+		"rescue": map[string]interface{}{
+			"rescue_image_ref": imageIdentification,
+		},
+	}
+	return this.serverAction(vm, request, 200)
+}
+
+func (this *OpenStack) VmUnmountISO(vm *lobster.VirtualMachine) error {
+	return this.VmUnrescue(vm)
+}
+
+// Changes the administrator password through the hypervisor, which requires the guest agent in the image.
+func (this *OpenStack) VmResetPassword(vm *lobster.VirtualMachine) (string, error) {
+	password := utils.Uid(16)
+	request := map[string]interface{}{
+		"changePassword": map[string]interface{}{
+			"adminPass": password,
+		},
+	}
+	err := this.serverAction(vm, request, 202)
+	if err != nil {
+		return "", err
+	}
+	// the password from creation is no longer valid
+	vm.SetMetadata("password", "unknown")
+	return password, nil
+}
+
 func (this *OpenStack) VmRename(vm *lobster.VirtualMachine, name string) error {
 	opts := servers.UpdateOpts{
 		Name: name,
@@ -214,9 +417,9 @@ func (this *OpenStack) VmRename(vm *lobster.VirtualMachine, name string) error {
 	return err
 }
 
-func (this *OpenStack) VmReimage(vm *lobster.VirtualMachine, imageIdentification string) error {
+func (this *OpenStack) VmReimage(vm *lobster.VirtualMachine, options *lobster.VMIReimageOptions) error {
 	opts := servers.RebuildOpts{
-		ImageID: imageIdentification,
+		ImageID: options.ImageIdentification,
 	}
 	_, err := servers.Rebuild(this.ComputeClient, vm.Identification, opts).Extract()
 	return err
@@ -229,6 +432,299 @@ func (this *OpenStack) VmSnapshot(vm *lobster.VirtualMachine) (string, error) {
 	return servers.CreateImage(this.ComputeClient, vm.Identification, opts).ExtractImageID()
 }
 
+// Firewall rules are stored in a security group created for each VM on demand.
+func (this *OpenStack) firewallGroupName(vm *lobster.VirtualMachine) string {
+	return "lobster-" + vm.Identification
+}
+
+// Returns the security group of the VM, or nil if no rules have been added yet.
+func (this *OpenStack) firewallGroup(vm *lobster.VirtualMachine) (*secgroups.SecurityGroup, error) {
+	name := this.firewallGroupName(vm)
+	var group *secgroups.SecurityGroup
+	err := secgroups.ListByServer(this.ComputeClient, vm.Identification).EachPage(func(page pagination.Page) (bool, error) {
+		groups, err := secgroups.ExtractSecurityGroups(page)
+		if err != nil {
+			return false, err
+		}
+
+		for i := range groups {
+			if groups[i].Name == name {
+				group = &groups[i]
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+	return group, err
+}
+
+func (this *OpenStack) VmFirewallRules(vm *lobster.VirtualMachine) ([]*lobster.FirewallRule, error) {
+	group, err := this.firewallGroup(vm)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([]*lobster.FirewallRule, 0)
+	if group == nil {
+		return rules, nil
+	}
+	for _, osRule := range group.Rules {
+		if osRule.IPRange.CIDR == "" {
+			// rule references another group, which we do not create
+			continue
+		}
+		rule := &lobster.FirewallRule{
+			Id:       osRule.ID,
+			Protocol: strings.ToLower(osRule.IPProtocol),
+			Cidr:     osRule.IPRange.CIDR,
+		}
+		if rule.Protocol != "icmp" {
+			rule.PortMin = osRule.FromPort
+			rule.PortMax = osRule.ToPort
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func (this *OpenStack) VmFirewallAdd(vm *lobster.VirtualMachine, rule *lobster.FirewallRule) error {
+	group, err := this.firewallGroup(vm)
+	if err != nil {
+		return err
+	} else if group == nil {
+		opts := secgroups.CreateOpts{
+			Name:        this.firewallGroupName(vm),
+			Description: "lobster firewall for " + vm.Name,
+		}
+		group, err = secgroups.Create(this.ComputeClient, opts).Extract()
+		if err != nil {
+			return err
+		}
+		err = secgroups.AddServerToGroup(this.ComputeClient, vm.Identification, group.Name).ExtractErr()
+		if err != nil {
+			secgroups.Delete(this.ComputeClient, group.ID)
+			return err
+		}
+	}
+
+	opts := secgroups.CreateRuleOpts{
+		ParentGroupID: group.ID,
+		FromPort:      rule.PortMin,
+		ToPort:        rule.PortMax,
+		IPProtocol:    rule.Protocol,
+		CIDR:          rule.Cidr,
+	}
+	if rule.Protocol == "icmp" {
+		// -1 matches all ICMP types and codes
+		opts.FromPort = -1
+		opts.ToPort = -1
+	}
+	_, err = secgroups.CreateRule(this.ComputeClient, opts).Extract()
+	return err
+}
+
+func (this *OpenStack) VmFirewallRemove(vm *lobster.VirtualMachine, ruleId string) error {
+	// make sure that the rule belongs to this VM's group before deleting it
+	rules, err := this.VmFirewallRules(vm)
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		if rule.Id == ruleId {
+			return secgroups.DeleteRule(this.ComputeClient, ruleId).ExtractErr()
+		}
+	}
+	return errors.New("firewall rule not found")
+}
+
+func (this *OpenStack) VolumeCreate(volume *lobster.Volume) (string, error) {
+	if this.VolumeClient == nil {
+		return "", errors.New("block storage not available")
+	}
+	opts := volumes.CreateOpts{
+		Name: volume.Name,
+		Size: volume.Size,
+	}
+	osVolume, err := volumes.Create(this.VolumeClient, opts).Extract()
+	if err != nil {
+		return "", err
+	} else {
+		return osVolume.ID, nil
+	}
+}
+
+func (this *OpenStack) VolumeDelete(volume *lobster.Volume) error {
+	if this.VolumeClient == nil {
+		return errors.New("block storage not available")
+	}
+	return volumes.Delete(this.VolumeClient, volume.Identification).ExtractErr()
+}
+
+func (this *OpenStack) VolumeInfo(volume *lobster.Volume) (*lobster.VolumeInfo, error) {
+	if this.VolumeClient == nil {
+		return nil, errors.New("block storage not available")
+	}
+	osVolume, err := volumes.Get(this.VolumeClient, volume.Identification).Extract()
+	if err != nil {
+		return nil, err
+	}
+
+	info := new(lobster.VolumeInfo)
+	if osVolume.Status == "available" || osVolume.Status == "in-use" {
+		info.Status = lobster.VolumeActive
+	} else if strings.HasPrefix(osVolume.Status, "error") {
+		info.Status = lobster.VolumeError
+	} else {
+		info.Status = lobster.VolumePending
+	}
+	return info, nil
+}
+
+func (this *OpenStack) VolumeResize(volume *lobster.Volume, size int) error {
+	if this.VolumeClient == nil {
+		return errors.New("block storage not available")
+	}
+	// the volumes package does not wrap the extend action
+	request := map[string]interface{}{
+		"os-extend": map[string]interface{}{
+			"new_size": size,
+		},
+	}
+	_, err := this.VolumeClient.Request("POST", this.VolumeClient.ServiceURL("volumes", volume.Identification, "action"), gophercloud.RequestOpts{
+		JSONBody: request,
+		OkCodes:  []int{202},
+	})
+	return err
+}
+
+func (this *OpenStack) VolumeAttach(vm *lobster.VirtualMachine, volume *lobster.Volume) error {
+	opts := volumeattach.CreateOpts{
+		VolumeID: volume.Identification,
+	}
+	_, err := volumeattach.Create(this.ComputeClient, vm.Identification, opts).Extract()
+	return err
+}
+
+func (this *OpenStack) VolumeDetach(vm *lobster.VirtualMachine, volume *lobster.Volume) error {
+	// attachment ID is the same as the volume ID
+	return volumeattach.Delete(this.ComputeClient, vm.Identification, volume.Identification).ExtractErr()
+}
+
+// Reserved IPs are only enabled if a pool is configured.
+func (this *OpenStack) CanFloatingIPs() bool {
+	return this.ReservedIpPool != ""
+}
+
+func (this *OpenStack) FloatingIPAllocate() (string, string, error) {
+	if this.ReservedIpPool == "" {
+		return "", "", errors.New("reserved IP pool not configured")
+	}
+	floatingIp, err := floatingip.Create(this.ComputeClient, floatingip.CreateOpts{Pool: this.ReservedIpPool}).Extract()
+	if err != nil {
+		return "", "", err
+	} else {
+		return floatingIp.ID, floatingIp.IP, nil
+	}
+}
+
+func (this *OpenStack) FloatingIPRelease(identification string) error {
+	return floatingip.Delete(this.ComputeClient, identification).ExtractErr()
+}
+
+func (this *OpenStack) FloatingIPAttach(vm *lobster.VirtualMachine, identification string) error {
+	floatingIp, err := floatingip.Get(this.ComputeClient, identification).Extract()
+	if err != nil {
+		return err
+	} else if floatingIp.InstanceID == vm.Identification {
+		return nil
+	}
+	return floatingip.Associate(this.ComputeClient, vm.Identification, floatingIp.IP).ExtractErr()
+}
+
+func (this *OpenStack) FloatingIPDetach(vm *lobster.VirtualMachine, identification string) error {
+	floatingIp, err := floatingip.Get(this.ComputeClient, identification).Extract()
+	if err != nil {
+		return err
+	}
+	return floatingip.Disassociate(this.ComputeClient, vm.Identification, floatingIp.IP).ExtractErr()
+}
+
+// Each lobster network is a tenant network with a single subnet, separate from the shared network in networkId.
+func (this *OpenStack) NetworkCreate(network *lobster.Network) (string, error) {
+	if this.NetworkClient == nil {
+		return "", errors.New("networking not available")
+	}
+	osNetwork, err := networks.Create(this.NetworkClient, networks.CreateOpts{
+		Name:         network.Name,
+		AdminStateUp: networks.Up,
+	}).Extract()
+	if err != nil {
+		return "", err
+	}
+	_, err = subnets.Create(this.NetworkClient, subnets.CreateOpts{
+		NetworkID: osNetwork.ID,
+		CIDR:      network.Cidr,
+		IPVersion: subnets.IPv4,
+		Name:      network.Name,
+	}).Extract()
+	if err != nil {
+		networks.Delete(this.NetworkClient, osNetwork.ID)
+		return "", err
+	}
+	return osNetwork.ID, nil
+}
+
+func (this *OpenStack) NetworkDelete(network *lobster.Network) error {
+	if this.NetworkClient == nil {
+		return errors.New("networking not available")
+	}
+	// subnets are deleted along with the network
+	return networks.Delete(this.NetworkClient, network.Identification).ExtractErr()
+}
+
+// the attachinterfaces extension is not wrapped by gophercloud, so these use raw requests
+
+type osInterfaceAttachment struct {
+	PortID string `json:"port_id"`
+	NetID  string `json:"net_id"`
+}
+
+func (this *OpenStack) NetworkAttach(vm *lobster.VirtualMachine, network *lobster.Network) error {
+	request := map[string]interface{}{
+		"interfaceAttachment": map[string]interface{}{
+			"net_id": network.Identification,
+		},
+	}
+	_, err := this.ComputeClient.Request("POST", this.ComputeClient.ServiceURL("servers", vm.Identification, "os-interface"), gophercloud.RequestOpts{
+		JSONBody: request,
+		OkCodes:  []int{200},
+	})
+	return err
+}
+
+func (this *OpenStack) NetworkDetach(vm *lobster.VirtualMachine, network *lobster.Network) error {
+	var response struct {
+		InterfaceAttachments []osInterfaceAttachment `json:"interfaceAttachments"`
+	}
+	_, err := this.ComputeClient.Request("GET", this.ComputeClient.ServiceURL("servers", vm.Identification, "os-interface"), gophercloud.RequestOpts{
+		JSONResponse: &response,
+		OkCodes:      []int{200},
+	})
+	if err != nil {
+		return err
+	}
+	for _, attachment := range response.InterfaceAttachments {
+		if attachment.NetID == network.Identification {
+			_, err := this.ComputeClient.Request("DELETE", this.ComputeClient.ServiceURL("servers", vm.Identification, "os-interface", attachment.PortID), gophercloud.RequestOpts{
+				OkCodes: []int{202},
+			})
+			return err
+		}
+	}
+	// already detached
+	return nil
+}
+
 func (this *OpenStack) BandwidthAccounting(vm *lobster.VirtualMachine) int64 {
 	return 0
 }
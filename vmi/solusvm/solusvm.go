@@ -137,9 +137,9 @@ func (this *SolusVM) VmRename(vm *lobster.VirtualMachine, name string) error {
 	return this.Api.VmHostname(vmIdentificationInt, name)
 }
 
-func (this *SolusVM) VmReimage(vm *lobster.VirtualMachine, imageIdentification string) error {
+func (this *SolusVM) VmReimage(vm *lobster.VirtualMachine, options *lobster.VMIReimageOptions) error {
 	vmIdentificationInt, _ := strconv.Atoi(vm.Identification)
-	return this.Api.VmReimage(vmIdentificationInt, imageIdentification)
+	return this.Api.VmReimage(vmIdentificationInt, options.ImageIdentification)
 }
 
 func (this *SolusVM) VmResize(vm *lobster.VirtualMachine, plan *lobster.Plan) error {
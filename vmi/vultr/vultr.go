@@ -79,6 +79,7 @@ func (this *Vultr) VmCreate(vm *lobster.VirtualMachine, options *lobster.VMIVmCr
 	serverOptions := &vultr.ServerOptions{
 		PrivateNetworking: true,
 		IPV6:              true,
+		UserData:          options.UserData,
 	}
 
 	imageParts := strings.SplitN(options.ImageIdentification, ":", 2)
@@ -113,6 +114,10 @@ func (this *Vultr) VmCreate(vm *lobster.VirtualMachine, options *lobster.VMIVmCr
 	}
 }
 
+func (this *Vultr) CanUserData() bool {
+	return true
+}
+
 func (this *Vultr) VmDelete(vm *lobster.VirtualMachine) error {
 	return this.client.DeleteServer(vm.Identification)
 }
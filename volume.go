@@ -0,0 +1,291 @@
+package lobster
+
+import "fmt"
+import "log"
+import "time"
+
+// database objects
+
+type Volume struct {
+	Id             int
+	UserId         int
+	Region         string
+	Name           string
+	Identification string
+	Size           int // in GB
+	Status         string
+	VmId           int // zero if not attached
+	CreatedTime    time.Time
+}
+
+// interface objects
+
+type VolumeStatus int
+
+const (
+	VolumePending VolumeStatus = iota
+	VolumeActive
+	VolumeError
+)
+
+type VolumeInfo struct {
+	Status VolumeStatus
+}
+
+func volumeListHelper(rows Rows) []*Volume {
+	defer rows.Close()
+	volumes := make([]*Volume, 0)
+	for rows.Next() {
+		volume := Volume{}
+		rows.Scan(&volume.Id, &volume.UserId, &volume.Region, &volume.Name, &volume.Identification, &volume.Size, &volume.Status, &volume.VmId, &volume.CreatedTime)
+		volumes = append(volumes, &volume)
+	}
+	return volumes
+}
+
+const VOLUME_QUERY = "SELECT id, user_id, region, name, identification, size, status, vm_id, time_created FROM volumes"
+
+func volumeList(userId int) []*Volume {
+	return volumeListHelper(db.Query(VOLUME_QUERY+" WHERE user_id = ? ORDER BY name", userId))
+}
+
+func volumeListVm(vmId int) []*Volume {
+	return volumeListHelper(db.Query(VOLUME_QUERY+" WHERE vm_id = ? ORDER BY name", vmId))
+}
+
+// Returns volumes of the user in the region that are not attached to any virtual machine.
+func volumeListAvailable(userId int, region string) []*Volume {
+	return volumeListHelper(db.Query(VOLUME_QUERY+" WHERE user_id = ? AND region = ? AND vm_id = 0 AND status = 'active' ORDER BY name", userId, region))
+}
+
+func volumeGet(userId int, volumeId int) *Volume {
+	volumes := volumeListHelper(db.Query(VOLUME_QUERY+" WHERE id = ? AND user_id = ?", volumeId, userId))
+	if len(volumes) == 1 {
+		return volumes[0]
+	} else {
+		return nil
+	}
+}
+
+func volumeGetForce(volumeId int) *Volume {
+	volumes := volumeListHelper(db.Query(VOLUME_QUERY+" WHERE id = ?", volumeId))
+	if len(volumes) == 1 {
+		return volumes[0]
+	} else {
+		return nil
+	}
+}
+
+// Returns whether volumes can be created in the region.
+func regionCanVolumes(region string) bool {
+	_, ok := regionInterface(region).(VMIVolumes)
+	return ok
+}
+
+func volumeCreate(userId int, region string, name string, size int) (int, error) {
+	err := vmNameOk(name)
+	if err != nil {
+		return 0, err
+	} else if size < 1 || size > MAX_VOLUME_SIZE {
+		return 0, L.Errorf("invalid_volume_size", MAX_VOLUME_SIZE)
+	}
+
+	// validate credit
+	user := UserDetails(userId)
+	if user == nil {
+		return 0, L.Error("invalid_account")
+	} else if user.Credit < MINIMUM_CREDIT {
+		return 0, L.Error("insufficient_credit")
+	}
+
+	// validate region
+	vmi := regionInterface(region)
+	if vmi == nil || !regionEnabled(region) {
+		return 0, L.Error("invalid_region")
+	}
+	vmiVolumes, ok := vmi.(VMIVolumes)
+	if !ok {
+		return 0, L.Error("operation_unsupported")
+	}
+	err = userQuotaCheck(userId, map[string]int{"storage": size})
+	if err != nil {
+		return 0, err
+	}
+
+	log.Printf("volumeCreate(%d, %s, %s, %d)", userId, region, name, size)
+	volume := &Volume{
+		UserId: userId,
+		Region: region,
+		Name:   name,
+		Size:   size,
+	}
+	volumeIdentification, err := vmiVolumes.VolumeCreate(volume)
+	if err != nil {
+		return 0, err
+	}
+	result := db.Exec(
+		"INSERT INTO volumes (user_id, region, name, identification, size) VALUES (?, ?, ?, ?, ?)",
+		userId, region, name, volumeIdentification, size,
+	)
+	return result.LastInsertId(), nil
+}
+
+func volumeDelete(userId int, volumeId int) error {
+	volume := volumeGet(userId, volumeId)
+	if volume == nil {
+		return L.Error("invalid_volume")
+	} else if volume.VmId != 0 {
+		return L.Error("volume_in_use")
+	}
+
+	vmi, ok := vmGetInterface(volume.Region).(VMIVolumes)
+	if !ok {
+		return L.Error("operation_unsupported")
+	}
+
+	log.Printf("volumeDelete(%d, %d)", userId, volumeId)
+	err := vmi.VolumeDelete(volume)
+	if err != nil {
+		return err
+	}
+	db.Exec("DELETE FROM volumes WHERE id = ?", volume.Id)
+	return nil
+}
+
+// Deletes the volume on the back-end (detaching it first if needed) and removes it from the database.
+// Errors from the back-end are reported but otherwise ignored.
+func volumeDeleteForce(volumeId int) {
+	volume := volumeGetForce(volumeId)
+	if volume == nil {
+		return
+	}
+
+	vmi, ok := vmGetInterface(volume.Region).(VMIVolumes)
+	if ok {
+		if volume.VmId != 0 {
+			vm := vmGet(volume.VmId)
+			if vm != nil {
+				err := vmi.VolumeDetach(vm, volume)
+				if err != nil {
+					ReportError(err, "volume force detach failed", fmt.Sprintf("volume_id=%d, vm_id=%d", volume.Id, vm.Id))
+				}
+			}
+		}
+		err := vmi.VolumeDelete(volume)
+		if err != nil {
+			ReportError(err, "volume force deletion failed", fmt.Sprintf("volume_id=%d, identification=%s", volume.Id, volume.Identification))
+		}
+	}
+	db.Exec("DELETE FROM volumes WHERE id = ?", volume.Id)
+}
+
+// Grows the volume to the specified size in GB; the volume must be detached.
+func volumeResize(userId int, volumeId int, size int) error {
+	volume := volumeGet(userId, volumeId)
+	if volume == nil {
+		return L.Error("invalid_volume")
+	} else if volume.VmId != 0 {
+		return L.Error("volume_in_use")
+	} else if volume.Status != "active" {
+		return L.Error("volume_not_ready")
+	} else if size <= volume.Size {
+		return L.Error("volume_shrink_unsupported")
+	} else if size > MAX_VOLUME_SIZE {
+		return L.Errorf("invalid_volume_size", MAX_VOLUME_SIZE)
+	}
+
+	vmi, ok := vmGetInterface(volume.Region).(VMIVolumes)
+	if !ok {
+		return L.Error("operation_unsupported")
+	}
+	err := userQuotaCheck(userId, map[string]int{"storage": size - volume.Size})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("volumeResize(%d, %d, %d)", userId, volumeId, size)
+	err = vmi.VolumeResize(volume, size)
+	if err != nil {
+		return err
+	}
+	db.Exec("UPDATE volumes SET size = ?, status = 'pending' WHERE id = ?", size, volume.Id)
+	return nil
+}
+
+func (vm *VirtualMachine) AttachVolume(volumeId int) error {
+	volume := volumeGet(vm.UserId, volumeId)
+	if volume == nil {
+		return L.Error("invalid_volume")
+	} else if volume.Region != vm.Region {
+		return L.Error("volume_wrong_region")
+	} else if volume.VmId != 0 {
+		return L.Error("volume_in_use")
+	} else if volume.Status != "active" {
+		return L.Error("volume_not_ready")
+	}
+
+	log.Printf("vmAttachVolume(%d, %d)", vm.Id, volumeId)
+	return vm.do(func(vm *VirtualMachine) error {
+		vmi, ok := vmGetInterface(vm.Region).(VMIVolumes)
+		if !ok {
+			return L.Error("operation_unsupported")
+		}
+		err := vmi.VolumeAttach(vm, volume)
+		if err != nil {
+			return err
+		}
+		db.Exec("UPDATE volumes SET vm_id = ? WHERE id = ?", vm.Id, volume.Id)
+		return nil
+	})
+}
+
+func (vm *VirtualMachine) DetachVolume(volumeId int) error {
+	volume := volumeGet(vm.UserId, volumeId)
+	if volume == nil || volume.VmId != vm.Id {
+		return L.Error("invalid_volume")
+	}
+
+	log.Printf("vmDetachVolume(%d, %d)", vm.Id, volumeId)
+	return vm.do(func(vm *VirtualMachine) error {
+		vmi, ok := vmGetInterface(vm.Region).(VMIVolumes)
+		if !ok {
+			return L.Error("operation_unsupported")
+		}
+		err := vmi.VolumeDetach(vm, volume)
+		if err != nil {
+			return err
+		}
+		db.Exec("UPDATE volumes SET vm_id = 0 WHERE id = ?", volume.Id)
+		return nil
+	})
+}
+
+// Updates the status of pending volumes; called from cached.
+func volumeUpdatePending() {
+	volumes := volumeListHelper(db.Query(VOLUME_QUERY + " WHERE status = 'pending' ORDER BY RAND() LIMIT 3"))
+	for _, volume := range volumes {
+		vmi, ok := vmGetInterface(volume.Region).(VMIVolumes)
+		if !ok {
+			continue
+		}
+		info, err := vmi.VolumeInfo(volume)
+		if err != nil {
+			ReportError(err, "volumeInfo failed", fmt.Sprintf("volume_id=%d, identification=%s", volume.Id, volume.Identification))
+			continue
+		}
+
+		if info.Status == VolumeError {
+			db.Exec("UPDATE volumes SET status = 'error' WHERE id = ?", volume.Id)
+		} else if info.Status == VolumeActive {
+			db.Exec("UPDATE volumes SET status = 'active' WHERE id = ?", volume.Id)
+		}
+	}
+}
+
+// Returns the total size in GB of the billable volumes owned by the user.
+// Volumes that are still being created or that failed are not billed.
+func volumeUserSize(userId int) int {
+	var size int
+	db.QueryRow("SELECT IFNULL(SUM(size), 0) FROM volumes WHERE user_id = ? AND status = 'active'", userId).Scan(&size)
+	return size
+}
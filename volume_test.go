@@ -0,0 +1,144 @@
+package lobster
+
+import "errors"
+import "testing"
+
+func TestBillingVolumes(t *testing.T) {
+	TestReset()
+	cfg.Billing.VolumeFee = 0.0001
+	userId := TestUser()
+	db.Exec("UPDATE users SET status = 'active', time_billed = DATE_SUB(NOW(), INTERVAL 2 HOUR) WHERE id = ?", userId)
+	db.Exec("INSERT INTO volumes (user_id, region, name, identification, size, status) VALUES (?, 'test', 'a', 'a', 60, 'active')", userId)
+	db.Exec("INSERT INTO volumes (user_id, region, name, identification, size, status) VALUES (?, 'test', 'b', 'b', 40, 'active')", userId)
+
+	// volumes that are pending or failed should not be billed
+	db.Exec("INSERT INTO volumes (user_id, region, name, identification, size, status) VALUES (?, 'test', 'c', 'c', 30, 'pending')", userId)
+	db.Exec("INSERT INTO volumes (user_id, region, name, identification, size, status) VALUES (?, 'test', 'd', 'd', 20, 'error')", userId)
+
+	serviceBilling()
+	expectedCharge := 100 * int64(cfg.Billing.VolumeFee*BILLING_PRECISION) * 2
+	if !testVerifyCharge(userId, "volumes", expectedCharge) {
+		t.Fatalf("Expected volume charge of %d for 100 GB over two hours", expectedCharge)
+	}
+
+	// the hourly rate should include volumes
+	if UserCreditSummary(userId).Hourly != 100*int64(cfg.Billing.VolumeFee*BILLING_PRECISION) {
+		t.Fatal("Hourly rate in credit summary does not include volumes")
+	}
+}
+
+type testVolumeVmi struct {
+	TestVmi
+	deleted []string
+}
+
+func (this *testVolumeVmi) VolumeCreate(volume *Volume) (string, error) {
+	return "", nil
+}
+
+func (this *testVolumeVmi) VolumeDelete(volume *Volume) error {
+	this.deleted = append(this.deleted, volume.Identification)
+	return nil
+}
+
+func (this *testVolumeVmi) VolumeInfo(volume *Volume) (*VolumeInfo, error) {
+	return nil, nil
+}
+
+func (this *testVolumeVmi) VolumeResize(volume *Volume, size int) error {
+	return nil
+}
+
+func (this *testVolumeVmi) VolumeAttach(vm *VirtualMachine, volume *Volume) error {
+	return nil
+}
+
+func (this *testVolumeVmi) VolumeDetach(vm *VirtualMachine, volume *Volume) error {
+	return nil
+}
+
+func TestBillingTerminateVolumes(t *testing.T) {
+	TestReset()
+	cfg.Billing.VolumeFee = 0.0001
+	cfg.BillingNotifications.LowBalanceIntervals = 1
+	vmi := &testVolumeVmi{}
+	defer TestRegion("testvolume", vmi)()
+
+	// the user has no virtual machines, only a volume, and is past the termination threshold
+	userId := TestUser()
+	db.Exec("UPDATE users SET credit = -1000, billing_low_count = 10 WHERE id = ?", userId)
+	db.Exec("INSERT INTO volumes (user_id, region, name, identification, size, status) VALUES (?, 'testvolume', 'a', 'a', 60, 'active')", userId)
+
+	testForceUserBilling(userId)
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM volumes WHERE user_id = ?", userId).Scan(&count)
+	if count != 0 {
+		t.Fatal("Volume not deleted on account termination")
+	} else if len(vmi.deleted) != 1 || vmi.deleted[0] != "a" {
+		t.Fatalf("Expected volume to be deleted on the back-end, got %v", vmi.deleted)
+	}
+}
+
+func TestVolumeQuota(t *testing.T) {
+	TestReset()
+	cfg.Quota.Storage = 100
+	defer TestRegion("testvolume", &testVolumeVmi{})()
+
+	// pending volumes count against the quota along with active ones
+	userId := TestUser()
+	db.Exec("INSERT INTO volumes (user_id, region, name, identification, size, status) VALUES (?, 'testvolume', 'a', 'a', 60, 'pending')", userId)
+	if usage := userQuotaUsage(userId); usage["storage"] != 60 {
+		t.Fatalf("Expected 60 GB of storage usage, got %d", usage["storage"])
+	}
+	if _, err := volumeCreate(userId, "testvolume", "b", 50); err == nil {
+		t.Fatal("Volume created beyond storage quota")
+	}
+	volumeId, err := volumeCreate(userId, "testvolume", "b", 40)
+	if err != nil {
+		t.Fatalf("Failed to create volume within storage quota: %v", err)
+	}
+	db.Exec("UPDATE volumes SET status = 'active' WHERE id = ?", volumeId)
+	if err := volumeResize(userId, volumeId, 41); err == nil {
+		t.Fatal("Volume resized beyond storage quota")
+	}
+}
+
+type testDeleteVolumeVmi struct {
+	testVolumeVmi
+	err error
+}
+
+func (this *testDeleteVolumeVmi) VmDelete(vm *VirtualMachine) error {
+	return this.err
+}
+
+func TestVmDeleteVolumes(t *testing.T) {
+	TestReset()
+	vmi := &testDeleteVolumeVmi{err: errors.New("delete failed")}
+	defer TestRegion("testvolume", vmi)()
+	userId := TestUser()
+	vmId := TestVm(userId)
+	db.Exec("UPDATE vms SET region = 'testvolume', identification = 'vm' WHERE id = ?", vmId)
+	volumeId := db.Exec("INSERT INTO volumes (user_id, region, name, identification, size, status, vm_id) VALUES (?, 'testvolume', 'a', 'a', 10, 'active', ?)", userId, vmId).LastInsertId()
+
+	// the volume stays attached until the instance is deleted on the back-end
+	if err := vmGet(vmId).DeleteForce(userId); err != nil {
+		t.Fatalf("Failed to delete VM: %v", err)
+	}
+	var jobId int
+	db.QueryRow("SELECT id FROM jobs WHERE vm_id = ? AND kind = 'vmDelete'", vmId).Scan(&jobId)
+	if volumeGet(userId, volumeId).VmId != vmId {
+		t.Fatal("Volume detached before the instance was deleted")
+	} else if err := vmDeleteJob(jobGet(jobId)); err == nil {
+		t.Fatal("Delete job passed with failing back-end")
+	} else if volumeGet(userId, volumeId).VmId != vmId {
+		t.Fatal("Volume detached after the back-end delete failed")
+	}
+
+	vmi.err = nil
+	if err := vmDeleteJob(jobGet(jobId)); err != nil {
+		t.Fatalf("Delete job failed: %v", err)
+	} else if volumeGet(userId, volumeId).VmId != 0 {
+		t.Fatal("Volume not detached after the instance was deleted")
+	}
+}